@@ -4,23 +4,91 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port                     string
-	RateLimitRequestsPerMin  int
-	MaxWalletsPerRequest     int
-	SolanaRPCEndpoint        string
-	DragonflyAddr            string
-	DragonflyPassword        string
-	DragonflyDB              int
-	MongoDBURI               string
-	MongoDBDatabase          string
-	MongoDBCollectionAPIKeys string
-	APIKeyCacheTTL           int `json:"api_key_cache_ttl"`
-	BalanceCacheTTL          int `json:"balance_cache_ttl"`
+	Port                                string
+	CORSAllowedOrigins                  []string `json:"cors_allowed_origins"`
+	RateLimitRequestsPerMin             int
+	MaxWalletsPerRequest                int
+	MaxWalletsPerGroup                  int
+	SolanaRPCEndpoint                   string
+	SolanaWSEndpoint                    string
+	CacheBackend                        string
+	CacheMemoryMaxEntries               int
+	DragonflyAddr                       string
+	DragonflyPassword                   string
+	DragonflyDB                         int
+	MongoDBURI                          string
+	MongoDBDatabase                     string
+	MongoDBCollectionAPIKeys            string
+	MongoDBCollectionWatchlist          string
+	MongoDBCollectionWebhooks           string
+	MongoDBCollectionWebhookDeliveries  string
+	MongoDBCollectionWebhookDeadLetters string
+	MongoDBCollectionLeases             string
+	MongoDBCollectionAlertRules         string
+	MongoDBCollectionBalanceSnapshots   string
+	MongoDBCollectionWalletGroups       string
+	APIKeyCacheTTL                      int      `json:"api_key_cache_ttl"`
+	APIKeyCacheSize                     int      `json:"api_key_cache_size"`
+	MemoryCacheCleanupInterval          int      `json:"memory_cache_cleanup_interval"`
+	BalanceCacheTTL                     int      `json:"balance_cache_ttl"`
+	BalanceNotFoundCacheTTL             int      `json:"balance_not_found_cache_ttl"`
+	BalanceErrorCacheTTL                int      `json:"balance_error_cache_ttl"`
+	StakeCacheTTL                       int      `json:"stake_cache_ttl"`
+	StakeErrorCacheTTL                  int      `json:"stake_error_cache_ttl"`
+	StakeLookupConcurrency              int      `json:"stake_lookup_concurrency"`
+	StakeMaxLookupsPerRequest           int      `json:"stake_max_lookups_per_request"`
+	AccountCacheTTL                     int      `json:"account_cache_ttl"`
+	PriceCacheTTL                       int      `json:"price_cache_ttl"`
+	PriceFeeds                          []string `json:"price_feeds"`
+	PriceMaxAge                         int      `json:"price_max_age"`
+	PriceMaxConfidenceBps               int      `json:"price_max_confidence_bps"`
+	TokenMetadataCacheTTL               int      `json:"token_metadata_cache_ttl"`
+	TokenMetadataErrorCacheTTL          int      `json:"token_metadata_error_cache_ttl"`
+	TokenListPath                       string   `json:"token_list_path"`
+	NFTCacheTTL                         int      `json:"nft_cache_ttl"`
+	SNSCacheTTL                         int      `json:"sns_cache_ttl"`
+	NetworkCacheTTL                     int      `json:"network_cache_ttl"`
+	EpochCacheTTL                       int      `json:"epoch_cache_ttl"`
+	BalanceL1CacheTTL                   int      `json:"balance_l1_cache_ttl"`
+	BalanceL1CacheSize                  int      `json:"balance_l1_cache_size"`
+	CacheWarmerEnabled                  bool     `json:"cache_warmer_enabled"`
+	CacheWarmerInterval                 int      `json:"cache_warmer_interval"`
+	CacheWarmerRPCBudget                int      `json:"cache_warmer_rpc_budget"`
+	CacheWarmerLearnTopN                int      `json:"cache_warmer_learn_top_n"`
+	WatchlistWallets                    []string `json:"watchlist_wallets"`
+	AccountSubscriptionsEnabled         bool     `json:"account_subscriptions_enabled"`
+	SubscribedBalanceCacheTTL           int      `json:"subscribed_balance_cache_ttl"`
+	StreamPollInterval                  int      `json:"stream_poll_interval"`
+	StreamPollMaxWallets                int      `json:"stream_poll_max_wallets"`
+	StreamMaxWalletsPerKey              int      `json:"stream_max_wallets_per_key"`
+	StreamHeartbeatInterval             int      `json:"stream_heartbeat_interval"`
+	StreamBufferSize                    int      `json:"stream_buffer_size"`
+	StreamReplayBufferSize              int      `json:"stream_replay_buffer_size"`
+	WebhookMaxAttempts                  int      `json:"webhook_max_attempts"`
+	WebhookRetryBackoffMs               int      `json:"webhook_retry_backoff_ms"`
+	WebhookTimeout                      int      `json:"webhook_timeout"`
+	WebhookWorkers                      int      `json:"webhook_workers"`
+	WebhookAllowPrivateURLs             bool     `json:"webhook_allow_private_urls"`
+	WebhookMaxPerKey                    int      `json:"webhook_max_per_key"`
+	WebhookMaxWalletsPerKey             int      `json:"webhook_max_wallets_per_key"`
+	WebhookLeaseTTL                     int      `json:"webhook_lease_ttl"`
+	SMTPAddr                            string
+	SMTPUsername                        string
+	SMTPPassword                        string
+	SMTPFrom                            string
+	AlertMaxRecipientsPerKey            int  `json:"alert_max_recipients_per_key"`
+	AlertLeaseTTL                       int  `json:"alert_lease_ttl"`
+	SnapshotsEnabled                    bool `json:"snapshots_enabled"`
+	SnapshotInterval                    int  `json:"snapshot_interval"`
+	SnapshotRetentionDays               int  `json:"snapshot_retention_days"`
+	BalanceAtMaxSignatures              int  `json:"balance_at_max_signatures"`
+	BalanceAtCacheTTLDays               int  `json:"balance_at_cache_ttl_days"`
 }
 
 var AppConfig *Config
@@ -31,19 +99,101 @@ func Load() {
 	}
 
 	AppConfig = &Config{
-		Port:                     getEnvString("PORT", "8080"),
-		RateLimitRequestsPerMin:  getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 10),
-		MaxWalletsPerRequest:     getEnvInt("MAX_WALLETS_PER_REQUEST", 50),
-		SolanaRPCEndpoint:        getEnvString("SOLANA_RPC_ENDPOINT", "https://api.mainnet-beta.solana.com"),
-		DragonflyAddr:            getEnvString("DRAGONFLY_ADDR", "localhost:6379"),
-		DragonflyPassword:        getEnvString("DRAGONFLY_PASSWORD", ""),
-		DragonflyDB:              getEnvInt("DRAGONFLY_DB", 0),
-		MongoDBURI:               getEnvString("MONGODB_URI", "mongodb://localhost:27017"),
-		MongoDBDatabase:          getEnvString("MONGODB_DATABASE", "nova_api"),
-		MongoDBCollectionAPIKeys: getEnvString("MONGODB_COLLECTION_APIKEYS", "api_keys"),
-		APIKeyCacheTTL:           getEnvInt("API_KEY_CACHE_TTL", 300),
-		BalanceCacheTTL:          getEnvInt("BALANCE_CACHE_TTL", 300),
+		Port:                                getEnvString("PORT", "8080"),
+		CORSAllowedOrigins:                  getEnvStringSlice("CORS_ALLOWED_ORIGINS"),
+		RateLimitRequestsPerMin:             getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 10),
+		MaxWalletsPerRequest:                getEnvInt("MAX_WALLETS_PER_REQUEST", 50),
+		MaxWalletsPerGroup:                  getEnvInt("MAX_WALLETS_PER_GROUP", 200),
+		SolanaRPCEndpoint:                   getEnvString("SOLANA_RPC_ENDPOINT", "https://api.mainnet-beta.solana.com"),
+		SolanaWSEndpoint:                    getEnvString("SOLANA_WS_ENDPOINT", ""),
+		CacheBackend:                        getEnvString("CACHE_BACKEND", "redis"),
+		CacheMemoryMaxEntries:               getEnvInt("CACHE_MEMORY_MAX_ENTRIES", 100000),
+		DragonflyAddr:                       getEnvString("DRAGONFLY_ADDR", "localhost:6379"),
+		DragonflyPassword:                   getEnvString("DRAGONFLY_PASSWORD", ""),
+		DragonflyDB:                         getEnvInt("DRAGONFLY_DB", 0),
+		MongoDBURI:                          getEnvString("MONGODB_URI", "mongodb://localhost:27017"),
+		MongoDBDatabase:                     getEnvString("MONGODB_DATABASE", "nova_api"),
+		MongoDBCollectionAPIKeys:            getEnvString("MONGODB_COLLECTION_APIKEYS", "api_keys"),
+		MongoDBCollectionWatchlist:          getEnvString("MONGODB_COLLECTION_WATCHLIST", "watchlist"),
+		MongoDBCollectionWebhooks:           getEnvString("MONGODB_COLLECTION_WEBHOOKS", "webhooks"),
+		MongoDBCollectionWebhookDeliveries:  getEnvString("MONGODB_COLLECTION_WEBHOOK_DELIVERIES", "webhook_deliveries"),
+		MongoDBCollectionWebhookDeadLetters: getEnvString("MONGODB_COLLECTION_WEBHOOK_DEAD_LETTERS", "webhook_dead_letters"),
+		MongoDBCollectionLeases:             getEnvString("MONGODB_COLLECTION_LEASES", "leases"),
+		MongoDBCollectionAlertRules:         getEnvString("MONGODB_COLLECTION_ALERT_RULES", "alert_rules"),
+		MongoDBCollectionBalanceSnapshots:   getEnvString("MONGODB_COLLECTION_BALANCE_SNAPSHOTS", "balance_snapshots"),
+		MongoDBCollectionWalletGroups:       getEnvString("MONGODB_COLLECTION_WALLET_GROUPS", "wallet_groups"),
+		APIKeyCacheTTL:                      getEnvInt("API_KEY_CACHE_TTL", 300),
+		APIKeyCacheSize:                     getEnvInt("API_KEY_CACHE_SIZE", 10000),
+		MemoryCacheCleanupInterval:          getEnvInt("MEMORY_CACHE_CLEANUP_INTERVAL", 60),
+		BalanceCacheTTL:                     getEnvInt("BALANCE_CACHE_TTL", 300),
+		BalanceNotFoundCacheTTL:             getEnvInt("BALANCE_NOT_FOUND_CACHE_TTL", 60),
+		BalanceErrorCacheTTL:                getEnvInt("BALANCE_ERROR_CACHE_TTL", 5),
+		StakeCacheTTL:                       getEnvInt("STAKE_CACHE_TTL", 60),
+		StakeErrorCacheTTL:                  getEnvInt("STAKE_ERROR_CACHE_TTL", 10),
+		StakeLookupConcurrency:              getEnvInt("STAKE_LOOKUP_CONCURRENCY", 4),
+		StakeMaxLookupsPerRequest:           getEnvInt("STAKE_MAX_LOOKUPS_PER_REQUEST", 25),
+		AccountCacheTTL:                     getEnvInt("ACCOUNT_CACHE_TTL", 10),
+		PriceCacheTTL:                       getEnvInt("PRICE_CACHE_TTL", 5),
+		PriceFeeds:                          getEnvStringSlice("PRICE_FEEDS"),
+		PriceMaxAge:                         getEnvInt("PRICE_MAX_AGE", 60),
+		PriceMaxConfidenceBps:               getEnvInt("PRICE_MAX_CONFIDENCE_BPS", 200),
+		TokenMetadataCacheTTL:               getEnvInt("TOKEN_METADATA_CACHE_TTL", 86400),
+		TokenMetadataErrorCacheTTL:          getEnvInt("TOKEN_METADATA_ERROR_CACHE_TTL", 60),
+		TokenListPath:                       getEnvString("TOKEN_LIST_PATH", ""),
+		NFTCacheTTL:                         getEnvInt("NFT_CACHE_TTL", 300),
+		SNSCacheTTL:                         getEnvInt("SNS_CACHE_TTL", 300),
+		NetworkCacheTTL:                     getEnvInt("NETWORK_CACHE_TTL", 2),
+		EpochCacheTTL:                       getEnvInt("EPOCH_CACHE_TTL", 60),
+		BalanceL1CacheTTL:                   getEnvInt("BALANCE_L1_CACHE_TTL", 2),
+		BalanceL1CacheSize:                  getEnvInt("BALANCE_L1_CACHE_SIZE", 10000),
+		CacheWarmerEnabled:                  getEnvBool("CACHE_WARMER_ENABLED", false),
+		CacheWarmerInterval:                 getEnvInt("CACHE_WARMER_INTERVAL", 0),
+		CacheWarmerRPCBudget:                getEnvInt("CACHE_WARMER_RPC_BUDGET", 100),
+		CacheWarmerLearnTopN:                getEnvInt("CACHE_WARMER_LEARN_TOP_N", 0),
+		WatchlistWallets:                    getEnvStringSlice("WATCHLIST_WALLETS"),
+		AccountSubscriptionsEnabled:         getEnvBool("ACCOUNT_SUBSCRIPTIONS_ENABLED", false),
+		SubscribedBalanceCacheTTL:           getEnvInt("SUBSCRIBED_BALANCE_CACHE_TTL", 3600),
+		StreamPollInterval:                  getEnvInt("STREAM_POLL_INTERVAL", 5),
+		StreamPollMaxWallets:                getEnvInt("STREAM_POLL_MAX_WALLETS", 200),
+		StreamMaxWalletsPerKey:              getEnvInt("STREAM_MAX_WALLETS_PER_KEY", 100),
+		StreamHeartbeatInterval:             getEnvInt("STREAM_HEARTBEAT_INTERVAL", 30),
+		StreamBufferSize:                    getEnvInt("STREAM_BUFFER_SIZE", 64),
+		StreamReplayBufferSize:              getEnvInt("STREAM_REPLAY_BUFFER_SIZE", 1024),
+		WebhookMaxAttempts:                  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookRetryBackoffMs:               getEnvInt("WEBHOOK_RETRY_BACKOFF_MS", 1000),
+		WebhookTimeout:                      getEnvInt("WEBHOOK_TIMEOUT", 10),
+		WebhookWorkers:                      getEnvInt("WEBHOOK_WORKERS", 4),
+		WebhookAllowPrivateURLs:             getEnvBool("WEBHOOK_ALLOW_PRIVATE_URLS", false),
+		WebhookMaxPerKey:                    getEnvInt("WEBHOOK_MAX_PER_KEY", 25),
+		WebhookMaxWalletsPerKey:             getEnvInt("WEBHOOK_MAX_WALLETS_PER_KEY", 1000),
+		WebhookLeaseTTL:                     getEnvInt("WEBHOOK_LEASE_TTL", 30),
+		SMTPAddr:                            getEnvString("SMTP_ADDR", ""),
+		SMTPUsername:                        getEnvString("SMTP_USERNAME", ""),
+		SMTPPassword:                        getEnvString("SMTP_PASSWORD", ""),
+		SMTPFrom:                            getEnvString("SMTP_FROM", "nova@localhost"),
+		AlertMaxRecipientsPerKey:            getEnvInt("ALERT_MAX_RECIPIENTS_PER_KEY", 10),
+		AlertLeaseTTL:                       getEnvInt("ALERT_LEASE_TTL", 30),
+		SnapshotsEnabled:                    getEnvBool("SNAPSHOTS_ENABLED", false),
+		SnapshotInterval:                    getEnvInt("SNAPSHOT_INTERVAL", 3600),
+		SnapshotRetentionDays:               getEnvInt("SNAPSHOT_RETENTION_DAYS", 0),
+		BalanceAtMaxSignatures:              getEnvInt("BALANCE_AT_MAX_SIGNATURES", 10000),
+		BalanceAtCacheTTLDays:               getEnvInt("BALANCE_AT_CACHE_TTL_DAYS", 7),
 	}
+
+	if AppConfig.SolanaWSEndpoint == "" {
+		AppConfig.SolanaWSEndpoint = webSocketEndpoint(AppConfig.SolanaRPCEndpoint)
+	}
+}
+
+// webSocketEndpoint derives the PubSub endpoint that Solana RPC nodes serve next to their HTTP endpoint.
+func webSocketEndpoint(httpEndpoint string) string {
+	switch {
+	case strings.HasPrefix(httpEndpoint, "https://"):
+		return "wss://" + strings.TrimPrefix(httpEndpoint, "https://")
+	case strings.HasPrefix(httpEndpoint, "http://"):
+		return "ws://" + strings.TrimPrefix(httpEndpoint, "http://")
+	}
+	return httpEndpoint
 }
 
 func getEnvString(key, defaultValue string) string {
@@ -70,3 +220,14 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvStringSlice reads a comma-separated list, ignoring empty items.
+func getEnvStringSlice(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// AccountRPC is the part of the RPC client used to read full account info.
+type AccountRPC interface {
+	GetAccounts(addresses []string, encoding string, slice *rpc.DataSlice) ([]*rpc.AccountInfo, error)
+}
+
+// AccountService reads full account info through the same DragonflyDB cache as
+// balances. Entries are keyed by address, encoding and data slice and kept for
+// AccountCacheTTL (0 disables caching).
+type AccountService struct {
+	rpcClient AccountRPC
+	cache     BalanceCache
+}
+
+func NewAccountService(rpcClient AccountRPC, cache BalanceCache) *AccountService {
+	return &AccountService{
+		rpcClient: rpcClient,
+		cache:     cache,
+	}
+}
+
+// GetAccounts returns one entry per address, in order. Invalid and missing
+// accounts are reported in the entry's Error; only a failed RPC call fails the whole request.
+func (s *AccountService) GetAccounts(addresses []string, encoding string, slice *models.DataSlice) ([]models.AccountInfo, error) {
+	if encoding == "" {
+		encoding = rpc.EncodingBase64
+	}
+	var rpcSlice *rpc.DataSlice
+	if slice != nil {
+		rpcSlice = &rpc.DataSlice{Offset: slice.Offset, Length: slice.Length}
+	}
+
+	accounts := make([]models.AccountInfo, len(addresses))
+	missing := []int{}
+	for i, address := range addresses {
+		accounts[i].Address = address
+		if _, err := solana.PublicKeyFromBase58(address); err != nil {
+			accounts[i].Error = fmt.Sprintf("%v: %v", rpc.ErrInvalidAddress, err)
+			continue
+		}
+		if cached, found := s.cached(accountCacheKey(address, encoding, rpcSlice)); found {
+			accounts[i] = cached
+			continue
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return accounts, nil
+	}
+
+	fetch := make([]string, len(missing))
+	for j, i := range missing {
+		fetch[j] = addresses[i]
+	}
+	results, err := s.rpcClient.GetAccounts(fetch, encoding, rpcSlice)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range missing {
+		if j >= len(results) || results[j] == nil {
+			accounts[i].Error = rpc.ErrAccountNotFound.Error()
+			continue
+		}
+		result := results[j]
+		accounts[i] = models.AccountInfo{
+			Address:    addresses[i],
+			Lamports:   result.Lamports,
+			Owner:      result.Owner,
+			Executable: result.Executable,
+			RentEpoch:  result.RentEpoch,
+			Space:      result.Space,
+			Data:       result.Data,
+		}
+		s.store(accountCacheKey(addresses[i], encoding, rpcSlice), accounts[i])
+	}
+	return accounts, nil
+}
+
+func accountCacheKey(address, encoding string, slice *rpc.DataSlice) string {
+	if slice == nil {
+		return fmt.Sprintf("account:%s:%s", address, encoding)
+	}
+	return fmt.Sprintf("account:%s:%s:%d:%d", address, encoding, slice.Offset, slice.Length)
+}
+
+func (s *AccountService) cached(key string) (models.AccountInfo, bool) {
+	value, err := s.cache.Get(key)
+	if err != nil || value == "" {
+		return models.AccountInfo{}, false
+	}
+
+	var account models.AccountInfo
+	if err := json.Unmarshal([]byte(value), &account); err != nil {
+		return models.AccountInfo{}, false
+	}
+	return account, true
+}
+
+func (s *AccountService) store(key string, account models.AccountInfo) {
+	if config.AppConfig.AccountCacheTTL <= 0 {
+		return
+	}
+	value, err := json.Marshal(account)
+	if err != nil {
+		return
+	}
+	if err := s.cache.Set(key, string(value), time.Duration(config.AppConfig.AccountCacheTTL)*time.Second); err != nil {
+		log.Printf("Failed to cache account %s: %v", key, err)
+	}
+}
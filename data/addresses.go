@@ -0,0 +1,101 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// AddressRPC is the part of the RPC client used to look up validated addresses.
+type AddressRPC interface {
+	GetAccounts(addresses []string, encoding string, slice *rpc.DataSlice) ([]*rpc.AccountInfo, error)
+}
+
+// AddressService validates and classifies addresses. Decoding and the curve
+// check are done locally; only the account lookup goes to the RPC node.
+type AddressService struct {
+	rpcClient AddressRPC
+}
+
+func NewAddressService(rpcClient AddressRPC) *AddressService {
+	return &AddressService{
+		rpcClient: rpcClient,
+	}
+}
+
+// Validate returns one entry per address, in order. Unless offline, the accounts
+// of valid addresses are looked up; a failed lookup is reported in LookupError
+// while the offline checks are still returned.
+func (s *AddressService) Validate(addresses []string, offline bool) []models.AddressValidation {
+	validations := make([]models.AddressValidation, len(addresses))
+	valid := []string{}
+	positions := []int{}
+	for i, address := range addresses {
+		validations[i] = ValidateAddress(address)
+		if validations[i].Valid {
+			valid = append(valid, address)
+			positions = append(positions, i)
+		}
+	}
+	if offline || len(valid) == 0 {
+		return validations
+	}
+
+	slice := rpc.Token2022AccountTypeSlice
+	accounts, err := s.rpcClient.GetAccounts(valid, rpc.EncodingBase64, &slice)
+	if err != nil {
+		log.Printf("Failed to look up %d addresses: %v", len(valid), err)
+		for _, i := range positions {
+			validations[i].LookupError = err.Error()
+		}
+		return validations
+	}
+
+	for j, account := range accounts {
+		validation := &validations[positions[j]]
+		exists := account != nil
+		validation.Exists = &exists
+		if !exists {
+			continue
+		}
+		validation.Owner = account.Owner
+		validation.AccountType = rpc.ClassifyAccount(account.Owner, account.Executable, account.Space, accountTypeByte(account.Data))
+	}
+	return validations
+}
+
+// ValidateAddress makes the checks that need no RPC call: the address must be
+// base58 of 32 bytes, and is a wallet when on the ed25519 curve or a PDA when off it.
+func ValidateAddress(address string) models.AddressValidation {
+	validation := models.AddressValidation{Address: address}
+	pubkey, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		validation.Error = err.Error()
+		return validation
+	}
+
+	validation.Valid = true
+	validation.Curve = "pda"
+	if pubkey.IsOnCurve() {
+		validation.Curve = "wallet"
+	}
+	return validation
+}
+
+// accountTypeByte decodes the single byte of a ["<data>", "base64"] slice, 0 when there is none
+func accountTypeByte(data json.RawMessage) byte {
+	var encoded [2]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return 0
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded[0])
+	if err != nil || len(decoded) == 0 {
+		return 0
+	}
+	return decoded[0]
+}
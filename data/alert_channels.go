@@ -0,0 +1,223 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+const (
+	AlertChannelLog     = "log"
+	AlertChannelWebhook = "webhook"
+	AlertChannelSlack   = "slack"
+	AlertChannelSMTP    = "smtp"
+)
+
+// AlertChannel delivers alert notifications somewhere
+type AlertChannel interface {
+	Send(notification models.AlertNotification) error
+}
+
+// AlertChannelFactory builds a channel from a rule's channel configuration, rejecting invalid configurations
+type AlertChannelFactory func(cfg models.AlertChannelConfig) (AlertChannel, error)
+
+var (
+	alertChannelsLock     sync.RWMutex
+	alertChannelFactories = map[string]AlertChannelFactory{
+		AlertChannelLog:     newLogAlertChannel,
+		AlertChannelWebhook: newWebhookAlertChannel,
+		AlertChannelSlack:   newSlackAlertChannel,
+		AlertChannelSMTP:    newSMTPAlertChannel,
+	}
+)
+
+// RegisterAlertChannel adds or replaces the factory for a channel type
+func RegisterAlertChannel(channelType string, factory AlertChannelFactory) {
+	alertChannelsLock.Lock()
+	defer alertChannelsLock.Unlock()
+
+	alertChannelFactories[channelType] = factory
+}
+
+// NewAlertChannel builds the channel configured by cfg
+func NewAlertChannel(cfg models.AlertChannelConfig) (AlertChannel, error) {
+	alertChannelsLock.RLock()
+	factory, exists := alertChannelFactories[cfg.Type]
+	alertChannelsLock.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown alert channel type %q", cfg.Type)
+	}
+	return factory(cfg)
+}
+
+type logAlertChannel struct{}
+
+func newLogAlertChannel(models.AlertChannelConfig) (AlertChannel, error) {
+	return logAlertChannel{}, nil
+}
+
+func (logAlertChannel) Send(notification models.AlertNotification) error {
+	log.Printf("Alert %s [%s]: %s", notification.RuleName, notification.State, notification.Message)
+	return nil
+}
+
+// httpAlertChannel POSTs a JSON body built from the notification. When the
+// channel has a secret the body is signed like webhook deliveries.
+type httpAlertChannel struct {
+	url    string
+	secret string
+	client *http.Client
+	body   func(models.AlertNotification) interface{}
+}
+
+func newWebhookAlertChannel(cfg models.AlertChannelConfig) (AlertChannel, error) {
+	return newHTTPAlertChannel(cfg, func(notification models.AlertNotification) interface{} {
+		return notification
+	})
+}
+
+// newSlackAlertChannel posts to a Slack-compatible incoming webhook, which only needs a "text" field
+func newSlackAlertChannel(cfg models.AlertChannelConfig) (AlertChannel, error) {
+	cfg.Secret = ""
+	return newHTTPAlertChannel(cfg, func(notification models.AlertNotification) interface{} {
+		return map[string]string{"text": fmt.Sprintf("[%s] %s: %s", strings.ToUpper(notification.State), notification.RuleName, notification.Message)}
+	})
+}
+
+func newHTTPAlertChannel(cfg models.AlertChannelConfig, body func(models.AlertNotification) interface{}) (AlertChannel, error) {
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, fmt.Errorf("%s alert channel needs an http or https url", cfg.Type)
+	}
+	return &httpAlertChannel{
+		url:    cfg.URL,
+		secret: cfg.Secret,
+		client: newWebhookClient(time.Duration(config.AppConfig.WebhookTimeout) * time.Second),
+		body:   body,
+	}, nil
+}
+
+func (c *httpAlertChannel) Send(notification models.AlertNotification) error {
+	payload, err := json.Marshal(c.body(notification))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.secret != "" {
+		req.Header.Set("X-Nova-Signature", SignWebhookPayload(c.secret, payload))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type smtpAlertChannel struct {
+	to []*mail.Address
+}
+
+func newSMTPAlertChannel(cfg models.AlertChannelConfig) (AlertChannel, error) {
+	if config.AppConfig.SMTPAddr == "" {
+		return nil, fmt.Errorf("smtp alert channel needs SMTP_ADDR to be configured")
+	}
+	to, err := ParseAlertRecipients(cfg.To)
+	if err != nil {
+		return nil, err
+	}
+	return &smtpAlertChannel{to: to}, nil
+}
+
+// ParseAlertRecipients parses the recipients of an smtp channel, each a single
+// RFC 5322 address such as "ops@example.com" or "Ops <ops@example.com>".
+func ParseAlertRecipients(to []string) ([]*mail.Address, error) {
+	if len(to) == 0 {
+		return nil, fmt.Errorf("smtp alert channel needs at least one recipient")
+	}
+
+	addresses := make([]*mail.Address, 0, len(to))
+	for _, recipient := range to {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid smtp recipient %q: %v", recipient, err)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+func (c *smtpAlertChannel) Send(notification models.AlertNotification) error {
+	from, err := mail.ParseAddress(config.AppConfig.SMTPFrom)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+
+	var auth smtp.Auth
+	if config.AppConfig.SMTPUsername != "" {
+		host := strings.Split(config.AppConfig.SMTPAddr, ":")[0]
+		auth = smtp.PlainAuth("", config.AppConfig.SMTPUsername, config.AppConfig.SMTPPassword, host)
+	}
+
+	recipients := make([]string, len(c.to))
+	for i, address := range c.to {
+		recipients[i] = address.Address
+	}
+
+	message, err := alertEmail(from, c.to, notification)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(config.AppConfig.SMTPAddr, auth, from.Address, recipients, message)
+}
+
+// alertEmail builds the message for a notification. Addresses are formatted
+// by net/mail and the subject is MIME encoded, so no part of the rule can
+// start a header of its own.
+func alertEmail(from *mail.Address, to []*mail.Address, notification models.AlertNotification) ([]byte, error) {
+	recipients := make([]string, len(to))
+	for i, address := range to {
+		recipients[i] = address.String()
+	}
+	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(notification.State), notification.RuleName)
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from.String())
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&message)
+	if _, err := body.Write([]byte(notification.Message + "\r\n")); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// AlertStore persists alert rules and their firing state
+type AlertStore interface {
+	CreateAlertRule(rule *models.AlertRule) error
+	ListAlertRules(apiKey string) ([]models.AlertRule, error)
+	AllAlertRules() ([]models.AlertRule, error)
+	DeleteAlertRule(apiKey, id string) error
+	UpdateAlertState(id, state string, changedAt time.Time) error
+	AcquireLease(name, holder string, ttl time.Duration) (bool, error)
+}
+
+func (ms *MongoService) CreateAlertRule(rule *models.AlertRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionAlertRules)
+	if _, err := collection.InsertOne(ctx, rule); err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return nil
+}
+
+func (ms *MongoService) ListAlertRules(apiKey string) ([]models.AlertRule, error) {
+	return ms.findAlertRules(bson.M{"api_key": apiKey})
+}
+
+func (ms *MongoService) AllAlertRules() ([]models.AlertRule, error) {
+	return ms.findAlertRules(bson.M{})
+}
+
+func (ms *MongoService) findAlertRules(filter bson.M) ([]models.AlertRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionAlertRules)
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	rules := []models.AlertRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode alert rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (ms *MongoService) DeleteAlertRule(apiKey, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionAlertRules)
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "api_key": apiKey})
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrAlertRuleNotFound
+	}
+	return nil
+}
+
+func (ms *MongoService) UpdateAlertState(id, state string, changedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionAlertRules)
+	update := bson.M{"$set": bson.M{"state": state, "state_changed_at": changedAt}}
+	if _, err := collection.UpdateByID(ctx, id, update); err != nil {
+		return fmt.Errorf("failed to update alert state: %w", err)
+	}
+	return nil
+}
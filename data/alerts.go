@@ -0,0 +1,697 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+const (
+	alertQueueSize = 1024
+
+	AlertConditionBelow           = "below"
+	AlertConditionAbove           = "above"
+	AlertConditionDropPercent     = "drop_percent"
+	AlertConditionBelowRentExempt = "below_rent_exempt"
+
+	AlertStateOK       = "ok"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+
+	alertLeaseName = "alerts"
+)
+
+var (
+	ErrInvalidAlertRule = errors.New("invalid alert rule")
+	ErrAlertLimit       = errors.New("alert limit reached")
+)
+
+// RentMinimums provides the rent-exempt minimums below_rent_exempt rules compare against.
+type RentMinimums interface {
+	Minimums(sizes []uint64) (*models.RentExemptions, error)
+}
+
+// AlertEvaluator checks alert rules against every balance the BalanceFeed
+// observes for their wallets, whether polled or pushed by a subscription. A
+// rule notifies its channels once when it starts firing and once when it
+// resolves; repeated matches while firing are not sent again.
+//
+// With several replicas only the one holding the store's alert lease evaluates
+// rules, saves their states and sends notifications. The others record balance
+// history and take rule states from the store whenever they reload the rules,
+// which every replica does before it renews or tries to take the lease. A
+// replica taking over evaluates every watched wallet against those states.
+type AlertEvaluator struct {
+	store    AlertStore
+	balances BalanceRPC
+	rent     RentMinimums
+	sub      *FeedSubscription
+	holder   string
+	leaseTTL time.Duration
+	leader   atomic.Bool
+
+	// registerMutex serializes Register, Delete and reload so the per-key
+	// recipient limit holds and a reload cannot undo a concurrent change
+	registerMutex sync.Mutex
+	// watchMutex serializes add and remove so the wallets of sub follow
+	// walletRefs. Unlike mutex it is held while sub subscribes upstream.
+	watchMutex sync.Mutex
+
+	mutex      sync.Mutex
+	rules      map[string]*alertRuleEntry
+	walletRefs map[string]int
+	history    map[string][]balancePoint
+
+	queue  chan alertDispatch
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type alertRuleEntry struct {
+	rule     models.AlertRule
+	channels []AlertChannel
+}
+
+type balancePoint struct {
+	balance float64
+	time    time.Time
+}
+
+type alertDispatch struct {
+	notification models.AlertNotification
+	channels     []AlertChannel
+}
+
+// NewAlertEvaluator loads the stored rules and starts watching their wallets.
+// balances provides the current balance of a wallet when a rule is added, so
+// rules are evaluated without waiting for the next change. rent provides the
+// minimums of below_rent_exempt rules.
+func NewAlertEvaluator(store AlertStore, feed *BalanceFeed, balances BalanceRPC, rent RentMinimums) (*AlertEvaluator, error) {
+	rules, err := store.AllAlertRules()
+	if err != nil {
+		return nil, err
+	}
+
+	leaseTTL := time.Duration(config.AppConfig.AlertLeaseTTL) * time.Second
+	if leaseTTL <= 0 {
+		leaseTTL = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	evaluator := &AlertEvaluator{
+		store:      store,
+		balances:   balances,
+		rent:       rent,
+		sub:        feed.Subscribe(alertQueueSize),
+		holder:     newInstanceID(),
+		leaseTTL:   leaseTTL,
+		rules:      make(map[string]*alertRuleEntry),
+		walletRefs: make(map[string]int),
+		history:    make(map[string][]balancePoint),
+		queue:      make(chan alertDispatch, alertQueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	var wallets []string
+	for _, rule := range rules {
+		if _, err := evaluator.add(rule); err != nil {
+			log.Printf("Skipping alert rule %s: %v", rule.ID, err)
+			continue
+		}
+		wallets = append(wallets, rule.Wallet)
+	}
+	evaluator.renewLease()
+
+	evaluator.wg.Add(3)
+	go evaluator.listen(wallets)
+	go evaluator.notify()
+	go evaluator.holdLease()
+
+	return evaluator, nil
+}
+
+// Register validates and stores a new rule for apiKey, then evaluates it
+// against the current balance. The returned rule is the only place the signing
+// secrets of its webhook channels are exposed.
+func (e *AlertEvaluator) Register(apiKey string, request models.AlertRuleRequest) (*models.AlertRule, error) {
+	rule := models.AlertRule{
+		ID:        primitive.NewObjectID().Hex(),
+		APIKey:    apiKey,
+		Name:      request.Name,
+		Wallet:    request.Wallet,
+		Condition: request.Condition,
+		Channels:  append([]models.AlertChannelConfig(nil), request.Channels...),
+		CreatedAt: time.Now().UTC(),
+	}
+	if rule.Name == "" {
+		rule.Name = fmt.Sprintf("%s %s", rule.Condition.Type, rule.Wallet)
+	}
+	if strings.IndexFunc(rule.Name, unicode.IsControl) >= 0 {
+		return nil, fmt.Errorf("%w: name cannot contain line breaks or other control characters", ErrInvalidAlertRule)
+	}
+	if len(rule.Channels) == 0 {
+		rule.Channels = []models.AlertChannelConfig{{Type: AlertChannelLog}}
+	}
+
+	for i := range rule.Channels {
+		channel := &rule.Channels[i]
+		channel.Secret = ""
+		switch channel.Type {
+		case AlertChannelWebhook, AlertChannelSlack:
+			// Checked here rather than in the channel factory so stored rules
+			// load without DNS lookups; connections are checked either way
+			if err := ValidateWebhookURL(channel.URL); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+			}
+		}
+		if channel.Type == AlertChannelWebhook {
+			secret, err := newWebhookSecret()
+			if err != nil {
+				return nil, err
+			}
+			channel.Secret = secret
+		}
+	}
+
+	e.registerMutex.Lock()
+	defer e.registerMutex.Unlock()
+
+	if err := e.checkRecipients(apiKey, rule.Channels); err != nil {
+		return nil, err
+	}
+
+	if _, err := e.add(rule); err != nil {
+		return nil, err
+	}
+	if err := e.store.CreateAlertRule(&rule); err != nil {
+		e.remove(rule.ID)
+		return nil, err
+	}
+
+	e.evaluateCurrent(rule.Wallet)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if entry, exists := e.rules[rule.ID]; exists {
+		rule = entry.rule
+	}
+	return &rule, nil
+}
+
+// checkRecipients enforces AlertMaxRecipientsPerKey on the distinct email
+// recipients of apiKey's stored rules together with channels.
+func (e *AlertEvaluator) checkRecipients(apiKey string, channels []models.AlertChannelConfig) error {
+	max := config.AppConfig.AlertMaxRecipientsPerKey
+	var added []string
+	for _, channel := range channels {
+		if channel.Type == AlertChannelSMTP {
+			added = append(added, channel.To...)
+		}
+	}
+	if max <= 0 || len(added) == 0 {
+		return nil
+	}
+
+	rules, err := e.store.ListAlertRules(apiKey)
+	if err != nil {
+		return err
+	}
+
+	recipients := make(map[string]bool)
+	for _, rule := range rules {
+		for _, channel := range rule.Channels {
+			if channel.Type == AlertChannelSMTP {
+				addRecipients(recipients, channel.To)
+			}
+		}
+	}
+	if err := addRecipients(recipients, added); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+	}
+	if len(recipients) > max {
+		return fmt.Errorf("%w: maximum %d email recipients per API key", ErrAlertLimit, max)
+	}
+	return nil
+}
+
+// addRecipients adds the lowercased addresses of to to recipients.
+func addRecipients(recipients map[string]bool, to []string) error {
+	addresses, err := ParseAlertRecipients(to)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		recipients[strings.ToLower(address.Address)] = true
+	}
+	return nil
+}
+
+// List returns the rules of apiKey without their channel secrets.
+func (e *AlertEvaluator) List(apiKey string) ([]models.AlertRule, error) {
+	rules, err := e.store.ListAlertRules(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		for j := range rules[i].Channels {
+			rules[i].Channels[j].Secret = ""
+		}
+	}
+	return rules, nil
+}
+
+func (e *AlertEvaluator) Delete(apiKey, id string) error {
+	e.registerMutex.Lock()
+	defer e.registerMutex.Unlock()
+
+	if err := e.store.DeleteAlertRule(apiKey, id); err != nil {
+		return err
+	}
+	e.remove(id)
+	return nil
+}
+
+// Close stops watching wallets and waits for queued notifications to be
+// abandoned. The lease expires on its own.
+func (e *AlertEvaluator) Close() {
+	e.cancel()
+	e.sub.Close()
+	e.wg.Wait()
+}
+
+// add starts evaluating a rule and reports whether it was new; adding a known
+// rule does nothing. The feed subscribes upstream without mutex held, so
+// evaluation keeps going meanwhile; the rule is rolled back if that fails.
+func (e *AlertEvaluator) add(rule models.AlertRule) (bool, error) {
+	if err := validateAlertCondition(rule.Condition); err != nil {
+		return false, err
+	}
+
+	channels := make([]AlertChannel, 0, len(rule.Channels))
+	for _, cfg := range rule.Channels {
+		channel, err := NewAlertChannel(cfg)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+		}
+		channels = append(channels, channel)
+	}
+
+	e.watchMutex.Lock()
+	defer e.watchMutex.Unlock()
+
+	e.mutex.Lock()
+	if _, exists := e.rules[rule.ID]; exists {
+		e.mutex.Unlock()
+		return false, nil
+	}
+	watch := e.walletRefs[rule.Wallet] == 0
+	e.walletRefs[rule.Wallet]++
+	e.rules[rule.ID] = &alertRuleEntry{rule: rule, channels: channels}
+	e.mutex.Unlock()
+
+	if watch {
+		if err := e.sub.Add(rule.Wallet); err != nil {
+			e.mutex.Lock()
+			delete(e.rules, rule.ID)
+			e.releaseLocked(rule.Wallet)
+			e.mutex.Unlock()
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (e *AlertEvaluator) remove(id string) {
+	e.watchMutex.Lock()
+	defer e.watchMutex.Unlock()
+
+	e.mutex.Lock()
+	entry, exists := e.rules[id]
+	if !exists {
+		e.mutex.Unlock()
+		return
+	}
+	delete(e.rules, id)
+	released := e.releaseLocked(entry.rule.Wallet)
+	e.mutex.Unlock()
+
+	if released {
+		e.sub.Remove(entry.rule.Wallet)
+	}
+}
+
+// releaseLocked drops a reference to wallet and reports whether nobody watches it anymore.
+func (e *AlertEvaluator) releaseLocked(wallet string) bool {
+	e.walletRefs[wallet]--
+	if e.walletRefs[wallet] > 0 {
+		return false
+	}
+	delete(e.walletRefs, wallet)
+	delete(e.history, wallet)
+	return true
+}
+
+// holdLease renews or tries to take the alert lease a few times per lease
+// period and reloads the rules each time.
+func (e *AlertEvaluator) holdLease() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			e.reload()
+			if e.renewLease() {
+				e.evaluateAll()
+			}
+		}
+	}
+}
+
+// renewLease renews or tries to take the lease and reports whether this replica just took it.
+func (e *AlertEvaluator) renewLease() bool {
+	leader, err := e.store.AcquireLease(alertLeaseName, e.holder, e.leaseTTL)
+	if err != nil {
+		// Stand down rather than risk a second replica sending the same notifications
+		log.Printf("Failed to renew alert lease: %v", err)
+		leader = false
+	}
+	if leader != e.leader.Load() {
+		log.Printf("Alert notification lease %s: leader=%t", e.holder, leader)
+	}
+	return leader && !e.leader.Swap(leader)
+}
+
+func (e *AlertEvaluator) evaluateAll() {
+	e.mutex.Lock()
+	wallets := make([]string, 0, len(e.walletRefs))
+	for wallet := range e.walletRefs {
+		wallets = append(wallets, wallet)
+	}
+	e.mutex.Unlock()
+
+	for _, wallet := range wallets {
+		e.evaluateCurrent(wallet)
+	}
+}
+
+// reload brings the evaluated rules in line with the store. Rules registered
+// on another replica are evaluated against the current balance right away, and
+// replicas other than the leader take over the states the leader saved.
+func (e *AlertEvaluator) reload() {
+	e.registerMutex.Lock()
+	defer e.registerMutex.Unlock()
+
+	rules, err := e.store.AllAlertRules()
+	if err != nil {
+		log.Printf("Failed to reload alert rules: %v", err)
+		return
+	}
+
+	stored := make(map[string]bool, len(rules))
+	added := make(map[string]bool)
+	for _, rule := range rules {
+		stored[rule.ID] = true
+		isNew, err := e.add(rule)
+		if err != nil {
+			log.Printf("Skipping alert rule %s: %v", rule.ID, err)
+			continue
+		}
+		if isNew {
+			added[rule.Wallet] = true
+		} else if !e.leader.Load() {
+			e.mutex.Lock()
+			if entry, exists := e.rules[rule.ID]; exists {
+				entry.rule.State = rule.State
+				entry.rule.StateChangedAt = rule.StateChangedAt
+			}
+			e.mutex.Unlock()
+		}
+	}
+
+	e.mutex.Lock()
+	var deleted []string
+	for id := range e.rules {
+		if !stored[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	e.mutex.Unlock()
+	for _, id := range deleted {
+		e.remove(id)
+	}
+
+	for wallet := range added {
+		e.evaluateCurrent(wallet)
+	}
+}
+
+func validateAlertCondition(condition models.AlertCondition) error {
+	switch condition.Type {
+	case AlertConditionBelow, AlertConditionAbove:
+		if condition.Value < 0 {
+			return fmt.Errorf("%w: value cannot be negative", ErrInvalidAlertRule)
+		}
+	case AlertConditionDropPercent:
+		if condition.Value <= 0 || condition.Value > 100 {
+			return fmt.Errorf("%w: drop_percent value must be between 0 and 100", ErrInvalidAlertRule)
+		}
+		if condition.WindowSeconds <= 0 {
+			return fmt.Errorf("%w: drop_percent needs a positive window_seconds", ErrInvalidAlertRule)
+		}
+	case AlertConditionBelowRentExempt:
+		if condition.DataSize < 0 || condition.DataSize > rpc.MaxAccountDataSize {
+			return fmt.Errorf("%w: data_size must be between 0 and %d bytes", ErrInvalidAlertRule, rpc.MaxAccountDataSize)
+		}
+	default:
+		return fmt.Errorf("%w: unknown condition type %q", ErrInvalidAlertRule, condition.Type)
+	}
+	return nil
+}
+
+func (e *AlertEvaluator) listen(initialWallets []string) {
+	defer e.wg.Done()
+
+	for _, wallet := range initialWallets {
+		e.evaluateCurrent(wallet)
+	}
+
+	for update := range e.sub.Updates() {
+		e.observe(update.Wallet, update.Balance, update.Time)
+	}
+}
+
+func (e *AlertEvaluator) evaluateCurrent(walletAddress string) {
+	balance, err := e.balances.GetBalance(walletAddress)
+	if err != nil {
+		log.Printf("Failed to get balance for alerts on wallet %s: %v", walletAddress, err)
+		return
+	}
+	e.observe(walletAddress, balance, time.Now().UTC())
+}
+
+// observe records a balance and evaluates every rule on the wallet, dispatching state changes.
+func (e *AlertEvaluator) observe(walletAddress string, balance float64, at time.Time) {
+	type stateChange struct {
+		id    string
+		state string
+	}
+	var changes []stateChange
+	var dispatches []alertDispatch
+
+	if !e.leader.Load() {
+		// Recorded anyway so drop_percent windows are complete once this replica takes over
+		e.mutex.Lock()
+		if e.walletRefs[walletAddress] > 0 {
+			e.recordLocked(walletAddress, balance, at)
+		}
+		e.mutex.Unlock()
+		return
+	}
+
+	// Rent-exempt minimums come from the node, so they are read without the lock
+	minimums := e.rentMinimums(walletAddress)
+
+	e.mutex.Lock()
+	if e.walletRefs[walletAddress] == 0 {
+		e.mutex.Unlock()
+		return
+	}
+
+	history := e.recordLocked(walletAddress, balance, at)
+	for _, entry := range e.rules {
+		rule := &entry.rule
+		if rule.Wallet != walletAddress {
+			continue
+		}
+		if _, known := minimums[rule.Condition.DataSize]; rule.Condition.Type == AlertConditionBelowRentExempt && !known {
+			// Keep the current state until the minimum can be read
+			continue
+		}
+
+		met, message := evaluateAlertCondition(rule.Condition, history, balance, at, minimums)
+
+		var state string
+		switch {
+		case met && rule.State != AlertStateFiring:
+			state = AlertStateFiring
+		case !met && rule.State == AlertStateFiring:
+			state = AlertStateResolved
+		case !met && rule.State == "":
+			// A new rule that is not firing starts out quietly
+			rule.State = AlertStateOK
+			rule.StateChangedAt = at
+			changes = append(changes, stateChange{id: rule.ID, state: AlertStateOK})
+			continue
+		default:
+			continue
+		}
+
+		rule.State = state
+		rule.StateChangedAt = at
+		changes = append(changes, stateChange{id: rule.ID, state: state})
+		dispatches = append(dispatches, alertDispatch{
+			notification: models.AlertNotification{
+				RuleID:   rule.ID,
+				RuleName: rule.Name,
+				Wallet:   walletAddress,
+				State:    state,
+				Balance:  balance,
+				Message:  message,
+				Time:     at,
+			},
+			channels: entry.channels,
+		})
+	}
+	e.mutex.Unlock()
+
+	for _, change := range changes {
+		if err := e.store.UpdateAlertState(change.id, change.state, at); err != nil {
+			log.Printf("Failed to save state of alert rule %s: %v", change.id, err)
+		}
+	}
+	for _, dispatch := range dispatches {
+		select {
+		case e.queue <- dispatch:
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// rentMinimums returns the rent-exempt minimum in SOL by data size for the
+// wallet's below_rent_exempt rules. It is empty when the minimums cannot be read.
+func (e *AlertEvaluator) rentMinimums(walletAddress string) map[int]float64 {
+	e.mutex.Lock()
+	var sizes []uint64
+	seen := make(map[int]bool)
+	for _, entry := range e.rules {
+		condition := entry.rule.Condition
+		if entry.rule.Wallet == walletAddress && condition.Type == AlertConditionBelowRentExempt && !seen[condition.DataSize] {
+			seen[condition.DataSize] = true
+			sizes = append(sizes, uint64(condition.DataSize))
+		}
+	}
+	e.mutex.Unlock()
+
+	minimums := make(map[int]float64, len(sizes))
+	if len(sizes) == 0 {
+		return minimums
+	}
+
+	exemptions, err := e.rent.Minimums(sizes)
+	if err != nil {
+		log.Printf("Failed to get rent-exempt minimums for alerts on wallet %s: %v", walletAddress, err)
+		return minimums
+	}
+	for _, exemption := range exemptions.Minimums {
+		minimums[int(exemption.DataSize)] = exemption.SOL
+	}
+	return minimums
+}
+
+// recordLocked appends a balance to the wallet's history, dropping points older than the longest window of its rules.
+func (e *AlertEvaluator) recordLocked(walletAddress string, balance float64, at time.Time) []balancePoint {
+	var window time.Duration
+	for _, entry := range e.rules {
+		if entry.rule.Wallet == walletAddress && entry.rule.Condition.Type == AlertConditionDropPercent {
+			if w := time.Duration(entry.rule.Condition.WindowSeconds) * time.Second; w > window {
+				window = w
+			}
+		}
+	}
+
+	history := append(e.history[walletAddress], balancePoint{balance: balance, time: at})
+	cutoff := at.Add(-window)
+	start := 0
+	for start < len(history)-1 && history[start].time.Before(cutoff) {
+		start++
+	}
+	history = history[start:]
+	e.history[walletAddress] = history
+	return history
+}
+
+// evaluateAlertCondition reports whether condition holds for balance and
+// describes the outcome. rentMinimums holds the rent-exempt minimum in SOL by data size.
+func evaluateAlertCondition(condition models.AlertCondition, history []balancePoint, balance float64, at time.Time, rentMinimums map[int]float64) (bool, string) {
+	switch condition.Type {
+	case AlertConditionBelow:
+		return balance < condition.Value, fmt.Sprintf("balance %.9g SOL, threshold below %.9g SOL", balance, condition.Value)
+	case AlertConditionAbove:
+		return balance > condition.Value, fmt.Sprintf("balance %.9g SOL, threshold above %.9g SOL", balance, condition.Value)
+	case AlertConditionDropPercent:
+		cutoff := at.Add(-time.Duration(condition.WindowSeconds) * time.Second)
+		peak := balance
+		for _, point := range history {
+			if !point.time.Before(cutoff) && point.balance > peak {
+				peak = point.balance
+			}
+		}
+		drop := 0.0
+		if peak > 0 {
+			drop = (peak - balance) / peak * 100
+		}
+		return drop > condition.Value, fmt.Sprintf("balance %.9g SOL dropped %.2f%% from %.9g SOL within %ds, threshold %.2f%%",
+			balance, drop, peak, condition.WindowSeconds, condition.Value)
+	case AlertConditionBelowRentExempt:
+		minimum := rentMinimums[condition.DataSize]
+		return balance < minimum, fmt.Sprintf("balance %.9g SOL, rent-exempt minimum %.9g SOL for %d bytes", balance, minimum, condition.DataSize)
+	}
+	return false, ""
+}
+
+func (e *AlertEvaluator) notify() {
+	defer e.wg.Done()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case dispatch := <-e.queue:
+			for _, channel := range dispatch.channels {
+				if err := channel.Send(dispatch.notification); err != nil {
+					log.Printf("Failed to send alert %s: %v", dispatch.notification.RuleID, err)
+				}
+			}
+		}
+	}
+}
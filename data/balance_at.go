@@ -0,0 +1,217 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+var (
+	// ErrNotFinalized is returned for points in time the finalized chain has not reached yet.
+	ErrNotFinalized = errors.New("requested point is not finalized yet")
+	// ErrHistoryTooLong is returned when more than BalanceAtMaxSignatures transactions would have to be walked.
+	ErrHistoryTooLong = errors.New("wallet history is too long to reconstruct")
+)
+
+// PastBalanceRPC is the part of the RPC client used to reconstruct past balances.
+type PastBalanceRPC interface {
+	GetBalanceAndSlot(walletAddress string) (float64, uint64, error)
+	GetSignatures(walletAddress, before string, limit int) ([]rpc.SignatureInfo, error)
+	GetBalanceChange(signature, walletAddress string) (*rpc.BalanceChange, error)
+}
+
+// PastBalanceService reconstructs a wallet's balance at a past slot or time from
+// its transaction history. It walks getSignaturesForAddress backwards from the
+// tip until it reaches the target and reads the balance from the pre- and
+// post-balances of the transaction at that boundary. Only finalized data is used,
+// so transaction checkpoints and slot results never go stale; they are still
+// cached for a bounded time since the keys are chosen by callers.
+type PastBalanceService struct {
+	rpcClient     PastBalanceRPC
+	cache         BalanceCache
+	maxSignatures int
+	cacheTTL      time.Duration
+}
+
+func NewPastBalanceService(rpcClient PastBalanceRPC, cache BalanceCache) *PastBalanceService {
+	return &PastBalanceService{
+		rpcClient:     rpcClient,
+		cache:         cache,
+		maxSignatures: config.AppConfig.BalanceAtMaxSignatures,
+		cacheTTL:      time.Duration(config.AppConfig.BalanceAtCacheTTLDays) * 24 * time.Hour,
+	}
+}
+
+// BalanceAtSlot returns the balance at the end of slot.
+func (s *PastBalanceService) BalanceAtSlot(walletAddress string, slot uint64) (*models.PastBalance, error) {
+	resultKey := fmt.Sprintf("balance:at:%s:slot:%d", walletAddress, slot)
+	if cached := s.cached(resultKey); cached != nil {
+		return cached, nil
+	}
+
+	current, finalizedSlot, err := s.rpcClient.GetBalanceAndSlot(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	if slot > finalizedSlot {
+		return nil, fmt.Errorf("%w: slot %d is past the finalized slot %d", ErrNotFinalized, slot, finalizedSlot)
+	}
+
+	result, err := s.reconstruct(walletAddress, current, func(signature rpc.SignatureInfo) bool {
+		return signature.Slot <= slot
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Slot = slot
+
+	// Slots up to the finalized one never change, so neither does the balance at them
+	s.store(resultKey, result)
+	return result, nil
+}
+
+// BalanceAtTime returns the balance as of at, using the block time of each transaction.
+func (s *PastBalanceService) BalanceAtTime(walletAddress string, at time.Time) (*models.PastBalance, error) {
+	if at.After(time.Now()) {
+		return nil, fmt.Errorf("%w: %s is in the future", ErrNotFinalized, at.Format(time.RFC3339))
+	}
+
+	current, _, err := s.rpcClient.GetBalanceAndSlot(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	target := at.Unix()
+	result, err := s.reconstruct(walletAddress, current, func(signature rpc.SignatureInfo) bool {
+		return signature.BlockTime != 0 && signature.BlockTime <= target
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := at.UTC()
+	result.Timestamp = &timestamp
+	return result, nil
+}
+
+// reconstruct walks the history newest first until reached reports a transaction
+// at or before the target, whose post-balance is the answer. If the history ends
+// first, the target predates every transaction and the pre-balance of the oldest
+// one is the answer. Without any transactions the balance never changed.
+func (s *PastBalanceService) reconstruct(walletAddress string, current float64, reached func(rpc.SignatureInfo) bool) (*models.PastBalance, error) {
+	var oldest *rpc.SignatureInfo
+	before := ""
+	walked := 0
+
+	for {
+		limit := rpc.MaxSignaturesPerPage
+		if s.maxSignatures > 0 && s.maxSignatures-walked < limit {
+			limit = s.maxSignatures - walked
+		}
+		if limit <= 0 {
+			return nil, fmt.Errorf("%w: more than %d transactions", ErrHistoryTooLong, s.maxSignatures)
+		}
+
+		signatures, err := s.rpcClient.GetSignatures(walletAddress, before, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range signatures {
+			if reached(signatures[i]) {
+				return s.fromCheckpoint(walletAddress, signatures[i], "post")
+			}
+		}
+
+		walked += len(signatures)
+		if len(signatures) > 0 {
+			oldest = &signatures[len(signatures)-1]
+			before = oldest.Signature
+		}
+		if len(signatures) < limit {
+			break
+		}
+	}
+
+	if oldest == nil {
+		return &models.PastBalance{Wallet: walletAddress, Balance: current}, nil
+	}
+	return s.fromCheckpoint(walletAddress, *oldest, "pre")
+}
+
+func (s *PastBalanceService) fromCheckpoint(walletAddress string, signature rpc.SignatureInfo, position string) (*models.PastBalance, error) {
+	change, err := s.balanceChange(walletAddress, signature.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := change.PostBalance
+	if position == "pre" {
+		balance = change.PreBalance
+	}
+	return &models.PastBalance{
+		Wallet:  walletAddress,
+		Balance: balance,
+		Checkpoint: &models.BalanceCheckpoint{
+			Signature: signature.Signature,
+			Slot:      change.Slot,
+			BlockTime: change.BlockTime,
+			Position:  position,
+		},
+	}, nil
+}
+
+// balanceChange returns the wallet's balances around a finalized transaction.
+func (s *PastBalanceService) balanceChange(walletAddress, signature string) (*rpc.BalanceChange, error) {
+	key := fmt.Sprintf("balance:tx:%s:%s", walletAddress, signature)
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		var change rpc.BalanceChange
+		if err := json.Unmarshal([]byte(value), &change); err == nil {
+			return &change, nil
+		}
+	}
+
+	change, err := s.rpcClient.GetBalanceChange(signature, walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, err := json.Marshal(change); err == nil && s.cacheTTL > 0 {
+		if err := s.cache.Set(key, string(value), s.cacheTTL); err != nil {
+			log.Printf("Failed to cache balance checkpoint %s: %v", signature, err)
+		}
+	}
+	return change, nil
+}
+
+func (s *PastBalanceService) cached(key string) *models.PastBalance {
+	value, err := s.cache.Get(key)
+	if err != nil || value == "" {
+		return nil
+	}
+
+	var result models.PastBalance
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (s *PastBalanceService) store(key string, result *models.PastBalance) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+	value, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if err := s.cache.Set(key, string(value), s.cacheTTL); err != nil {
+		log.Printf("Failed to cache past balance %s: %v", key, err)
+	}
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"nova-api/config"
+)
+
+// BalanceCache stores balances for BalanceService. Get and Set give access to
+// plain string entries for data that lives next to the balances. A ttl of 0
+// keeps an entry until the backend evicts it.
+type BalanceCache interface {
+	GetBalance(walletAddress string) (float64, bool, error)
+	SetBalance(walletAddress string, balance float64) error
+	SetBalanceWithTTL(walletAddress string, balance float64, ttl time.Duration) error
+	Get(key string) (string, error)
+	Set(key, value string, ttl time.Duration) error
+	Close() error
+	Ping() error
+}
+
+const (
+	CacheBackendRedis     = "redis"
+	CacheBackendDragonfly = "dragonfly"
+	CacheBackendMemory    = "memory"
+	CacheBackendNone      = "none"
+)
+
+// NewBalanceCache creates the balance cache selected by the CACHE_BACKEND setting.
+func NewBalanceCache() (BalanceCache, error) {
+	switch strings.ToLower(config.AppConfig.CacheBackend) {
+	case CacheBackendRedis, CacheBackendDragonfly, "":
+		return NewCacheService(
+			config.AppConfig.DragonflyAddr,
+			config.AppConfig.DragonflyPassword,
+			config.AppConfig.DragonflyDB,
+		), nil
+	case CacheBackendMemory:
+		return NewMemoryBalanceCache(config.AppConfig.CacheMemoryMaxEntries), nil
+	case CacheBackendNone:
+		return NoopBalanceCache{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", config.AppConfig.CacheBackend)
+	}
+}
+
+// MemoryBalanceCache keeps balances in process, for running without Redis.
+type MemoryBalanceCache struct {
+	cache *MemoryCache
+}
+
+func NewMemoryBalanceCache(maxItems int) *MemoryBalanceCache {
+	return &MemoryBalanceCache{
+		cache: NewMemoryCacheWithOptions(MemoryCacheOptions{
+			MaxItems:        maxItems,
+			CleanupInterval: time.Duration(config.AppConfig.MemoryCacheCleanupInterval) * time.Second,
+		}),
+	}
+}
+
+func (c *MemoryBalanceCache) GetBalance(walletAddress string) (float64, bool, error) {
+	cached, found := c.cache.Get(fmt.Sprintf("balance:%s", walletAddress))
+	if !found {
+		return 0, false, nil
+	}
+
+	balance, ok := cached.(float64)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected cached balance type %T", cached)
+	}
+
+	return balance, true, nil
+}
+
+func (c *MemoryBalanceCache) SetBalance(walletAddress string, balance float64) error {
+	ttl := time.Duration(config.AppConfig.BalanceCacheTTL) * time.Second
+	return c.SetBalanceWithTTL(walletAddress, balance, ttl)
+}
+
+func (c *MemoryBalanceCache) SetBalanceWithTTL(walletAddress string, balance float64, ttl time.Duration) error {
+	c.cache.Set(fmt.Sprintf("balance:%s", walletAddress), balance, ttl)
+	return nil
+}
+
+func (c *MemoryBalanceCache) Get(key string) (string, error) {
+	cached, found := c.cache.Get(key)
+	if !found {
+		return "", nil
+	}
+
+	value, ok := cached.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected cached value type %T", cached)
+	}
+
+	return value, nil
+}
+
+func (c *MemoryBalanceCache) Set(key, value string, ttl time.Duration) error {
+	c.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (c *MemoryBalanceCache) Close() error {
+	c.cache.Close()
+	return nil
+}
+
+func (c *MemoryBalanceCache) Ping() error {
+	return nil
+}
+
+// NoopBalanceCache never stores anything, so every balance is read from the RPC.
+type NoopBalanceCache struct{}
+
+func (NoopBalanceCache) GetBalance(walletAddress string) (float64, bool, error) {
+	return 0, false, nil
+}
+
+func (NoopBalanceCache) SetBalance(walletAddress string, balance float64) error {
+	return nil
+}
+
+func (NoopBalanceCache) SetBalanceWithTTL(walletAddress string, balance float64, ttl time.Duration) error {
+	return nil
+}
+
+func (NoopBalanceCache) Get(key string) (string, error) {
+	return "", nil
+}
+
+func (NoopBalanceCache) Set(key, value string, ttl time.Duration) error {
+	return nil
+}
+
+func (NoopBalanceCache) Close() error {
+	return nil
+}
+
+func (NoopBalanceCache) Ping() error {
+	return nil
+}
@@ -2,6 +2,8 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -12,8 +14,21 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// balanceInvalidationChannel is the pub/sub channel replicas use to drop
+// balances from each other's in-process cache after writing a fresher value.
+const balanceInvalidationChannel = "balance:invalidate"
+
+type balanceInvalidation struct {
+	Origin string `json:"origin"`
+	Wallet string `json:"wallet"`
+}
+
 type CacheService struct {
-	client *redis.Client
+	client     *redis.Client
+	local      *MemoryCache
+	localTTL   time.Duration
+	pubsub     *redis.PubSub
+	instanceID string
 }
 
 func NewCacheService(addr, password string, db int) *CacheService {
@@ -25,12 +40,71 @@ func NewCacheService(addr, password string, db int) *CacheService {
 
 	log.Printf("Connected to Redis: %s", addr)
 
-	return &CacheService{
+	service := &CacheService{
 		client: rdb,
 	}
+
+	// The in-process cache sits in front of Redis and only holds balances for a short time
+	if config.AppConfig.BalanceL1CacheTTL > 0 {
+		service.local = NewMemoryCacheWithOptions(MemoryCacheOptions{
+			MaxItems:        config.AppConfig.BalanceL1CacheSize,
+			CleanupInterval: time.Duration(config.AppConfig.MemoryCacheCleanupInterval) * time.Second,
+		})
+		service.localTTL = time.Duration(config.AppConfig.BalanceL1CacheTTL) * time.Second
+		service.instanceID = newInstanceID()
+		service.pubsub = rdb.Subscribe(context.Background(), balanceInvalidationChannel)
+		go service.listenForInvalidations()
+	}
+
+	return service
+}
+
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (c *CacheService) listenForInvalidations() {
+	for msg := range c.pubsub.Channel() {
+		var invalidation balanceInvalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+			log.Printf("Ignoring malformed balance invalidation: %v", err)
+			continue
+		}
+
+		// Our own writes already updated the local cache
+		if invalidation.Origin == c.instanceID {
+			continue
+		}
+
+		c.local.Delete(invalidation.Wallet)
+	}
+}
+
+func (c *CacheService) publishInvalidation(ctx context.Context, walletAddress string) error {
+	payload, err := json.Marshal(balanceInvalidation{
+		Origin: c.instanceID,
+		Wallet: walletAddress,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal balance invalidation: %w", err)
+	}
+
+	return c.client.Publish(ctx, balanceInvalidationChannel, payload).Err()
 }
 
 func (c *CacheService) GetBalance(walletAddress string) (float64, bool, error) {
+	if c.local != nil {
+		if cached, found := c.local.Get(walletAddress); found {
+			if balance, ok := cached.(float64); ok {
+				return balance, true, nil
+			}
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -49,10 +123,19 @@ func (c *CacheService) GetBalance(walletAddress string) (float64, bool, error) {
 		return 0, false, fmt.Errorf("failed to unmarshal cached balance: %v", err)
 	}
 
+	if c.local != nil {
+		c.local.Set(walletAddress, balance, c.localTTL)
+	}
+
 	return balance, true, nil
 }
 
 func (c *CacheService) SetBalance(walletAddress string, balance float64) error {
+	ttl := time.Duration(config.AppConfig.BalanceCacheTTL) * time.Second
+	return c.SetBalanceWithTTL(walletAddress, balance, ttl)
+}
+
+func (c *CacheService) SetBalanceWithTTL(walletAddress string, balance float64, ttl time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -63,12 +146,18 @@ func (c *CacheService) SetBalance(walletAddress string, balance float64) error {
 		return fmt.Errorf("failed to marshal balance: %w", err)
 	}
 
-	ttl := time.Duration(config.AppConfig.BalanceCacheTTL) * time.Second
 	err = c.client.Set(ctx, key, balanceBytes, ttl).Err()
 	if err != nil {
 		return fmt.Errorf("failed to cache balance: %w", err)
 	}
 
+	if c.local != nil {
+		c.local.Set(walletAddress, balance, c.localTTL)
+		if err := c.publishInvalidation(ctx, walletAddress); err != nil {
+			log.Printf("Failed to publish balance invalidation for wallet %s: %v", walletAddress, err)
+		}
+	}
+
 	return nil
 }
 
@@ -127,6 +216,12 @@ func (c *CacheService) Set(key, value string, ttl time.Duration) error {
 	return c.client.Set(ctx, key, value, ttl).Err()
 }
 func (c *CacheService) Close() error {
+	if c.pubsub != nil {
+		c.pubsub.Close()
+	}
+	if c.local != nil {
+		c.local.Close()
+	}
 	return c.client.Close()
 }
 
@@ -0,0 +1,466 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// BalanceFeed detects balance changes for watched wallets and fans them out to
+// every FeedSubscription interested in them. Each wallet is watched once no
+// matter how many subscriptions include it. Changes come from the
+// SubscriptionManager when one is configured and the wallet's subscription is
+// open, and from polling the RPC otherwise. The most recent changes are kept
+// in a replay buffer so reconnecting clients can catch up on what they missed.
+type BalanceFeed struct {
+	balanceService *BalanceService
+	subscriptions  *SubscriptionManager
+	pollInterval   time.Duration
+	pollMax        int
+
+	mutex       sync.Mutex
+	watches     map[string]*walletWatch
+	subscribers map[*FeedSubscription]bool
+	lastID      uint64
+	history     []models.BalanceUpdate
+	historySize int
+
+	// upstream serializes Subscribe and Unsubscribe calls to subscriptions,
+	// which talk to the node and so are made without mutex held. subscribed
+	// holds the wallets currently subscribed there.
+	upstream   sync.Mutex
+	subscribed map[string]bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type walletWatch struct {
+	refs    int
+	balance float64
+	known   bool
+	// since is the feed position from which on every change of the wallet
+	// was published. It is set together with known.
+	since      uint64
+	lastPolled time.Time
+}
+
+// FeedSubscription receives the changes of the wallets added to it. When its
+// buffer is full the oldest pending update is dropped in favour of the newest.
+type FeedSubscription struct {
+	feed    *BalanceFeed
+	wallets map[string]bool
+	updates chan models.BalanceUpdate
+	closed  bool
+}
+
+// NewBalanceFeed creates a feed and starts polling. subscriptions may be nil to rely on polling only.
+func NewBalanceFeed(balanceService *BalanceService, subscriptions *SubscriptionManager) *BalanceFeed {
+	ctx, cancel := context.WithCancel(context.Background())
+	feed := &BalanceFeed{
+		balanceService: balanceService,
+		subscriptions:  subscriptions,
+		pollInterval:   time.Duration(config.AppConfig.StreamPollInterval) * time.Second,
+		pollMax:        config.AppConfig.StreamPollMaxWallets,
+		watches:        make(map[string]*walletWatch),
+		subscribers:    make(map[*FeedSubscription]bool),
+		historySize:    config.AppConfig.StreamReplayBufferSize,
+		subscribed:     make(map[string]bool),
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	if feed.pollInterval <= 0 {
+		feed.pollInterval = 5 * time.Second
+	}
+
+	if subscriptions != nil {
+		subscriptions.OnUpdate(feed.Publish)
+	}
+
+	go feed.poll(ctx)
+
+	return feed
+}
+
+// Subscribe creates a subscription with room for bufferSize pending updates.
+func (f *BalanceFeed) Subscribe(bufferSize int) *FeedSubscription {
+	sub := &FeedSubscription{
+		feed:    f,
+		wallets: make(map[string]bool),
+		updates: make(chan models.BalanceUpdate, bufferSize),
+	}
+
+	f.mutex.Lock()
+	f.subscribers[sub] = true
+	f.mutex.Unlock()
+
+	return sub
+}
+
+// Publish records a wallet's latest balance and notifies subscribers if it changed.
+func (f *BalanceFeed) Publish(walletAddress string, balance float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	watch, exists := f.watches[walletAddress]
+	if !exists || (watch.known && watch.balance == balance) {
+		return
+	}
+	if !watch.known {
+		watch.since = f.lastID
+	}
+	watch.balance = balance
+	watch.known = true
+
+	f.lastID++
+	update := models.BalanceUpdate{
+		ID:      f.lastID,
+		Wallet:  walletAddress,
+		Balance: balance,
+		Time:    time.Now().UTC(),
+	}
+
+	if f.historySize > 0 {
+		if len(f.history) >= f.historySize {
+			f.history = f.history[1:]
+		}
+		f.history = append(f.history, update)
+	}
+
+	for sub := range f.subscribers {
+		if sub.wallets[walletAddress] {
+			sub.deliver(update)
+		}
+	}
+}
+
+// LastID returns the ID of the most recent update, or 0 if nothing was published yet.
+func (f *BalanceFeed) LastID() uint64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.lastID
+}
+
+// Since returns the buffered updates for wallets published after the update
+// with ID lastID. complete is false when updates after lastID have already
+// been dropped from the buffer, or when a wallet was not watched for the whole
+// time since lastID, so its changes in between were never published. The
+// caller cannot fully resume then.
+func (f *BalanceFeed) Since(lastID uint64, wallets []string) (updates []models.BalanceUpdate, complete bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if lastID > f.lastID {
+		return nil, false
+	}
+
+	oldest := f.lastID + 1
+	if len(f.history) > 0 {
+		oldest = f.history[0].ID
+	}
+	complete = lastID+1 >= oldest
+	for _, wallet := range wallets {
+		watch, exists := f.watches[wallet]
+		if !exists || !watch.known || watch.since > lastID {
+			complete = false
+		}
+	}
+
+	wanted := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		wanted[wallet] = true
+	}
+	for _, update := range f.history {
+		if update.ID > lastID && wanted[update.Wallet] {
+			updates = append(updates, update)
+		}
+	}
+	return updates, complete
+}
+
+// Seed sets a watched wallet's baseline balance without notifying anyone, unless a balance is already known.
+func (f *BalanceFeed) Seed(walletAddress string, balance float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if watch, exists := f.watches[walletAddress]; exists && !watch.known {
+		watch.balance = balance
+		watch.known = true
+		watch.since = f.lastID
+	}
+}
+
+// WatchedWallets returns the number of distinct wallets currently watched.
+func (f *BalanceFeed) WatchedWallets() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return len(f.watches)
+}
+
+func (f *BalanceFeed) Close() {
+	f.cancel()
+	<-f.done
+}
+
+// watch adds a reference to a wallet and reports whether it was not watched
+// before. The caller must hold the mutex, and call syncUpstream once released.
+func (f *BalanceFeed) watch(walletAddress string) bool {
+	watch, exists := f.watches[walletAddress]
+	if !exists {
+		watch = &walletWatch{}
+		f.watches[walletAddress] = watch
+	}
+	watch.refs++
+	return !exists
+}
+
+// unwatch drops a reference to a wallet and reports whether nobody watches it
+// anymore. The caller must hold the mutex, and call syncUpstream once released.
+func (f *BalanceFeed) unwatch(walletAddress string) bool {
+	watch, exists := f.watches[walletAddress]
+	if !exists {
+		return false
+	}
+
+	watch.refs--
+	if watch.refs > 0 {
+		return false
+	}
+
+	delete(f.watches, walletAddress)
+	return true
+}
+
+// syncUpstream subscribes to or unsubscribes from wallets so the
+// SubscriptionManager matches the watches. It compares the current state
+// rather than replaying the changes, so concurrent adds and removes of the
+// same wallet settle correctly whichever order they get here in.
+func (f *BalanceFeed) syncUpstream(wallets []string) {
+	if f.subscriptions == nil || len(wallets) == 0 {
+		return
+	}
+
+	f.upstream.Lock()
+	defer f.upstream.Unlock()
+
+	for _, wallet := range wallets {
+		f.mutex.Lock()
+		_, watched := f.watches[wallet]
+		f.mutex.Unlock()
+
+		switch {
+		case watched && !f.subscribed[wallet]:
+			if err := f.subscriptions.Subscribe(wallet); err != nil {
+				log.Printf("Failed to subscribe to wallet %s, falling back to polling: %v", wallet, err)
+				continue
+			}
+			f.subscribed[wallet] = true
+		case !watched && f.subscribed[wallet]:
+			f.subscriptions.Unsubscribe(wallet)
+			delete(f.subscribed, wallet)
+		}
+	}
+}
+
+func (f *BalanceFeed) poll(ctx context.Context) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.pollOnce()
+		}
+	}
+}
+
+// pollOnce refreshes the watched wallets that are not kept fresh by a
+// subscription. Wallets without a known balance are always candidates, so
+// pushed changes have a baseline. At most pollMax wallets are read per tick,
+// the least recently polled first, so a large watch list is covered over a few
+// ticks instead of flooding the RPC.
+func (f *BalanceFeed) pollOnce() {
+	type candidate struct {
+		wallet     string
+		known      bool
+		lastPolled time.Time
+	}
+
+	f.mutex.Lock()
+	candidates := make([]candidate, 0, len(f.watches))
+	for wallet, watch := range f.watches {
+		candidates = append(candidates, candidate{wallet: wallet, known: watch.known, lastPolled: watch.lastPolled})
+	}
+	f.mutex.Unlock()
+
+	// IsActive takes the manager's lock, so it is asked without holding ours
+	due := candidates[:0]
+	for _, c := range candidates {
+		if c.known && f.subscriptions != nil && f.subscriptions.IsActive(c.wallet) {
+			continue
+		}
+		due = append(due, c)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].lastPolled.Equal(due[j].lastPolled) {
+			return due[i].lastPolled.Before(due[j].lastPolled)
+		}
+		return due[i].wallet < due[j].wallet
+	})
+	if f.pollMax > 0 && len(due) > f.pollMax {
+		due = due[:f.pollMax]
+	}
+
+	now := time.Now()
+	wallets := make([]string, 0, len(due))
+	f.mutex.Lock()
+	for _, c := range due {
+		if watch, exists := f.watches[c.wallet]; exists {
+			watch.lastPolled = now
+		}
+		wallets = append(wallets, c.wallet)
+	}
+	f.mutex.Unlock()
+
+	for _, wallet := range wallets {
+		balance, err := f.balanceService.RefreshBalance(wallet)
+		if err != nil {
+			log.Printf("Failed to poll balance for wallet %s: %v", wallet, err)
+			continue
+		}
+
+		f.mutex.Lock()
+		watch, exists := f.watches[wallet]
+		baseline := exists && !watch.known
+		f.mutex.Unlock()
+
+		// The first reading is the baseline, subscribers already received it when they subscribed
+		if baseline {
+			f.Seed(wallet, balance)
+			continue
+		}
+		f.Publish(wallet, balance)
+	}
+}
+
+// Add starts delivering changes for wallets. Invalid addresses are rejected before anything is added.
+func (s *FeedSubscription) Add(wallets ...string) error {
+	for _, wallet := range wallets {
+		if _, err := solana.PublicKeyFromBase58(wallet); err != nil {
+			return fmt.Errorf("%w %s: %v", rpc.ErrInvalidAddress, wallet, err)
+		}
+	}
+
+	s.feed.mutex.Lock()
+	if s.closed {
+		s.feed.mutex.Unlock()
+		return fmt.Errorf("subscription closed")
+	}
+
+	var added []string
+	for _, wallet := range wallets {
+		if !s.wallets[wallet] {
+			s.wallets[wallet] = true
+			if s.feed.watch(wallet) {
+				added = append(added, wallet)
+			}
+		}
+	}
+	s.feed.mutex.Unlock()
+
+	s.feed.syncUpstream(added)
+	return nil
+}
+
+func (s *FeedSubscription) Remove(wallets ...string) {
+	s.feed.mutex.Lock()
+	var released []string
+	for _, wallet := range wallets {
+		if s.wallets[wallet] {
+			delete(s.wallets, wallet)
+			if s.feed.unwatch(wallet) {
+				released = append(released, wallet)
+			}
+		}
+	}
+	s.feed.mutex.Unlock()
+
+	s.feed.syncUpstream(released)
+}
+
+// Has reports whether wallet is part of the subscription.
+func (s *FeedSubscription) Has(wallet string) bool {
+	s.feed.mutex.Lock()
+	defer s.feed.mutex.Unlock()
+
+	return s.wallets[wallet]
+}
+
+// Len returns the number of wallets in the subscription.
+func (s *FeedSubscription) Len() int {
+	s.feed.mutex.Lock()
+	defer s.feed.mutex.Unlock()
+
+	return len(s.wallets)
+}
+
+func (s *FeedSubscription) Updates() <-chan models.BalanceUpdate {
+	return s.updates
+}
+
+// Close releases every wallet and closes the updates channel.
+func (s *FeedSubscription) Close() {
+	s.feed.mutex.Lock()
+	if s.closed {
+		s.feed.mutex.Unlock()
+		return
+	}
+	s.closed = true
+
+	var released []string
+	for wallet := range s.wallets {
+		if s.feed.unwatch(wallet) {
+			released = append(released, wallet)
+		}
+	}
+	s.wallets = nil
+	delete(s.feed.subscribers, s)
+	close(s.updates)
+	s.feed.mutex.Unlock()
+
+	s.feed.syncUpstream(released)
+}
+
+// deliver queues an update without blocking the feed. The caller must hold the feed mutex.
+func (s *FeedSubscription) deliver(update models.BalanceUpdate) {
+	select {
+	case s.updates <- update:
+		return
+	default:
+	}
+
+	// The consumer is behind, drop its oldest pending update
+	select {
+	case <-s.updates:
+	default:
+	}
+	select {
+	case s.updates <- update:
+	default:
+	}
+}
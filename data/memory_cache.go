@@ -1,80 +1,302 @@
 package data
 
 import (
+	"container/list"
+	"context"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// memoryCacheShards is the number of independently locked partitions of a MemoryCache.
+const memoryCacheShards = 16
+
+// defaultCleanupInterval is how often expired items are swept when no interval is configured.
+const defaultCleanupInterval = time.Minute
+
 type CacheItem struct {
-	Value     interface{}
+	Value interface{}
+	// ExpiresAt is zero for items that never expire
 	ExpiresAt time.Time
 }
 
+func (i CacheItem) expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
+
+// CacheStats holds counters describing how a MemoryCache has been used.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+type lruEntry struct {
+	key  string
+	item CacheItem
+	// used orders entries of different shards by their last use
+	used uint64
+}
+
+// memoryCacheShard keeps its items in least-recently-used order, most recent at the front.
+type memoryCacheShard struct {
+	mutex sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+// MemoryCacheOptions configures a MemoryCache created with NewMemoryCacheWithOptions.
+type MemoryCacheOptions struct {
+	// MaxItems bounds the number of entries across all shards. 0 or less means unbounded.
+	MaxItems int
+	// CleanupInterval is how often expired items are swept. Defaults to a minute.
+	CleanupInterval time.Duration
+	// Context stops the background cleanup when cancelled. Defaults to context.Background.
+	Context context.Context
+}
+
+// MemoryCache is split into shards to reduce lock contention. The size limit
+// applies to the cache as a whole: once it is full, the least recently used
+// entry of any shard is evicted.
 type MemoryCache struct {
-	items map[string]CacheItem
-	mutex sync.RWMutex
+	shards    [memoryCacheShards]*memoryCacheShard
+	maxItems  int
+	size      atomic.Int64
+	clock     atomic.Uint64
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 func NewMemoryCache() *MemoryCache {
+	return NewMemoryCacheWithOptions(MemoryCacheOptions{})
+}
+
+// NewMemoryCacheWithLimit creates a cache that holds at most maxItems entries,
+// evicting the least recently used ones once full. A maxItems of 0 or less
+// means the cache is unbounded.
+func NewMemoryCacheWithLimit(maxItems int) *MemoryCache {
+	return NewMemoryCacheWithOptions(MemoryCacheOptions{MaxItems: maxItems})
+}
+
+// NewMemoryCacheWithOptions creates a cache and starts its background cleanup,
+// which runs until Close is called or opts.Context is cancelled.
+func NewMemoryCacheWithOptions(opts MemoryCacheOptions) *MemoryCache {
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	interval := opts.CleanupInterval
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+
+	ctx, cancel := context.WithCancel(parent)
 	cache := &MemoryCache{
-		items: make(map[string]CacheItem),
+		maxItems: opts.MaxItems,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	for i := range cache.shards {
+		cache.shards[i] = &memoryCacheShard{
+			items: make(map[string]*list.Element),
+			order: list.New(),
+		}
 	}
 
-	go cache.cleanup()
+	go cache.cleanup(ctx, interval)
 
 	return cache
 }
 
+// Close stops the background cleanup and waits for it to exit. The cache stays
+// usable afterwards, but expired items are only dropped when they are read.
+func (c *MemoryCache) Close() {
+	c.cancel()
+	<-c.done
+}
+
+func (c *MemoryCache) shard(key string) *memoryCacheShard {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	return c.shards[hasher.Sum32()%memoryCacheShards]
+}
+
 func (c *MemoryCache) Get(key string) (interface{}, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
 
-	item, exists := c.items[key]
+	element, exists := shard.items[key]
 	if !exists {
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	didExpire := time.Now().After(item.ExpiresAt)
-	if didExpire {
+	entry := element.Value.(*lruEntry)
+	if entry.item.expired(time.Now()) {
+		c.remove(shard, element)
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	return item.Value, true
+	entry.used = c.clock.Add(1)
+	shard.order.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.item.Value, true
 }
 
+// Set stores value for ttl. A ttl of 0 keeps the item until it is evicted or deleted.
 func (c *MemoryCache) Set(key string, value interface{}, ttl time.Duration) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	item := CacheItem{Value: value}
+	if ttl != 0 {
+		item.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	if element, exists := shard.items[key]; exists {
+		entry := element.Value.(*lruEntry)
+		entry.item = item
+		entry.used = c.clock.Add(1)
+		shard.order.MoveToFront(element)
+		shard.mutex.Unlock()
+		return
+	}
+	shard.items[key] = shard.order.PushFront(&lruEntry{key: key, item: item, used: c.clock.Add(1)})
+	c.size.Add(1)
+	shard.mutex.Unlock()
 
-	c.items[key] = CacheItem{
-		Value:     value,
-		ExpiresAt: time.Now().Add(ttl),
+	if c.maxItems > 0 {
+		c.evictOverflow()
 	}
 }
 
+// evictOverflow evicts least recently used entries until the cache is within
+// MaxItems. The shards are locked one at a time, so the oldest entry is chosen
+// from what each shard held when it was looked at.
+func (c *MemoryCache) evictOverflow() {
+	for c.size.Load() > int64(c.maxItems) {
+		var victim *memoryCacheShard
+		var oldest uint64
+		for _, shard := range c.shards {
+			shard.mutex.Lock()
+			if back := shard.order.Back(); back != nil {
+				if used := back.Value.(*lruEntry).used; victim == nil || used < oldest {
+					victim, oldest = shard, used
+				}
+			}
+			shard.mutex.Unlock()
+		}
+		if victim == nil {
+			return
+		}
+
+		victim.mutex.Lock()
+		if back := victim.order.Back(); back != nil && c.size.Load() > int64(c.maxItems) {
+			c.remove(victim, back)
+			c.evictions.Add(1)
+		}
+		victim.mutex.Unlock()
+	}
+}
+
+// remove drops element from shard, whose lock must be held.
+func (c *MemoryCache) remove(shard *memoryCacheShard, element *list.Element) {
+	shard.order.Remove(element)
+	delete(shard.items, element.Value.(*lruEntry).key)
+	c.size.Add(-1)
+}
+
 func (c *MemoryCache) Delete(key string) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if element, exists := shard.items[key]; exists {
+		c.remove(shard, element)
+	}
+}
+
+// Len returns the number of stored items, including expired ones that have not been swept yet.
+func (c *MemoryCache) Len() int {
+	total := 0
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		total += len(shard.items)
+		shard.mutex.Unlock()
+	}
+	return total
+}
 
-	delete(c.items, key)
+// Clear removes every item. Counters are left untouched.
+func (c *MemoryCache) Clear() {
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		c.size.Add(-int64(len(shard.items)))
+		shard.items = make(map[string]*list.Element)
+		shard.order.Init()
+		shard.mutex.Unlock()
+	}
 }
 
-func (c *MemoryCache) cleanup() {
-	ticker := time.NewTicker(time.Minute)
+// Range calls fn for every unexpired item until fn returns false. Each shard is
+// copied before fn is called, so fn may safely use the cache itself.
+func (c *MemoryCache) Range(fn func(key string, value interface{}) bool) {
+	now := time.Now()
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		entries := make([]lruEntry, 0, len(shard.items))
+		for element := shard.order.Front(); element != nil; element = element.Next() {
+			entries = append(entries, *element.Value.(*lruEntry))
+		}
+		shard.mutex.Unlock()
+
+		for _, entry := range entries {
+			if entry.item.expired(now) {
+				continue
+			}
+			if !fn(entry.key, entry.item.Value) {
+				return
+			}
+		}
+	}
+}
+
+func (c *MemoryCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+func (c *MemoryCache) cleanup(ctx context.Context, interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			c.mutex.Lock()
 			now := time.Now()
-			for key, item := range c.items {
-				didExpire := now.After(item.ExpiresAt)
-				if didExpire {
-					delete(c.items, key)
+			for _, shard := range c.shards {
+				shard.mutex.Lock()
+				for _, element := range shard.items {
+					if element.Value.(*lruEntry).item.expired(now) {
+						c.remove(shard, element)
+					}
 				}
+				shard.mutex.Unlock()
 			}
-			c.mutex.Unlock()
 		}
 	}
 }
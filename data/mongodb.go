@@ -28,7 +28,10 @@ type MongoService struct {
 
 func NewMongoService() (*MongoService, error) {
 	service := &MongoService{
-		cache: NewMemoryCache(),
+		cache: NewMemoryCacheWithOptions(MemoryCacheOptions{
+			MaxItems:        config.AppConfig.APIKeyCacheSize,
+			CleanupInterval: time.Duration(config.AppConfig.MemoryCacheCleanupInterval) * time.Second,
+		}),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -37,11 +40,13 @@ func NewMongoService() (*MongoService, error) {
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.AppConfig.MongoDBURI))
 	if err != nil {
 		log.Printf("Failed to connect to MongoDB: %v", err)
+		service.cache.Close()
 		return nil, fmt.Errorf("MongoDB connection failed: %w", err)
 	}
 
 	if err := client.Ping(ctx, nil); err != nil {
 		log.Printf("Failed to ping MongoDB: %v", err)
+		service.cache.Close()
 		return nil, fmt.Errorf("MongoDB connection failed: %w", err)
 	}
 
@@ -96,8 +101,36 @@ func (ms *MongoService) ValidateAPIKey(key string) (*models.APIKey, error) {
 	return &apiKey, nil
 }
 
+// GetWatchlist returns the wallets stored in the watchlist collection. Each document holds a "wallet" field.
+func (ms *MongoService) GetWatchlist() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWatchlist)
+	opts := options.Find().SetProjection(bson.M{"wallet": 1, "_id": 0})
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watchlist: %w", err)
+	}
+
+	var entries []struct {
+		Wallet string `bson:"wallet"`
+	}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode watchlist: %w", err)
+	}
+
+	wallets := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		wallets = append(wallets, entry.Wallet)
+	}
+	return wallets, nil
+}
+
 // Close closes the MongoDB connection
 func (ms *MongoService) Close() error {
+	ms.cache.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"nova-api/config"
+)
+
+// NameRPC is the part of the RPC client used to resolve .sol domains.
+type NameRPC interface {
+	ResolveSOLDomain(domain string) (string, error)
+}
+
+// NameService resolves Solana Name Service .sol domains to the address of
+// their owner. Domains can be transferred, so resolutions are cached for SNSCacheTTL.
+type NameService struct {
+	rpcClient NameRPC
+	cache     BalanceCache
+}
+
+func NewNameService(rpcClient NameRPC, cache BalanceCache) *NameService {
+	return &NameService{
+		rpcClient: rpcClient,
+		cache:     cache,
+	}
+}
+
+// Resolve returns the owner address of domain, such as "alice.sol".
+func (s *NameService) Resolve(domain string) (string, error) {
+	domain = strings.ToLower(domain)
+	key := "sns:" + domain
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		return value, nil
+	}
+
+	owner, err := s.rpcClient.ResolveSOLDomain(domain)
+	if err != nil {
+		return "", err
+	}
+
+	if config.AppConfig.SNSCacheTTL > 0 {
+		ttl := time.Duration(config.AppConfig.SNSCacheTTL) * time.Second
+		if err := s.cache.Set(key, owner, ttl); err != nil {
+			log.Printf("Failed to cache resolution of %s: %v", domain, err)
+		}
+	}
+	return owner, nil
+}
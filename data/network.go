@@ -0,0 +1,194 @@
+package data
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// networkPerformanceSamples is how many of the per-minute performance samples TPS is averaged over.
+const networkPerformanceSamples = 5
+
+// NetworkRPC is the part of the RPC client used to read the cluster's status.
+type NetworkRPC interface {
+	GetEpochInfo() (*rpc.EpochInfo, error)
+	GetPerformanceSamples(limit int) ([]rpc.PerformanceSample, error)
+	GetPrioritizationFees(accounts []string) ([]uint64, error)
+}
+
+// NetworkService reads the cluster's status. The status is the same for every
+// caller, so it is cached in the shared cache for NetworkCacheTTL and only one
+// refresh per cache key runs at a time: callers arriving during a refresh of
+// the same key wait for its result instead of calling the node themselves,
+// while refreshes of other keys go ahead.
+type NetworkService struct {
+	rpcClient NetworkRPC
+	cache     BalanceCache
+	refresh   singleflight.Group
+}
+
+func NewNetworkService(rpcClient NetworkRPC, cache BalanceCache) *NetworkService {
+	return &NetworkService{
+		rpcClient: rpcClient,
+		cache:     cache,
+	}
+}
+
+// Status returns the network status with priority fees scoped to accounts,
+// or across all transactions when accounts is empty.
+func (s *NetworkService) Status(accounts []string) (*models.NetworkStatus, error) {
+	accounts = sortedUnique(accounts)
+	key := "network"
+	if len(accounts) > 0 {
+		key += ":" + strings.Join(accounts, ",")
+	}
+	if status := s.cached(key); status != nil {
+		return status, nil
+	}
+
+	result, err, _ := s.refresh.Do(key, func() (interface{}, error) {
+		// A refresh of the key may have finished since the cache was read
+		if status := s.cached(key); status != nil {
+			return status, nil
+		}
+
+		status, err := s.read(accounts)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.AppConfig.NetworkCacheTTL > 0 {
+			if value, err := json.Marshal(status); err == nil {
+				if err := s.cache.Set(key, string(value), time.Duration(config.AppConfig.NetworkCacheTTL)*time.Second); err != nil {
+					log.Printf("Failed to cache network status: %v", err)
+				}
+			}
+		}
+		return status, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.NetworkStatus), nil
+}
+
+func (s *NetworkService) cached(key string) *models.NetworkStatus {
+	value, err := s.cache.Get(key)
+	if err != nil || value == "" {
+		return nil
+	}
+	var status models.NetworkStatus
+	if err := json.Unmarshal([]byte(value), &status); err != nil {
+		return nil
+	}
+	return &status
+}
+
+func (s *NetworkService) read(accounts []string) (*models.NetworkStatus, error) {
+	info, err := s.rpcClient.GetEpochInfo()
+	if err != nil {
+		return nil, err
+	}
+	samples, err := s.rpcClient.GetPerformanceSamples(networkPerformanceSamples)
+	if err != nil {
+		return nil, err
+	}
+	fees, err := s.rpcClient.GetPrioritizationFees(accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.NetworkStatus{
+		Slot:        info.Slot,
+		BlockHeight: info.BlockHeight,
+		Epoch: models.NetworkEpoch{
+			Epoch:        info.Epoch,
+			SlotIndex:    info.SlotIndex,
+			SlotsInEpoch: info.SlotsInEpoch,
+		},
+		PriorityFees: PriorityFeePercentiles(fees),
+		UpdatedAt:    time.Now().UTC(),
+	}
+	if info.SlotsInEpoch > 0 {
+		status.Epoch.Progress = float64(info.SlotIndex) / float64(info.SlotsInEpoch)
+	}
+	status.PriorityFees.Accounts = accounts
+	status.TPS, status.NonVoteTPS = transactionsPerSecond(samples)
+	return status, nil
+}
+
+// transactionsPerSecond averages the samples. The non-vote rate is only
+// returned when every sample reports it.
+func transactionsPerSecond(samples []rpc.PerformanceSample) (float64, *float64) {
+	var transactions, nonVote, seconds uint64
+	reportsNonVote := len(samples) > 0
+	for _, sample := range samples {
+		transactions += sample.NumTransactions
+		seconds += uint64(sample.SamplePeriodSecs)
+		if sample.NumNonVoteTransactions == nil {
+			reportsNonVote = false
+		} else {
+			nonVote += *sample.NumNonVoteTransactions
+		}
+	}
+	if seconds == 0 {
+		return 0, nil
+	}
+
+	tps := float64(transactions) / float64(seconds)
+	if !reportsNonVote {
+		return tps, nil
+	}
+	nonVoteTPS := float64(nonVote) / float64(seconds)
+	return tps, &nonVoteTPS
+}
+
+// PriorityFeePercentiles summarizes the fees of recent slots using the nearest-rank method.
+func PriorityFeePercentiles(fees []uint64) models.PriorityFees {
+	percentiles := models.PriorityFees{Slots: len(fees)}
+	if len(fees) == 0 {
+		return percentiles
+	}
+
+	sorted := append([]uint64(nil), fees...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(percentile int) uint64 {
+		index := (percentile*len(sorted)+99)/100 - 1
+		return sorted[max(index, 0)]
+	}
+
+	percentiles.Min = sorted[0]
+	percentiles.P25 = rank(25)
+	percentiles.P50 = rank(50)
+	percentiles.P75 = rank(75)
+	percentiles.P90 = rank(90)
+	percentiles.P99 = rank(99)
+	percentiles.Max = sorted[len(sorted)-1]
+	return percentiles
+}
+
+// sortedUnique returns values sorted without duplicates, so the same set of
+// values always produces the same cache key.
+func sortedUnique(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	unique := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,227 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+// maxAssetPages bounds the getAssetsByOwner pages read for one wallet.
+const maxAssetPages = 10
+
+// NFTRPC is the part of the RPC client used to list a wallet's NFTs.
+type NFTRPC interface {
+	GetTokenAccounts(walletAddress string) ([]rpc.TokenHolding, error)
+	GetNFTMetadata(mints []string) (map[string]*rpc.NFT, error)
+	GetAssetsByOwner(ownerAddress string, page, limit int) (*rpc.AssetPage, error)
+}
+
+// nftList is the cached list of a wallet's NFTs
+type nftList struct {
+	NFTs            []models.NFT `json:"nfts"`
+	CompressedError string       `json:"compressed_error,omitempty"`
+}
+
+// NFTService lists the NFTs of a wallet: token accounts holding exactly one
+// indivisible token, described by their Metaplex metadata, and compressed NFTs
+// from the DAS API when the RPC endpoint implements it. The whole list is
+// cached for NFTCacheTTL and paged from the cache.
+type NFTService struct {
+	rpcClient NFTRPC
+	cache     BalanceCache
+	// dasUnsupported is set once the endpoint turned out not to implement the DAS API
+	dasUnsupported atomic.Bool
+}
+
+func NewNFTService(rpcClient NFTRPC, cache BalanceCache) *NFTService {
+	return &NFTService{
+		rpcClient: rpcClient,
+		cache:     cache,
+	}
+}
+
+// NFTs returns a page (starting at 1) of the NFTs of walletAddress, ordered by
+// collection, name and mint. With collections, only NFTs of those verified
+// collections are returned.
+func (s *NFTService) NFTs(walletAddress string, collections []string, page, limit int) (*models.NFTPage, error) {
+	list, err := s.list(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	nfts := list.NFTs
+	if len(collections) > 0 {
+		wanted := make(map[string]bool, len(collections))
+		for _, collection := range collections {
+			wanted[collection] = true
+		}
+		nfts = []models.NFT{}
+		for _, nft := range list.NFTs {
+			if nft.CollectionVerified && wanted[nft.Collection] {
+				nfts = append(nfts, nft)
+			}
+		}
+	}
+
+	// Pages past the end are compared before multiplying, so a huge page cannot overflow
+	start := len(nfts)
+	if page > 0 && limit > 0 && page-1 <= len(nfts)/limit {
+		start = (page - 1) * limit
+	}
+	end := start + min(limit, len(nfts)-start)
+	return &models.NFTPage{
+		NFTs:            nfts[start:end],
+		Page:            page,
+		Limit:           limit,
+		Total:           len(nfts),
+		CompressedError: list.CompressedError,
+	}, nil
+}
+
+// Count counts the NFTs of walletAddress from the same cached list as NFTs.
+func (s *NFTService) Count(walletAddress string) (*models.NFTCount, error) {
+	list, err := s.list(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	count := &models.NFTCount{Total: len(list.NFTs), CompressedError: list.CompressedError}
+	for _, nft := range list.NFTs {
+		if nft.Compressed {
+			count.Compressed++
+		}
+	}
+	return count, nil
+}
+
+func (s *NFTService) list(walletAddress string) (*nftList, error) {
+	key := "nfts:" + walletAddress
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		var list nftList
+		if err := json.Unmarshal([]byte(value), &list); err == nil {
+			return &list, nil
+		}
+	}
+
+	nfts, err := s.standardNFTs(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+	list := &nftList{NFTs: nfts}
+
+	// Compressed NFTs are listed best-effort, standard NFTs are still worth returning without them
+	compressed, err := s.compressedNFTs(walletAddress)
+	if err != nil {
+		log.Printf("Failed to list compressed NFTs of wallet %s: %v", walletAddress, err)
+		list.CompressedError = err.Error()
+	}
+	list.NFTs = append(list.NFTs, compressed...)
+
+	sort.Slice(list.NFTs, func(i, j int) bool {
+		a, b := list.NFTs[i], list.NFTs[j]
+		if a.Collection != b.Collection {
+			return a.Collection < b.Collection
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Mint < b.Mint
+	})
+
+	// A transient DAS failure is not cached, an endpoint without DAS will not gain it
+	if config.AppConfig.NFTCacheTTL > 0 && (err == nil || errors.Is(err, rpc.ErrDASUnsupported)) {
+		if value, err := json.Marshal(list); err == nil {
+			ttl := time.Duration(config.AppConfig.NFTCacheTTL) * time.Second
+			if err := s.cache.Set(key, string(value), ttl); err != nil {
+				log.Printf("Failed to cache NFTs of wallet %s: %v", walletAddress, err)
+			}
+		}
+	}
+	return list, nil
+}
+
+func (s *NFTService) standardNFTs(walletAddress string) ([]models.NFT, error) {
+	holdings, err := s.rpcClient.GetTokenAccounts(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	mints := []string{}
+	seen := map[string]bool{}
+	for _, holding := range holdings {
+		if holding.Amount == 1 && holding.Decimals == 0 && !seen[holding.Mint] {
+			seen[holding.Mint] = true
+			mints = append(mints, holding.Mint)
+		}
+	}
+	if len(mints) == 0 {
+		return []models.NFT{}, nil
+	}
+
+	metadata, err := s.rpcClient.GetNFTMetadata(mints)
+	if err != nil {
+		return nil, err
+	}
+
+	nfts := make([]models.NFT, 0, len(mints))
+	for _, mint := range mints {
+		nft := models.NFT{Mint: mint}
+		if described, found := metadata[mint]; found {
+			if described.Fungible {
+				continue
+			}
+			nft = toNFT(*described)
+			nft.Mint = mint
+		}
+		nfts = append(nfts, nft)
+	}
+	return nfts, nil
+}
+
+func (s *NFTService) compressedNFTs(walletAddress string) ([]models.NFT, error) {
+	if s.dasUnsupported.Load() {
+		return nil, rpc.ErrDASUnsupported
+	}
+
+	nfts := []models.NFT{}
+	for page := 1; page <= maxAssetPages; page++ {
+		assets, err := s.rpcClient.GetAssetsByOwner(walletAddress, page, rpc.MaxAssetsPerPage)
+		if errors.Is(err, rpc.ErrDASUnsupported) {
+			s.dasUnsupported.Store(true)
+		}
+		if err != nil {
+			return nfts, err
+		}
+
+		// Standard NFTs are listed from token accounts
+		for _, asset := range assets.Items {
+			if asset.Compressed && !asset.Fungible {
+				nfts = append(nfts, toNFT(asset))
+			}
+		}
+		if assets.Returned < rpc.MaxAssetsPerPage {
+			return nfts, nil
+		}
+	}
+	log.Printf("Wallet %s owns more than %d assets, listing only the first ones", walletAddress, maxAssetPages*rpc.MaxAssetsPerPage)
+	return nfts, nil
+}
+
+func toNFT(nft rpc.NFT) models.NFT {
+	return models.NFT{
+		Mint:               nft.Mint,
+		Name:               nft.Name,
+		Symbol:             nft.Symbol,
+		MetadataURI:        nft.URI,
+		Collection:         nft.Collection,
+		CollectionVerified: nft.CollectionVerified,
+		Compressed:         nft.Compressed,
+	}
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// portfolioConcurrency bounds the wallets of one portfolio read at the same time.
+const portfolioConcurrency = 8
+
+// PortfolioService combines the SOL, token, stake and NFT services into one
+// view per wallet. Each section is read and cached by its own service, so a
+// failing or expired section does not affect the others.
+type PortfolioService struct {
+	balances *BalanceService
+	tokens   *TokenService
+	stakes   *StakeService
+	nfts     *NFTService
+}
+
+func NewPortfolioService(balances *BalanceService, tokens *TokenService, stakes *StakeService, nfts *NFTService) *PortfolioService {
+	return &PortfolioService{
+		balances: balances,
+		tokens:   tokens,
+		stakes:   stakes,
+		nfts:     nfts,
+	}
+}
+
+// Portfolio returns every wallet's sections, in order, and their totals.
+func (s *PortfolioService) Portfolio(wallets []string) *models.Portfolio {
+	portfolios := make([]models.WalletPortfolio, len(wallets))
+	slots := make(chan struct{}, portfolioConcurrency)
+	var wg sync.WaitGroup
+	for i, wallet := range wallets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, wallet string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			portfolios[i] = s.walletPortfolio(wallet)
+		}(i, wallet)
+	}
+	wg.Wait()
+	s.addStaked(portfolios)
+
+	return &models.Portfolio{Wallets: portfolios, Totals: PortfolioTotals(portfolios)}
+}
+
+func (s *PortfolioService) walletPortfolio(wallet string) models.WalletPortfolio {
+	portfolio := models.WalletPortfolio{Wallet: wallet}
+	if _, err := solana.PublicKeyFromBase58(wallet); err != nil {
+		portfolio.Error = fmt.Sprintf("%v: %v", rpc.ErrInvalidAddress, err)
+		return portfolio
+	}
+
+	if balance, err := s.balances.GetBalance(wallet); err != nil {
+		portfolio.SOLError = err.Error()
+	} else {
+		portfolio.SOL = &balance
+	}
+
+	if tokens, err := s.tokens.Holdings(wallet); err != nil {
+		portfolio.TokensError = err.Error()
+	} else {
+		portfolio.Tokens = tokens
+	}
+
+	if count, err := s.nfts.Count(wallet); err != nil {
+		portfolio.NFTsError = err.Error()
+	} else {
+		portfolio.NFTs = count
+	}
+	return portfolio
+}
+
+// addStaked reads the stake section of every valid wallet in one Summaries call,
+// so the wallets of a portfolio share its lookup budget.
+func (s *PortfolioService) addStaked(portfolios []models.WalletPortfolio) {
+	indexes := []int{}
+	wallets := []string{}
+	liquid := []float64{}
+	for i, portfolio := range portfolios {
+		if portfolio.Error != "" {
+			continue
+		}
+		// Without the SOL section the stake total only counts staked SOL
+		balance := 0.0
+		if portfolio.SOL != nil {
+			balance = *portfolio.SOL
+		}
+		indexes = append(indexes, i)
+		wallets = append(wallets, portfolio.Wallet)
+		liquid = append(liquid, balance)
+	}
+
+	summaries, errs := s.stakes.Summaries(wallets, liquid)
+	for j, i := range indexes {
+		if errs[j] != nil {
+			portfolios[i].StakedError = errs[j].Error()
+			continue
+		}
+		portfolios[i].Staked = summaries[j]
+	}
+}
+
+// PortfolioTotals adds up the sections that could be read, largest token first.
+func PortfolioTotals(portfolios []models.WalletPortfolio) models.PortfolioTotals {
+	totals := models.PortfolioTotals{Tokens: []models.TokenBalance{}}
+	tokens := map[string]int{}
+	for _, portfolio := range portfolios {
+		if portfolio.Error != "" || portfolio.SOLError != "" || portfolio.TokensError != "" ||
+			portfolio.StakedError != "" || portfolio.NFTsError != "" {
+			totals.Partial = true
+		}
+
+		if portfolio.SOL != nil {
+			totals.SOL += *portfolio.SOL
+		}
+		if portfolio.Staked != nil {
+			totals.Staked += portfolio.Staked.Staked
+		}
+		if portfolio.NFTs != nil {
+			totals.NFTs += portfolio.NFTs.Total
+		}
+		for _, token := range portfolio.Tokens {
+			if i, seen := tokens[token.Mint]; seen {
+				totals.Tokens[i].Amount += token.Amount
+				continue
+			}
+			tokens[token.Mint] = len(totals.Tokens)
+			token.USD = nil
+			totals.Tokens = append(totals.Tokens, token)
+		}
+	}
+	totals.Total = totals.SOL + totals.Staked
+
+	sort.Slice(totals.Tokens, func(i, j int) bool {
+		if totals.Tokens[i].Amount != totals.Tokens[j].Amount {
+			return totals.Tokens[i].Amount > totals.Tokens[j].Amount
+		}
+		return totals.Tokens[i].Mint < totals.Tokens[j].Mint
+	})
+	return totals
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"nova-api/config"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	// NativeMint is the wrapped SOL mint, used to look up the SOL price.
+	NativeMint = "So11111111111111111111111111111111111111112"
+
+	// defaultSOLPriceAccount is the Pyth SOL/USD price feed account, used when PRICE_FEEDS is empty
+	defaultSOLPriceAccount = "7UVimffxr9ow1uXYxsr4LHAcV58mLzhmwaeKvJ1pjLiE"
+)
+
+var (
+	// ErrNoPriceFeed is returned for mints without a configured price account.
+	ErrNoPriceFeed = errors.New("no price feed for mint")
+	// ErrPriceStale is returned when the oracle has not published within PriceMaxAge.
+	ErrPriceStale = errors.New("oracle price is stale")
+	// ErrPriceUncertain is returned when the oracle does not consider its price usable or its confidence interval is too wide.
+	ErrPriceUncertain = errors.New("oracle price is too uncertain")
+)
+
+// PriceRPC is the part of the RPC client used to read oracle prices.
+type PriceRPC interface {
+	GetPythPrice(priceAccount string) (*rpc.OraclePrice, error)
+}
+
+// PriceService values amounts in USD from Pyth price accounts. Decoded prices
+// are cached for PriceCacheTTL; the staleness and confidence checks run on
+// every read, so a cached price can still be rejected as it ages.
+type PriceService struct {
+	rpcClient PriceRPC
+	cache     BalanceCache
+	feeds     map[string]string
+}
+
+func NewPriceService(rpcClient PriceRPC, cache BalanceCache) *PriceService {
+	feeds := map[string]string{}
+	for _, entry := range config.AppConfig.PriceFeeds {
+		mint, account, found := strings.Cut(entry, ":")
+		if !found {
+			log.Printf("Ignoring price feed %q, expected mint:price_account", entry)
+			continue
+		}
+		if _, err := solana.PublicKeyFromBase58(account); err != nil {
+			log.Printf("Ignoring price feed %q: invalid price account: %v", entry, err)
+			continue
+		}
+		feeds[mint] = account
+	}
+	if len(feeds) == 0 {
+		feeds[NativeMint] = defaultSOLPriceAccount
+	}
+
+	return &PriceService{
+		rpcClient: rpcClient,
+		cache:     cache,
+		feeds:     feeds,
+	}
+}
+
+// USDPrice returns the USD price of one whole token of mint. Use NativeMint for SOL.
+func (s *PriceService) USDPrice(mint string) (float64, error) {
+	account, exists := s.feeds[mint]
+	if !exists {
+		return 0, fmt.Errorf("%w %s", ErrNoPriceFeed, mint)
+	}
+
+	price, err := s.oraclePrice(account)
+	if err != nil {
+		return 0, err
+	}
+
+	if !price.Tradable || price.Price <= 0 {
+		return 0, fmt.Errorf("%w: price account %s is not trading", ErrPriceUncertain, account)
+	}
+	maxAge := time.Duration(config.AppConfig.PriceMaxAge) * time.Second
+	if age := time.Since(price.PublishTime); age > maxAge {
+		return 0, fmt.Errorf("%w: published %s ago", ErrPriceStale, age.Round(time.Second))
+	}
+	if price.Confidence*10_000 > price.Price*float64(config.AppConfig.PriceMaxConfidenceBps) {
+		return 0, fmt.Errorf("%w: ±%g on %g", ErrPriceUncertain, price.Confidence, price.Price)
+	}
+	return price.Price, nil
+}
+
+func (s *PriceService) oraclePrice(account string) (*rpc.OraclePrice, error) {
+	key := "price:" + account
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		var price rpc.OraclePrice
+		if err := json.Unmarshal([]byte(value), &price); err == nil {
+			return &price, nil
+		}
+	}
+
+	price, err := s.rpcClient.GetPythPrice(account)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.AppConfig.PriceCacheTTL > 0 {
+		if value, err := json.Marshal(price); err == nil {
+			if err := s.cache.Set(key, string(value), time.Duration(config.AppConfig.PriceCacheTTL)*time.Second); err != nil {
+				log.Printf("Failed to cache price account %s: %v", account, err)
+			}
+		}
+	}
+	return price, nil
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+// rentMinimumTTL keeps a minimum a little longer than an epoch lasts. Minimums
+// are keyed by epoch, so a new epoch reads them again regardless.
+const rentMinimumTTL = 72 * time.Hour
+
+// RentRPC is the part of the RPC client used to read rent-exempt minimums.
+type RentRPC interface {
+	AccountRPC
+	GetEpoch() (uint64, error)
+	GetRentExemptMinimum(dataSize uint64) (uint64, error)
+}
+
+// RentService reads rent-exempt minimums from the node. Rent parameters can
+// only change at epoch boundaries, so minimums are cached per epoch and data
+// size; the current epoch itself is cached for EpochCacheTTL.
+type RentService struct {
+	rpcClient RentRPC
+	cache     BalanceCache
+	accounts  *AccountService
+}
+
+func NewRentService(rpcClient RentRPC, cache BalanceCache) *RentService {
+	return &RentService{
+		rpcClient: rpcClient,
+		cache:     cache,
+		accounts:  NewAccountService(rpcClient, cache),
+	}
+}
+
+// Minimums returns the rent-exempt minimum of each data size, in order.
+func (s *RentService) Minimums(sizes []uint64) (*models.RentExemptions, error) {
+	epoch, err := s.epoch()
+	if err != nil {
+		return nil, err
+	}
+
+	exemptions := &models.RentExemptions{Epoch: epoch, Minimums: make([]models.RentExemption, 0, len(sizes))}
+	for _, size := range sizes {
+		lamports, err := s.minimum(epoch, size)
+		if err != nil {
+			return nil, err
+		}
+		exemptions.Minimums = append(exemptions.Minimums, models.RentExemption{
+			DataSize: size,
+			Lamports: lamports,
+			SOL:      rpc.LamportsToSOL(lamports),
+		})
+	}
+	return exemptions, nil
+}
+
+// RentStatus reports for each address, in order, whether the account holds the
+// rent-exempt minimum for its data length. Entries are nil for addresses that
+// cannot be read, such as invalid ones.
+func (s *RentService) RentStatus(addresses []string) ([]*models.RentStatus, error) {
+	// Only the data length is needed, so no data is transferred
+	accounts, err := s.accounts.GetAccounts(addresses, rpc.EncodingBase64, &models.DataSlice{})
+	if err != nil {
+		return nil, err
+	}
+	epoch, err := s.epoch()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*models.RentStatus, len(accounts))
+	for i, account := range accounts {
+		// Missing accounts are reported with no data and no lamports
+		if account.Error != "" && account.Error != rpc.ErrAccountNotFound.Error() {
+			continue
+		}
+		minimum, err := s.minimum(epoch, account.Space)
+		if err != nil {
+			return nil, err
+		}
+		statuses[i] = &models.RentStatus{
+			DataSize:   account.Space,
+			Minimum:    rpc.LamportsToSOL(minimum),
+			RentExempt: account.Lamports >= minimum && account.Lamports > 0,
+		}
+	}
+	return statuses, nil
+}
+
+func (s *RentService) epoch() (uint64, error) {
+	if value, err := s.cache.Get("epoch"); err == nil && value != "" {
+		if epoch, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return epoch, nil
+		}
+	}
+
+	epoch, err := s.rpcClient.GetEpoch()
+	if err != nil {
+		return 0, err
+	}
+
+	if config.AppConfig.EpochCacheTTL > 0 {
+		ttl := time.Duration(config.AppConfig.EpochCacheTTL) * time.Second
+		if err := s.cache.Set("epoch", strconv.FormatUint(epoch, 10), ttl); err != nil {
+			log.Printf("Failed to cache epoch: %v", err)
+		}
+	}
+	return epoch, nil
+}
+
+func (s *RentService) minimum(epoch, size uint64) (uint64, error) {
+	key := fmt.Sprintf("rent:%d:%d", epoch, size)
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		if lamports, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return lamports, nil
+		}
+	}
+
+	lamports, err := s.rpcClient.GetRentExemptMinimum(size)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.cache.Set(key, strconv.FormatUint(lamports, 10), rentMinimumTTL); err != nil {
+		log.Printf("Failed to cache rent-exempt minimum for %d bytes: %v", size, err)
+	}
+	return lamports, nil
+}
@@ -1,24 +1,44 @@
 package data
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"time"
 
+	"nova-api/config"
 	"nova-api/rpc"
 )
 
+// accountNotFoundMarker is stored in the negative cache for wallets that do not exist on chain.
+// Any other stored value is the message of a recent upstream error.
+const accountNotFoundMarker = "account-not-found"
+
+// maxTrackedWallets bounds how many distinct wallets are counted for learning hot wallets.
+const maxTrackedWallets = 10_000
+
+// BalanceRPC fetches balances from the chain. It is satisfied by *rpc.SolanaRPC.
+type BalanceRPC interface {
+	GetBalance(walletAddress string) (float64, error)
+}
+
 type BalanceService struct {
-	rpcClient     *rpc.SolanaRPC
-	cacheService  *CacheService
+	rpcClient     BalanceRPC
+	cache         BalanceCache
 	walletMutexes map[string]*sync.Mutex
 	mutexMapLock  sync.RWMutex
+	requestCounts map[string]int
+	countsLock    sync.Mutex
 }
 
-func NewBalanceService(rpcEndpoint, dragonflyAddr, dragonflyPassword string, dragonflyDB int) *BalanceService {
+func NewBalanceService(rpcClient BalanceRPC, cache BalanceCache) *BalanceService {
 	return &BalanceService{
-		rpcClient:     rpc.NewSolanaRPC(rpcEndpoint),
-		cacheService:  NewCacheService(dragonflyAddr, dragonflyPassword, dragonflyDB),
+		rpcClient:     rpcClient,
+		cache:         cache,
 		walletMutexes: make(map[string]*sync.Mutex),
+		requestCounts: make(map[string]int),
 	}
 }
 
@@ -44,31 +64,129 @@ func (bs *BalanceService) getWalletMutex(walletAddress string) *sync.Mutex {
 }
 
 func (bs *BalanceService) GetBalance(walletAddress string) (float64, error) {
+	if config.AppConfig.CacheWarmerLearnTopN > 0 {
+		bs.recordRequest(walletAddress)
+	}
+
 	walletMutex := bs.getWalletMutex(walletAddress)
 	walletMutex.Lock()
 	defer walletMutex.Unlock()
 
-	if balance, found, err := bs.cacheService.GetBalance(walletAddress); err != nil {
+	if balance, found, err := bs.cache.GetBalance(walletAddress); err != nil {
 		log.Printf("Cache error for wallet %s: %v", walletAddress, err)
 	} else if found {
 		return balance, nil
 	}
 
+	if reason, err := bs.cache.Get(negativeCacheKey(walletAddress)); err != nil {
+		log.Printf("Negative cache error for wallet %s: %v", walletAddress, err)
+	} else if reason == accountNotFoundMarker {
+		return 0, nil
+	} else if reason != "" {
+		return 0, errors.New(reason)
+	}
+
+	balance, err := bs.rpcClient.GetBalance(walletAddress)
+	if errors.Is(err, rpc.ErrAccountNotFound) {
+		bs.setNegative(walletAddress, accountNotFoundMarker, config.AppConfig.BalanceNotFoundCacheTTL)
+		return 0, nil
+	}
+	if err != nil {
+		// Invalid addresses never reach the RPC, so there is nothing to save by caching them
+		if !errors.Is(err, rpc.ErrInvalidAddress) {
+			bs.setNegative(walletAddress, err.Error(), config.AppConfig.BalanceErrorCacheTTL)
+		}
+		return 0, err
+	}
+
+	if err := bs.cache.SetBalance(walletAddress, balance); err != nil {
+		log.Printf("Failed to cache balance for wallet %s: %v", walletAddress, err)
+	}
+
+	return balance, nil
+}
+
+// RefreshBalance reads a wallet's balance from the RPC and stores it in the cache, skipping any cached value.
+func (bs *BalanceService) RefreshBalance(walletAddress string) (float64, error) {
+	walletMutex := bs.getWalletMutex(walletAddress)
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
 	balance, err := bs.rpcClient.GetBalance(walletAddress)
+	if errors.Is(err, rpc.ErrAccountNotFound) {
+		// The cached balance is read before the negative cache, so a wallet that
+		// was drained and closed needs its balance overwritten with 0
+		balance, err = 0, nil
+	}
 	if err != nil {
 		return 0, err
 	}
 
-	if err := bs.cacheService.SetBalance(walletAddress, balance); err != nil {
+	// The read is still good when it cannot be cached
+	if err := bs.cache.SetBalance(walletAddress, balance); err != nil {
 		log.Printf("Failed to cache balance for wallet %s: %v", walletAddress, err)
 	}
 
 	return balance, nil
 }
 
+func (bs *BalanceService) recordRequest(walletAddress string) {
+	bs.countsLock.Lock()
+	defer bs.countsLock.Unlock()
+
+	if _, tracked := bs.requestCounts[walletAddress]; !tracked && len(bs.requestCounts) >= maxTrackedWallets {
+		return
+	}
+	bs.requestCounts[walletAddress]++
+}
+
+// TopRequestedWallets returns up to n of the most requested wallets. Counts are
+// halved on every call so wallets that stop being requested fall out over time.
+func (bs *BalanceService) TopRequestedWallets(n int) []string {
+	bs.countsLock.Lock()
+	defer bs.countsLock.Unlock()
+
+	wallets := make([]string, 0, len(bs.requestCounts))
+	for wallet := range bs.requestCounts {
+		wallets = append(wallets, wallet)
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return bs.requestCounts[wallets[i]] > bs.requestCounts[wallets[j]]
+	})
+	if len(wallets) > n {
+		wallets = wallets[:n]
+	}
+
+	for wallet, count := range bs.requestCounts {
+		if count/2 == 0 {
+			delete(bs.requestCounts, wallet)
+		} else {
+			bs.requestCounts[wallet] = count / 2
+		}
+	}
+
+	return wallets
+}
+
+func negativeCacheKey(walletAddress string) string {
+	return fmt.Sprintf("balance:negative:%s", walletAddress)
+}
+
+// setNegative remembers that a wallet could not be resolved to a balance. A ttlSeconds of 0 or less disables it.
+func (bs *BalanceService) setNegative(walletAddress, reason string, ttlSeconds int) {
+	if ttlSeconds <= 0 {
+		return
+	}
+
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if err := bs.cache.Set(negativeCacheKey(walletAddress), reason, ttl); err != nil {
+		log.Printf("Failed to negative cache wallet %s: %v", walletAddress, err)
+	}
+}
+
 func (bs *BalanceService) Close() error {
-	return bs.cacheService.Close()
+	return bs.cache.Close()
 }
 func (bs *BalanceService) Ping() error {
-	return bs.cacheService.Ping()
+	return bs.cache.Ping()
 }
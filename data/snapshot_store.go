@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+// SnapshotStore persists balance snapshots and reads them back in time order
+type SnapshotStore interface {
+	SaveSnapshots(snapshots []models.BalanceSnapshot) error
+	BalanceHistory(walletAddress string, from, to time.Time) ([]models.BalanceSnapshot, error)
+}
+
+// EnsureSnapshotCollection creates the snapshot collection as a time-series
+// collection keyed on the wallet. It is a no-op when the collection already exists.
+func (ms *MongoService) EnsureSnapshotCollection() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	timeSeries := options.TimeSeries().SetTimeField("time").SetMetaField("wallet").SetGranularity("minutes")
+	opts := options.CreateCollection().SetTimeSeriesOptions(timeSeries)
+	if days := config.AppConfig.SnapshotRetentionDays; days > 0 {
+		opts.SetExpireAfterSeconds(int64(days) * 24 * 60 * 60)
+	}
+
+	err := ms.database.CreateCollection(ctx, config.AppConfig.MongoDBCollectionBalanceSnapshots, opts)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Name == "NamespaceExists" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot collection: %w", err)
+	}
+	return nil
+}
+
+func (ms *MongoService) SaveSnapshots(snapshots []models.BalanceSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		documents = append(documents, snapshot)
+	}
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionBalanceSnapshots)
+	if _, err := collection.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to save balance snapshots: %w", err)
+	}
+	return nil
+}
+
+// BalanceHistory returns the snapshots of a wallet in [from, to), oldest first
+func (ms *MongoService) BalanceHistory(walletAddress string, from, to time.Time) ([]models.BalanceSnapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"wallet": walletAddress,
+		"time":   bson.M{"$gte": from, "$lt": to},
+	}
+	opts := options.Find().SetSort(bson.M{"time": 1}).SetProjection(bson.M{"_id": 0})
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionBalanceSnapshots)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load balance history: %w", err)
+	}
+
+	snapshots := []models.BalanceSnapshot{}
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to decode balance history: %w", err)
+	}
+	return snapshots, nil
+}
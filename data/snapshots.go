@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+const (
+	HistoryAggregateLast   = "last"
+	HistoryAggregateMinMax = "minmax"
+
+	snapshotLeaseName = "snapshots"
+)
+
+// BalanceRefresher reads a wallet's balance from the RPC, bypassing any cached value.
+type BalanceRefresher interface {
+	RefreshBalance(walletAddress string) (float64, error)
+}
+
+// Snapshotter records the balances of watchlisted wallets at a fixed interval
+// so their history can be queried later, for example for end-of-day reconciliation.
+// With several replicas only the one holding the snapshots lease records them.
+// The lease lasts one and a half intervals, so the holder keeps it as long as it
+// snapshots on time and another replica takes over once it stops.
+type Snapshotter struct {
+	balances      BalanceRefresher
+	store         SnapshotStore
+	leases        LeaseStore
+	holder        string
+	leaseTTL      time.Duration
+	watchlist     WatchlistProvider
+	staticWallets []string
+	interval      time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSnapshotter creates a snapshotter from config. watchlist may be nil when wallets only come from config.
+func NewSnapshotter(balances BalanceRefresher, store SnapshotStore, leases LeaseStore, watchlist WatchlistProvider) *Snapshotter {
+	interval := time.Duration(config.AppConfig.SnapshotInterval) * time.Second
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	return &Snapshotter{
+		balances:      balances,
+		store:         store,
+		leases:        leases,
+		holder:        newInstanceID(),
+		leaseTTL:      interval * 3 / 2,
+		watchlist:     watchlist,
+		staticWallets: config.AppConfig.WatchlistWallets,
+		interval:      interval,
+	}
+}
+
+// Start takes a snapshot right away and then every interval until ctx is cancelled or Close is called.
+func (s *Snapshotter) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.Snapshot()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Snapshotter) Close() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// Snapshot records the current balance of every tracked wallet and returns how
+// many were saved. Balances are read from the RPC, so a snapshot is never an
+// older cached value and does not count as a request for the cache warmer.
+// Nothing is recorded while another replica holds the snapshots lease.
+func (s *Snapshotter) Snapshot() int {
+	leader, err := s.leases.AcquireLease(snapshotLeaseName, s.holder, s.leaseTTL)
+	if err != nil {
+		// Skip rather than risk a second replica recording the same snapshot
+		log.Printf("Snapshotter failed to renew its lease: %v", err)
+		return 0
+	}
+	if !leader {
+		return 0
+	}
+
+	now := time.Now().UTC()
+
+	var snapshots []models.BalanceSnapshot
+	for _, wallet := range s.wallets() {
+		balance, err := s.balances.RefreshBalance(wallet)
+		if err != nil {
+			log.Printf("Snapshotter failed to get balance of wallet %s: %v", wallet, err)
+			continue
+		}
+		snapshots = append(snapshots, models.BalanceSnapshot{Wallet: wallet, Balance: balance, Time: now})
+	}
+
+	if err := s.store.SaveSnapshots(snapshots); err != nil {
+		log.Printf("Snapshotter failed to save snapshots: %v", err)
+		return 0
+	}
+	return len(snapshots)
+}
+
+func (s *Snapshotter) wallets() []string {
+	seen := make(map[string]bool)
+	var wallets []string
+	add := func(wallet string) {
+		if wallet != "" && !seen[wallet] {
+			seen[wallet] = true
+			wallets = append(wallets, wallet)
+		}
+	}
+
+	for _, wallet := range s.staticWallets {
+		add(wallet)
+	}
+	if s.watchlist != nil {
+		stored, err := s.watchlist.GetWatchlist()
+		if err != nil {
+			log.Printf("Snapshotter failed to load watchlist: %v", err)
+		}
+		for _, wallet := range stored {
+			add(wallet)
+		}
+	}
+	return wallets
+}
+
+// Downsample groups time-ordered snapshots into buckets of interval starting at
+// from. Each non-empty bucket yields the last balance, or the minimum and
+// maximum when aggregate is HistoryAggregateMinMax. Empty buckets are omitted.
+func Downsample(snapshots []models.BalanceSnapshot, from time.Time, interval time.Duration, aggregate string) []models.BalanceHistoryPoint {
+	points := []models.BalanceHistoryPoint{}
+	if interval <= 0 {
+		return points
+	}
+
+	var current *models.BalanceHistoryPoint
+	var currentBucket int64 = -1
+	for _, snapshot := range snapshots {
+		if snapshot.Time.Before(from) {
+			continue
+		}
+
+		bucket := int64(snapshot.Time.Sub(from) / interval)
+		if bucket != currentBucket {
+			points = append(points, models.BalanceHistoryPoint{Time: from.Add(time.Duration(bucket) * interval)})
+			current = &points[len(points)-1]
+			currentBucket = bucket
+		}
+
+		balance := snapshot.Balance
+		if aggregate == HistoryAggregateMinMax {
+			if current.Min == nil || balance < *current.Min {
+				current.Min = &balance
+			}
+			if current.Max == nil || balance > *current.Max {
+				current.Max = &balance
+			}
+		} else {
+			current.Balance = &balance
+		}
+	}
+	return points
+}
@@ -0,0 +1,220 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+// stakeSummaryConcurrency bounds the wallets of one Summaries call read at the same time.
+const stakeSummaryConcurrency = 8
+
+// ErrStakeLookupBudget is reported for wallets past the StakeMaxLookupsPerRequest uncached lookups of a request.
+var ErrStakeLookupBudget = errors.New("too many stake lookups in one request")
+
+// StakeRPC is the part of the RPC client used to find stake accounts.
+type StakeRPC interface {
+	GetStakeAccounts(walletAddress string) ([]rpc.StakeAccount, error)
+	GetEpoch() (uint64, error)
+}
+
+// StakeService finds the SOL a wallet controls through stake accounts, which
+// GetBalance leaves out. Lookups scan the whole Stake program twice, so results
+// are cached for StakeCacheTTL (0 disables caching), failures for
+// StakeErrorCacheTTL, and at most StakeLookupConcurrency lookups run at once.
+type StakeService struct {
+	rpcClient StakeRPC
+	cache     BalanceCache
+	lookups   chan struct{}
+}
+
+func NewStakeService(rpcClient StakeRPC, cache BalanceCache) *StakeService {
+	service := &StakeService{
+		rpcClient: rpcClient,
+		cache:     cache,
+	}
+	if config.AppConfig.StakeLookupConcurrency > 0 {
+		service.lookups = make(chan struct{}, config.AppConfig.StakeLookupConcurrency)
+	}
+	return service
+}
+
+// Summary returns the stake accounts of walletAddress with liquid added to the total.
+func (s *StakeService) Summary(walletAddress string, liquid float64) (*models.StakeSummary, error) {
+	accounts, err := s.stakeAccounts(walletAddress, nil)
+	if err != nil {
+		return nil, err
+	}
+	return stakeSummary(accounts, liquid), nil
+}
+
+// Summaries returns the summary or the error of each wallet, in order, with
+// liquid[i] added to the total of wallets[i]. Wallets are read in parallel and
+// at most StakeMaxLookupsPerRequest of them may miss the cache; the others fail
+// with ErrStakeLookupBudget instead of scanning the Stake program.
+func (s *StakeService) Summaries(wallets []string, liquid []float64) ([]*models.StakeSummary, []error) {
+	summaries := make([]*models.StakeSummary, len(wallets))
+	errs := make([]error, len(wallets))
+
+	var budget *int64
+	if config.AppConfig.StakeMaxLookupsPerRequest > 0 {
+		remaining := int64(config.AppConfig.StakeMaxLookupsPerRequest)
+		budget = &remaining
+	}
+
+	slots := make(chan struct{}, stakeSummaryConcurrency)
+	var wg sync.WaitGroup
+	for i, wallet := range wallets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, wallet string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			accounts, err := s.stakeAccounts(wallet, budget)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			summaries[i] = stakeSummary(accounts, liquid[i])
+		}(i, wallet)
+	}
+	wg.Wait()
+	return summaries, errs
+}
+
+func stakeSummary(accounts []models.StakeAccount, liquid float64) *models.StakeSummary {
+	summary := &models.StakeSummary{Accounts: accounts, Total: liquid}
+	for _, account := range accounts {
+		summary.Staked += account.Balance
+	}
+	summary.Total += summary.Staked
+	return summary
+}
+
+// stakeAccounts reads the stake accounts of walletAddress from the cache or the
+// RPC. A non-nil budget is the number of RPC lookups left to the request.
+func (s *StakeService) stakeAccounts(walletAddress string, budget *int64) ([]models.StakeAccount, error) {
+	key := "stake:" + walletAddress
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		var accounts []models.StakeAccount
+		if err := json.Unmarshal([]byte(value), &accounts); err == nil {
+			return accounts, nil
+		}
+	}
+	if reason, err := s.cache.Get(stakeErrorKey(walletAddress)); err == nil && reason != "" {
+		return nil, errors.New(reason)
+	}
+
+	if budget != nil && atomic.AddInt64(budget, -1) < 0 {
+		return nil, fmt.Errorf("%w, at most %d wallets are looked up per request", ErrStakeLookupBudget, config.AppConfig.StakeMaxLookupsPerRequest)
+	}
+	accounts, err := s.lookup(walletAddress)
+	if err != nil {
+		// Invalid addresses never reach the RPC, so there is nothing to save by caching them
+		if !errors.Is(err, rpc.ErrInvalidAddress) {
+			s.setError(walletAddress, err)
+		}
+		return nil, err
+	}
+
+	if config.AppConfig.StakeCacheTTL <= 0 {
+		return accounts, nil
+	}
+	if value, err := json.Marshal(accounts); err == nil {
+		ttl := time.Duration(config.AppConfig.StakeCacheTTL) * time.Second
+		if err := s.cache.Set(key, string(value), ttl); err != nil {
+			log.Printf("Failed to cache stake accounts for wallet %s: %v", walletAddress, err)
+		}
+	}
+	return accounts, nil
+}
+
+func (s *StakeService) lookup(walletAddress string) ([]models.StakeAccount, error) {
+	if s.lookups != nil {
+		s.lookups <- struct{}{}
+		defer func() { <-s.lookups }()
+	}
+
+	raw, err := s.rpcClient.GetStakeAccounts(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]models.StakeAccount, 0, len(raw))
+	if len(raw) > 0 {
+		epoch, err := s.rpcClient.GetEpoch()
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range raw {
+			accounts = append(accounts, ClassifyStake(account, epoch))
+		}
+	}
+	return accounts, nil
+}
+
+func stakeErrorKey(walletAddress string) string {
+	return "stake:error:" + walletAddress
+}
+
+// setError remembers a failed lookup for StakeErrorCacheTTL so wallets whose
+// lookups time out or are rejected are not scanned again on every request.
+func (s *StakeService) setError(walletAddress string, lookupErr error) {
+	if config.AppConfig.StakeErrorCacheTTL <= 0 {
+		return
+	}
+	ttl := time.Duration(config.AppConfig.StakeErrorCacheTTL) * time.Second
+	if err := s.cache.Set(stakeErrorKey(walletAddress), lookupErr.Error(), ttl); err != nil {
+		log.Printf("Failed to cache stake lookup error for wallet %s: %v", walletAddress, err)
+	}
+}
+
+// ClassifyStake splits a stake account's lamports by activation state in epoch.
+// Warmup and cooldown are assumed to finish within one epoch, which holds unless
+// the network-wide stake change limit is hit; stake activated or deactivated in
+// the current epoch is reported as activating or deactivating.
+func ClassifyStake(account rpc.StakeAccount, epoch uint64) models.StakeAccount {
+	result := models.StakeAccount{
+		Address: account.Address,
+		Voter:   account.Voter,
+		Balance: rpc.LamportsToSOL(account.Lamports),
+		Rent:    rpc.LamportsToSOL(account.RentExemptReserve),
+	}
+
+	delegated := uint64(0)
+	if account.Delegated() {
+		delegated = account.Stake
+		stake := rpc.LamportsToSOL(account.Stake)
+		// Genesis stake has an activation epoch of MaxUint64 and was active from the start
+		bootstrap := account.ActivationEpoch == math.MaxUint64
+
+		switch {
+		case account.Deactivated() && account.DeactivationEpoch == account.ActivationEpoch:
+			// Deactivated before it ever became active
+			result.Inactive += stake
+		case account.Deactivated() && account.DeactivationEpoch < epoch:
+			result.Inactive += stake
+		case account.Deactivated():
+			result.Deactivating = stake
+		case !bootstrap && account.ActivationEpoch >= epoch:
+			result.Activating = stake
+		default:
+			result.Active = stake
+		}
+	}
+
+	// Lamports beyond the reserve and the delegation (e.g. later deposits) are not staked
+	if account.Lamports > account.RentExemptReserve+delegated {
+		result.Inactive += rpc.LamportsToSOL(account.Lamports - account.RentExemptReserve - delegated)
+	}
+	return result
+}
@@ -0,0 +1,380 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"nova-api/config"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+const (
+	subscriptionInitialBackoff = time.Second
+	subscriptionMaxBackoff     = 30 * time.Second
+)
+
+// SubscriptionRPC reads the balance a subscription starts from, since
+// accountSubscribe only reports changes made after it was opened.
+type SubscriptionRPC interface {
+	GetBalanceAndSlot(walletAddress string) (float64, uint64, error)
+}
+
+// BalanceListener is called with every balance pushed by a subscription.
+type BalanceListener func(walletAddress string, balance float64)
+
+// SubscriptionManager keeps accountSubscribe subscriptions open for a set of
+// wallets and writes every lamports change straight into the balance cache.
+// Because those entries are pushed rather than polled they are cached with a
+// much longer TTL. The connection is re-established with backoff when it drops.
+// Wallets are reference counted so several users can watch the same wallet.
+// Every (re)subscribe is followed by a balance read so changes missed while
+// disconnected do not linger in the cache.
+type SubscriptionManager struct {
+	endpoint string
+	rpc      SubscriptionRPC
+	cache    BalanceCache
+	ttl      time.Duration
+
+	mutex        sync.Mutex
+	client       *ws.Client
+	clientFailed bool
+	wallets      map[string]*ws.AccountSubscription
+	refs         map[string]int
+	slots        map[string]uint64
+	synced       map[string]bool
+	listeners    []BalanceListener
+
+	failed chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewSubscriptionManager(wsEndpoint string, rpcClient SubscriptionRPC, cache BalanceCache) *SubscriptionManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := &SubscriptionManager{
+		endpoint: wsEndpoint,
+		rpc:      rpcClient,
+		cache:    cache,
+		ttl:      time.Duration(config.AppConfig.SubscribedBalanceCacheTTL) * time.Second,
+		wallets:  make(map[string]*ws.AccountSubscription),
+		refs:     make(map[string]int),
+		slots:    make(map[string]uint64),
+		synced:   make(map[string]bool),
+		failed:   make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	manager.wg.Add(1)
+	go manager.run()
+
+	return manager
+}
+
+// OnUpdate registers a listener for pushed balances. Listeners must not block.
+func (m *SubscriptionManager) OnUpdate(listener BalanceListener) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.listeners = append(m.listeners, listener)
+}
+
+// Subscribe starts watching a wallet. Every call must be paired with an Unsubscribe.
+func (m *SubscriptionManager) Subscribe(walletAddress string) error {
+	if _, err := solana.PublicKeyFromBase58(walletAddress); err != nil {
+		return fmt.Errorf("%w: %v", rpc.ErrInvalidAddress, err)
+	}
+
+	m.mutex.Lock()
+	m.refs[walletAddress]++
+	if m.refs[walletAddress] > 1 {
+		m.mutex.Unlock()
+		return nil
+	}
+
+	// Subscriptions added while disconnected are opened once the connection is back
+	m.wallets[walletAddress] = nil
+	client := m.client
+	m.mutex.Unlock()
+
+	if client != nil {
+		m.open(client, walletAddress)
+	}
+	return nil
+}
+
+// Unsubscribe releases a wallet. The subscription is closed once nobody watches it anymore.
+func (m *SubscriptionManager) Unsubscribe(walletAddress string) {
+	m.mutex.Lock()
+	if m.refs[walletAddress] == 0 {
+		m.mutex.Unlock()
+		return
+	}
+	m.refs[walletAddress]--
+	if m.refs[walletAddress] > 0 {
+		m.mutex.Unlock()
+		return
+	}
+
+	sub := m.wallets[walletAddress]
+	delete(m.refs, walletAddress)
+	delete(m.wallets, walletAddress)
+	delete(m.slots, walletAddress)
+	m.mutex.Unlock()
+
+	// Unsubscribing writes to the connection, which must not hold up other callers
+	if sub != nil {
+		sub.Unsubscribe()
+	}
+}
+
+// Sync replaces the wallets previously passed to Sync with wallets, leaving
+// wallets watched through Subscribe alone.
+func (m *SubscriptionManager) Sync(wallets []string) {
+	m.mutex.Lock()
+	previous := m.synced
+	m.synced = make(map[string]bool, len(wallets))
+	m.mutex.Unlock()
+
+	current := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		if current[wallet] {
+			continue
+		}
+		current[wallet] = true
+
+		if previous[wallet] {
+			continue
+		}
+		if err := m.Subscribe(wallet); err != nil {
+			log.Printf("Failed to subscribe to wallet %s: %v", wallet, err)
+			delete(current, wallet)
+		}
+	}
+
+	for wallet := range previous {
+		if !current[wallet] {
+			m.Unsubscribe(wallet)
+		}
+	}
+
+	m.mutex.Lock()
+	m.synced = current
+	m.mutex.Unlock()
+}
+
+// Wallets returns every watched wallet, whether or not its subscription is currently open.
+func (m *SubscriptionManager) Wallets() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	wallets := make([]string, 0, len(m.wallets))
+	for wallet := range m.wallets {
+		wallets = append(wallets, wallet)
+	}
+	sort.Strings(wallets)
+	return wallets
+}
+
+// IsActive reports whether a wallet currently has an open subscription, meaning
+// its cached balance is kept up to date without polling.
+func (m *SubscriptionManager) IsActive(walletAddress string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.wallets[walletAddress] != nil
+}
+
+// Close drops all subscriptions and waits for background work to finish.
+func (m *SubscriptionManager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *SubscriptionManager) run() {
+	defer m.wg.Done()
+
+	backoff := subscriptionInitialBackoff
+	for {
+		if err := m.connect(); err != nil {
+			log.Printf("Failed to connect to Solana WebSocket %s: %v", m.endpoint, err)
+
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > subscriptionMaxBackoff {
+				backoff = subscriptionMaxBackoff
+			}
+			continue
+		}
+		backoff = subscriptionInitialBackoff
+
+		if !m.waitForFailure() {
+			m.disconnect()
+			return
+		}
+		log.Printf("Solana WebSocket connection lost, reconnecting")
+		m.disconnect()
+	}
+}
+
+// waitForFailure blocks until the current connection fails, returning false if the manager is closed first.
+func (m *SubscriptionManager) waitForFailure() bool {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-m.failed:
+			m.mutex.Lock()
+			failed := m.clientFailed
+			m.mutex.Unlock()
+			if failed {
+				return true
+			}
+		}
+	}
+}
+
+func (m *SubscriptionManager) connect() error {
+	client, err := ws.Connect(m.ctx, m.endpoint)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.client = client
+	m.clientFailed = false
+	wallets := make([]string, 0, len(m.wallets))
+	for wallet := range m.wallets {
+		wallets = append(wallets, wallet)
+	}
+	m.mutex.Unlock()
+
+	for _, wallet := range wallets {
+		m.open(client, wallet)
+	}
+	return nil
+}
+
+func (m *SubscriptionManager) disconnect() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.client == nil {
+		return
+	}
+
+	client := m.client
+	m.client = nil
+	for wallet := range m.wallets {
+		m.wallets[wallet] = nil
+	}
+	client.Close()
+}
+
+// open subscribes to a wallet on client. The handshake runs without m.mutex
+// held; the subscription is dropped again if, meanwhile, the wallet was
+// released, the connection replaced or another call subscribed first.
+func (m *SubscriptionManager) open(client *ws.Client, walletAddress string) {
+	pubkey := solana.MustPublicKeyFromBase58(walletAddress)
+
+	sub, err := client.AccountSubscribe(pubkey, rpc.BalanceCommitment)
+	if err != nil {
+		log.Printf("Failed to subscribe to wallet %s: %v", walletAddress, err)
+		m.reportFailure(client)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	current, watched := m.wallets[walletAddress]
+	if m.client != client || !watched || current != nil {
+		sub.Unsubscribe()
+		return
+	}
+
+	m.wallets[walletAddress] = sub
+	m.wg.Add(1)
+	go m.receive(client, walletAddress, sub)
+}
+
+func (m *SubscriptionManager) receive(client *ws.Client, walletAddress string, sub *ws.AccountSubscription) {
+	defer m.wg.Done()
+
+	// The subscription only reports later changes, so the balance it starts from is read once.
+	// Notifications queue up meanwhile and are ordered against it by slot.
+	balance, slot, err := m.rpc.GetBalanceAndSlot(walletAddress)
+	if err != nil {
+		log.Printf("Failed to resync balance for wallet %s: %v", walletAddress, err)
+	} else {
+		m.publish(walletAddress, sub, balance, slot)
+	}
+
+	for {
+		result, err := sub.Recv(m.ctx)
+		if err != nil || result == nil {
+			// Unsubscribe closes the subscription, which surfaces as ErrSubscriptionClosed or a nil result
+			if err != nil && !errors.Is(err, ws.ErrSubscriptionClosed) && m.ctx.Err() == nil {
+				m.reportFailure(client)
+			}
+			return
+		}
+
+		if result.Value == nil {
+			continue
+		}
+
+		m.publish(walletAddress, sub, rpc.LamportsToSOL(result.Value.Lamports), result.Context.Slot)
+	}
+}
+
+// publish caches a balance read at slot and passes it to the listeners. Balances
+// older than the last one published, or of a subscription that was meanwhile
+// closed or replaced, are dropped.
+func (m *SubscriptionManager) publish(walletAddress string, sub *ws.AccountSubscription, balance float64, slot uint64) {
+	m.mutex.Lock()
+	if m.wallets[walletAddress] != sub || slot < m.slots[walletAddress] {
+		m.mutex.Unlock()
+		return
+	}
+	m.slots[walletAddress] = slot
+	listeners := m.listeners
+	m.mutex.Unlock()
+
+	if err := m.cache.SetBalanceWithTTL(walletAddress, balance, m.ttl); err != nil {
+		log.Printf("Failed to cache pushed balance for wallet %s: %v", walletAddress, err)
+	}
+	for _, listener := range listeners {
+		listener(walletAddress, balance)
+	}
+}
+
+// reportFailure flags client as broken. Failures of connections that were already replaced are ignored.
+func (m *SubscriptionManager) reportFailure(client *ws.Client) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.client != client {
+		return
+	}
+
+	m.clientFailed = true
+	select {
+	case m.failed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,212 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+// TokenMetadataRPC is the part of the RPC client used to describe mints.
+type TokenMetadataRPC interface {
+	GetTokenMetadatas(mints []string) ([]*rpc.TokenMetadata, []error)
+}
+
+// tokenListEntry is a token of a token-list JSON file
+type tokenListEntry struct {
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals *uint8 `json:"decimals"`
+	LogoURI  string `json:"logoURI"`
+}
+
+// TokenMetadataService describes mints from their on-chain metadata (the
+// Token-2022 metadata extension or the Metaplex metadata account), overlaid
+// with the entries of the token list at TokenListPath. On-chain metadata rarely
+// changes and is cached for TokenMetadataCacheTTL, mints that could not be
+// described for TokenMetadataErrorCacheTTL.
+type TokenMetadataService struct {
+	rpcClient TokenMetadataRPC
+	cache     BalanceCache
+	tokenList map[string]tokenListEntry
+}
+
+func NewTokenMetadataService(rpcClient TokenMetadataRPC, cache BalanceCache) *TokenMetadataService {
+	tokenList := map[string]tokenListEntry{}
+	if path := config.AppConfig.TokenListPath; path != "" {
+		loaded, err := loadTokenList(path)
+		if err != nil {
+			log.Printf("Ignoring token list %s: %v", path, err)
+		} else {
+			tokenList = loaded
+		}
+	}
+
+	return &TokenMetadataService{
+		rpcClient: rpcClient,
+		cache:     cache,
+		tokenList: tokenList,
+	}
+}
+
+// loadTokenList reads a token list, either in the {"tokens": [...]} format of
+// the Solana token list or as a plain array of tokens.
+func loadTokenList(path string) (map[string]tokenListEntry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Tokens []tokenListEntry `json:"tokens"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		if err := json.Unmarshal(raw, &list.Tokens); err != nil {
+			return nil, fmt.Errorf("expected a token list: %w", err)
+		}
+	}
+
+	tokens := make(map[string]tokenListEntry, len(list.Tokens))
+	for _, token := range list.Tokens {
+		tokens[token.Address] = token
+	}
+	return tokens, nil
+}
+
+// Metadata describes mint. When the chain cannot be read, a token list entry is still returned.
+func (s *TokenMetadataService) Metadata(mint string) (*models.TokenMetadata, error) {
+	metadatas, errs := s.Describe([]string{mint})
+	return metadatas[0], errs[0]
+}
+
+// Describe returns the metadata or the error of each mint, in order, reading
+// the mints missing from the cache in one batch.
+func (s *TokenMetadataService) Describe(mints []string) ([]*models.TokenMetadata, []error) {
+	metadatas, errs := s.onChain(mints)
+	for i, mint := range mints {
+		metadatas[i], errs[i] = s.withTokenList(mint, metadatas[i], errs[i])
+	}
+	return metadatas, errs
+}
+
+// withTokenList overlays the token list entry of mint on its on-chain metadata.
+func (s *TokenMetadataService) withTokenList(mint string, metadata *models.TokenMetadata, err error) (*models.TokenMetadata, error) {
+	entry, listed := s.tokenList[mint]
+	if err != nil && !listed {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = &models.TokenMetadata{Mint: mint}
+	}
+
+	if listed {
+		if entry.Name != "" {
+			metadata.Name = entry.Name
+		}
+		if entry.Symbol != "" {
+			metadata.Symbol = entry.Symbol
+		}
+		if entry.Decimals != nil && err != nil {
+			metadata.Decimals = *entry.Decimals
+		}
+		metadata.LogoURI = entry.LogoURI
+	}
+	return metadata, nil
+}
+
+func (s *TokenMetadataService) onChain(mints []string) ([]*models.TokenMetadata, []error) {
+	metadatas := make([]*models.TokenMetadata, len(mints))
+	errs := make([]error, len(mints))
+
+	// A mint listed more than once is read once
+	missing := []string{}
+	indexes := map[string][]int{}
+	for i, mint := range mints {
+		if value, err := s.cache.Get(tokenMetadataKey(mint)); err == nil && value != "" {
+			var metadata models.TokenMetadata
+			if err := json.Unmarshal([]byte(value), &metadata); err == nil {
+				metadatas[i] = &metadata
+				continue
+			}
+		}
+		if reason, err := s.cache.Get(tokenMetadataErrorKey(mint)); err == nil && reason != "" {
+			errs[i] = errors.New(reason)
+			continue
+		}
+		if _, pending := indexes[mint]; !pending {
+			missing = append(missing, mint)
+		}
+		indexes[mint] = append(indexes[mint], i)
+	}
+	if len(missing) == 0 {
+		return metadatas, errs
+	}
+
+	results, resultErrs := s.rpcClient.GetTokenMetadatas(missing)
+	for j, mint := range missing {
+		if err := resultErrs[j]; err != nil {
+			for _, i := range indexes[mint] {
+				errs[i] = err
+			}
+			// Invalid addresses never reach the RPC, so there is nothing to save by caching them
+			if !errors.Is(err, rpc.ErrInvalidAddress) {
+				s.cacheFailure(mint, err)
+			}
+			continue
+		}
+
+		metadata := models.TokenMetadata{
+			Mint:        mint,
+			Name:        results[j].Name,
+			Symbol:      results[j].Symbol,
+			Decimals:    results[j].Decimals,
+			MetadataURI: results[j].URI,
+		}
+		s.cacheMetadata(&metadata)
+		// Every position gets its own copy since the token list overlay modifies it
+		for _, i := range indexes[mint] {
+			copied := metadata
+			metadatas[i] = &copied
+		}
+	}
+	return metadatas, errs
+}
+
+func tokenMetadataKey(mint string) string {
+	return "token:meta:" + mint
+}
+
+func tokenMetadataErrorKey(mint string) string {
+	return "token:meta:error:" + mint
+}
+
+func (s *TokenMetadataService) cacheMetadata(metadata *models.TokenMetadata) {
+	if config.AppConfig.TokenMetadataCacheTTL <= 0 {
+		return
+	}
+	if value, err := json.Marshal(metadata); err == nil {
+		ttl := time.Duration(config.AppConfig.TokenMetadataCacheTTL) * time.Second
+		if err := s.cache.Set(tokenMetadataKey(metadata.Mint), string(value), ttl); err != nil {
+			log.Printf("Failed to cache metadata of mint %s: %v", metadata.Mint, err)
+		}
+	}
+}
+
+// cacheFailure remembers a mint that could not be described, so wallets holding
+// many unknown or closed mints do not read them again on every request.
+func (s *TokenMetadataService) cacheFailure(mint string, failure error) {
+	if config.AppConfig.TokenMetadataErrorCacheTTL <= 0 {
+		return
+	}
+	ttl := time.Duration(config.AppConfig.TokenMetadataErrorCacheTTL) * time.Second
+	if err := s.cache.Set(tokenMetadataErrorKey(mint), failure.Error(), ttl); err != nil {
+		log.Printf("Failed to cache metadata error of mint %s: %v", mint, err)
+	}
+}
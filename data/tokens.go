@@ -0,0 +1,107 @@
+package data
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+// TokenRPC is the part of the RPC client used to list a wallet's token accounts.
+type TokenRPC interface {
+	GetTokenAccounts(walletAddress string) ([]rpc.TokenHolding, error)
+}
+
+// TokenService reports a wallet's SPL token balances, described by its
+// TokenMetadataService. Token accounts are cached for BalanceCacheTTL like SOL balances.
+type TokenService struct {
+	rpcClient TokenRPC
+	cache     BalanceCache
+	metadata  *TokenMetadataService
+}
+
+func NewTokenService(rpcClient TokenRPC, cache BalanceCache, metadata *TokenMetadataService) *TokenService {
+	return &TokenService{
+		rpcClient: rpcClient,
+		cache:     cache,
+		metadata:  metadata,
+	}
+}
+
+// Holdings returns the non-zero token balances of walletAddress, one per mint, largest first.
+func (s *TokenService) Holdings(walletAddress string) ([]models.TokenBalance, error) {
+	holdings, err := s.tokenAccounts(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]uint64{}
+	decimals := map[string]uint8{}
+	for _, holding := range holdings {
+		if holding.Amount == 0 {
+			continue
+		}
+		raw[holding.Mint] += holding.Amount
+		decimals[holding.Mint] = holding.Decimals
+	}
+
+	mints := make([]string, 0, len(raw))
+	for mint := range raw {
+		mints = append(mints, mint)
+	}
+	metadatas, errs := s.metadata.Describe(mints)
+
+	balances := make([]models.TokenBalance, 0, len(raw))
+	for i, mint := range mints {
+		balance := models.TokenBalance{
+			Mint:     mint,
+			Amount:   float64(raw[mint]) / math.Pow10(int(decimals[mint])),
+			Decimals: decimals[mint],
+		}
+		// Metadata is only decoration, a balance is still worth returning without it
+		if errs[i] != nil {
+			log.Printf("Failed to get metadata of mint %s: %v", mint, errs[i])
+		} else {
+			balance.Token = metadatas[i]
+		}
+		balances = append(balances, balance)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Amount != balances[j].Amount {
+			return balances[i].Amount > balances[j].Amount
+		}
+		return balances[i].Mint < balances[j].Mint
+	})
+	return balances, nil
+}
+
+func (s *TokenService) tokenAccounts(walletAddress string) ([]rpc.TokenHolding, error) {
+	key := "tokens:" + walletAddress
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		var holdings []rpc.TokenHolding
+		if err := json.Unmarshal([]byte(value), &holdings); err == nil {
+			return holdings, nil
+		}
+	}
+
+	holdings, err := s.rpcClient.GetTokenAccounts(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.AppConfig.BalanceCacheTTL > 0 {
+		if value, err := json.Marshal(holdings); err == nil {
+			ttl := time.Duration(config.AppConfig.BalanceCacheTTL) * time.Second
+			if err := s.cache.Set(key, string(value), ttl); err != nil {
+				log.Printf("Failed to cache token accounts of wallet %s: %v", walletAddress, err)
+			}
+		}
+	}
+	return holdings, nil
+}
@@ -0,0 +1,260 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// transactionFetchConcurrency bounds the getTransaction calls made for one page.
+const transactionFetchConcurrency = 8
+
+// ErrInvalidCursor is returned for a pagination cursor that was not issued by TransactionService.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TransactionRPC is the part of the RPC client used to list wallet transactions.
+type TransactionRPC interface {
+	GetSignatures(walletAddress, before string, limit int) ([]rpc.SignatureInfo, error)
+	GetTransactionDetails(signature string) (*rpc.TransactionDetails, error)
+}
+
+// TransactionService lists a wallet's finalized transactions as SOL and SPL
+// transfers. Finalized transactions never change, so their details are cached
+// without expiry and shared between every wallet they touched.
+type TransactionService struct {
+	rpcClient TransactionRPC
+	cache     BalanceCache
+	metadata  *TokenMetadataService
+}
+
+func NewTransactionService(rpcClient TransactionRPC, cache BalanceCache) *TransactionService {
+	return &TransactionService{
+		rpcClient: rpcClient,
+		cache:     cache,
+	}
+}
+
+// SetTokenMetadata describes the tokens of SPL transfers.
+func (s *TransactionService) SetTokenMetadata(metadata *TokenMetadataService) {
+	s.metadata = metadata
+}
+
+// Transactions returns up to limit transactions of walletAddress, newest first,
+// starting after cursor (or at the tip when empty).
+func (s *TransactionService) Transactions(walletAddress, cursor string, limit int) (*models.TransactionPage, error) {
+	if _, err := solana.PublicKeyFromBase58(walletAddress); err != nil {
+		return nil, fmt.Errorf("%w: %v", rpc.ErrInvalidAddress, err)
+	}
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, err := s.rpcClient.GetSignatures(walletAddress, before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]*rpc.TransactionDetails, len(signatures))
+	errs := make([]error, len(signatures))
+	slots := make(chan struct{}, transactionFetchConcurrency)
+	var wg sync.WaitGroup
+	for i, signature := range signatures {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, signature string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			details[i], errs[i] = s.transactionDetails(signature)
+		}(i, signature.Signature)
+	}
+	wg.Wait()
+
+	page := &models.TransactionPage{Transactions: make([]models.WalletTransaction, 0, len(signatures))}
+	for i := range signatures {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		page.Transactions = append(page.Transactions, NormalizeTransaction(details[i], walletAddress))
+	}
+	s.describeTokens(page.Transactions)
+
+	// A short page means the history is exhausted
+	if len(signatures) == limit {
+		page.NextCursor = encodeCursor(signatures[len(signatures)-1].Signature)
+	}
+	return page, nil
+}
+
+// describeTokens adds the metadata of every SPL transfer on a page, reading the mints in one batch.
+func (s *TransactionService) describeTokens(transactions []models.WalletTransaction) {
+	if s.metadata == nil {
+		return
+	}
+	transfers := []*models.Transfer{}
+	mints := []string{}
+	for i := range transactions {
+		for j := range transactions[i].Transfers {
+			if transfer := &transactions[i].Transfers[j]; transfer.Type == "spl" {
+				transfers = append(transfers, transfer)
+				mints = append(mints, transfer.Mint)
+			}
+		}
+	}
+	if len(mints) == 0 {
+		return
+	}
+
+	metadatas, errs := s.metadata.Describe(mints)
+	for i, transfer := range transfers {
+		if errs[i] != nil {
+			log.Printf("Failed to get metadata of mint %s: %v", mints[i], errs[i])
+		} else {
+			transfer.Token = metadatas[i]
+		}
+	}
+}
+
+func (s *TransactionService) transactionDetails(signature string) (*rpc.TransactionDetails, error) {
+	key := "tx:" + signature
+	if value, err := s.cache.Get(key); err == nil && value != "" {
+		var details rpc.TransactionDetails
+		if err := json.Unmarshal([]byte(value), &details); err == nil {
+			return &details, nil
+		}
+	}
+
+	details, err := s.rpcClient.GetTransactionDetails(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, err := json.Marshal(details); err == nil {
+		if err := s.cache.Set(key, string(value), 0); err != nil {
+			log.Printf("Failed to cache transaction %s: %v", signature, err)
+		}
+	}
+	return details, nil
+}
+
+// NormalizeTransaction reduces a transaction to the net SOL and per-mint SPL
+// movements of walletAddress. The fee is left out of the SOL transfer and
+// reported separately.
+func NormalizeTransaction(details *rpc.TransactionDetails, walletAddress string) models.WalletTransaction {
+	transaction := models.WalletTransaction{
+		Signature: details.Signature,
+		Slot:      details.Slot,
+		BlockTime: details.BlockTime,
+		Status:    "success",
+		Fee:       rpc.LamportsToSOL(details.Fee),
+		Transfers: []models.Transfer{},
+	}
+	if details.Failed {
+		transaction.Status = "failed"
+	}
+
+	// SOL: the fee payer is always the first account, add the fee back so only transfers remain
+	solDeltas := map[string]int64{}
+	for i, account := range details.Accounts {
+		if i >= len(details.PreBalances) || i >= len(details.PostBalances) {
+			break
+		}
+		delta := int64(details.PostBalances[i]) - int64(details.PreBalances[i])
+		if i == 0 {
+			delta += int64(details.Fee)
+		}
+		solDeltas[account] += delta
+	}
+	if delta := solDeltas[walletAddress]; delta != 0 {
+		transaction.Transfers = append(transaction.Transfers, models.Transfer{
+			Type:         "sol",
+			Direction:    direction(delta),
+			Counterparty: counterparty(solDeltas, walletAddress, delta),
+			Amount:       rpc.LamportsToSOL(uint64(abs(delta))),
+		})
+	}
+
+	// SPL: sum the token accounts of each owner per mint
+	mints := []string{}
+	decimals := map[string]uint8{}
+	tokenDeltas := map[string]map[string]int64{}
+	for _, balance := range details.TokenBalances {
+		if _, seen := tokenDeltas[balance.Mint]; !seen {
+			mints = append(mints, balance.Mint)
+			decimals[balance.Mint] = balance.Decimals
+			tokenDeltas[balance.Mint] = map[string]int64{}
+		}
+		tokenDeltas[balance.Mint][balance.Owner] += int64(balance.Post) - int64(balance.Pre)
+	}
+	for _, mint := range mints {
+		delta := tokenDeltas[mint][walletAddress]
+		if delta == 0 {
+			continue
+		}
+		transaction.Transfers = append(transaction.Transfers, models.Transfer{
+			Type:         "spl",
+			Mint:         mint,
+			Decimals:     decimals[mint],
+			Direction:    direction(delta),
+			Counterparty: counterparty(tokenDeltas[mint], walletAddress, delta),
+			Amount:       float64(abs(delta)) / math.Pow10(int(decimals[mint])),
+		})
+	}
+
+	return transaction
+}
+
+func direction(delta int64) string {
+	if delta > 0 {
+		return "in"
+	}
+	return "out"
+}
+
+// counterparty returns the account other than walletAddress with the largest change opposite to delta
+func counterparty(deltas map[string]int64, walletAddress string, delta int64) string {
+	best, bestAmount := "", int64(0)
+	for account, other := range deltas {
+		if account == walletAddress || account == "" || (other > 0) == (delta > 0) || other == 0 {
+			continue
+		}
+		if amount := abs(other); amount > bestAmount || (amount == bestAmount && account < best) {
+			best, bestAmount = account, amount
+		}
+	}
+	return best
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func encodeCursor(signature string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(signature))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	if _, err := solana.SignatureFromBase58(string(decoded)); err != nil {
+		return "", ErrInvalidCursor
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+var ErrWalletGroupNotFound = errors.New("wallet group not found")
+
+// WalletGroupStore persists the wallet groups of API keys
+type WalletGroupStore interface {
+	CreateWalletGroup(group *models.WalletGroup) error
+	ListWalletGroups(apiKey string) ([]models.WalletGroup, error)
+	GetWalletGroup(apiKey, id string) (*models.WalletGroup, error)
+	DeleteWalletGroup(apiKey, id string) error
+}
+
+func (ms *MongoService) CreateWalletGroup(group *models.WalletGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWalletGroups)
+	if _, err := collection.InsertOne(ctx, group); err != nil {
+		return fmt.Errorf("failed to create wallet group: %w", err)
+	}
+	return nil
+}
+
+func (ms *MongoService) ListWalletGroups(apiKey string) ([]models.WalletGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWalletGroups)
+	cursor, err := collection.Find(ctx, bson.M{"api_key": apiKey}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet groups: %w", err)
+	}
+
+	groups := []models.WalletGroup{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode wallet groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (ms *MongoService) GetWalletGroup(apiKey, id string) (*models.WalletGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWalletGroups)
+	var group models.WalletGroup
+	err := collection.FindOne(ctx, bson.M{"_id": id, "api_key": apiKey}).Decode(&group)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWalletGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet group: %w", err)
+	}
+	return &group, nil
+}
+
+func (ms *MongoService) DeleteWalletGroup(apiKey, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWalletGroups)
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "api_key": apiKey})
+	if err != nil {
+		return fmt.Errorf("failed to delete wallet group: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrWalletGroupNotFound
+	}
+	return nil
+}
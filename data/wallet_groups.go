@@ -0,0 +1,63 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WalletGroupService manages the wallet groups of API keys. Groups are only
+// visible to the API key that created them.
+type WalletGroupService struct {
+	store WalletGroupStore
+}
+
+func NewWalletGroupService(store WalletGroupStore) *WalletGroupService {
+	return &WalletGroupService{
+		store: store,
+	}
+}
+
+// Create stores a group of the wallets in request, dropping duplicates.
+func (s *WalletGroupService) Create(apiKey string, request models.WalletGroupRequest) (*models.WalletGroup, error) {
+	wallets := make([]string, 0, len(request.Wallets))
+	seen := make(map[string]bool, len(request.Wallets))
+	for _, wallet := range request.Wallets {
+		if _, err := solana.PublicKeyFromBase58(wallet); err != nil {
+			return nil, fmt.Errorf("%w %s: %v", rpc.ErrInvalidAddress, wallet, err)
+		}
+		if !seen[wallet] {
+			seen[wallet] = true
+			wallets = append(wallets, wallet)
+		}
+	}
+
+	group := models.WalletGroup{
+		ID:        primitive.NewObjectID().Hex(),
+		APIKey:    apiKey,
+		Name:      request.Name,
+		Wallets:   wallets,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.store.CreateWalletGroup(&group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (s *WalletGroupService) List(apiKey string) ([]models.WalletGroup, error) {
+	return s.store.ListWalletGroups(apiKey)
+}
+
+func (s *WalletGroupService) Get(apiKey, id string) (*models.WalletGroup, error) {
+	return s.store.GetWalletGroup(apiKey, id)
+}
+
+func (s *WalletGroupService) Delete(apiKey, id string) error {
+	return s.store.DeleteWalletGroup(apiKey, id)
+}
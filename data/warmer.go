@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"nova-api/config"
+)
+
+// WatchlistProvider returns wallets that should be kept warm in the balance cache.
+type WatchlistProvider interface {
+	GetWatchlist() ([]string, error)
+}
+
+// CacheWarmer periodically refreshes the balances of watchlisted and frequently
+// requested wallets so they never expire from the cache under load.
+type CacheWarmer struct {
+	balanceService *BalanceService
+	watchlist      WatchlistProvider
+	staticWallets  []string
+	interval       time.Duration
+	budget         int
+	learnTopN      int
+	subscriptions  *SubscriptionManager
+
+	mutex  sync.Mutex
+	offset int
+	warm   []string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewCacheWarmer creates a warmer from config. watchlist may be nil when wallets only come from config.
+func NewCacheWarmer(balanceService *BalanceService, watchlist WatchlistProvider) *CacheWarmer {
+	interval := time.Duration(config.AppConfig.CacheWarmerInterval) * time.Second
+	if interval <= 0 {
+		// Refresh a little before cached balances expire
+		interval = time.Duration(config.AppConfig.BalanceCacheTTL) * time.Second * 4 / 5
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	return &CacheWarmer{
+		balanceService: balanceService,
+		watchlist:      watchlist,
+		staticWallets:  config.AppConfig.WatchlistWallets,
+		interval:       interval,
+		budget:         config.AppConfig.CacheWarmerRPCBudget,
+		learnTopN:      config.AppConfig.CacheWarmerLearnTopN,
+	}
+}
+
+// UseSubscriptions hands the warmed wallets to a SubscriptionManager. Wallets
+// with an open subscription are kept fresh by pushes and are no longer polled.
+func (w *CacheWarmer) UseSubscriptions(subscriptions *SubscriptionManager) {
+	w.subscriptions = subscriptions
+}
+
+// Start runs the refresh loop in the background until ctx is cancelled or Close is called.
+func (w *CacheWarmer) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			w.Refresh()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (w *CacheWarmer) Close() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+}
+
+// Wallets returns the wallets considered in the most recent refresh.
+func (w *CacheWarmer) Wallets() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return append([]string(nil), w.warm...)
+}
+
+// Refresh runs a single refresh cycle and returns the number of RPC calls made.
+// When there are more wallets than the budget allows, later cycles continue where
+// this one stopped so every wallet is eventually refreshed.
+func (w *CacheWarmer) Refresh() int {
+	wallets := w.collectWallets()
+	if w.subscriptions != nil {
+		w.subscriptions.Sync(wallets)
+	}
+
+	w.mutex.Lock()
+	w.warm = wallets
+	start := w.offset
+	w.mutex.Unlock()
+
+	if len(wallets) == 0 {
+		return 0
+	}
+
+	calls := 0
+	visited := 0
+	for ; visited < len(wallets); visited++ {
+		if w.budget > 0 && calls >= w.budget {
+			break
+		}
+
+		wallet := wallets[(start+visited)%len(wallets)]
+		if w.subscriptions != nil && w.subscriptions.IsActive(wallet) {
+			continue
+		}
+
+		calls++
+		if _, err := w.balanceService.RefreshBalance(wallet); err != nil {
+			log.Printf("Cache warmer failed to refresh wallet %s: %v", wallet, err)
+		}
+	}
+
+	w.mutex.Lock()
+	w.offset = (start + visited) % len(wallets)
+	w.mutex.Unlock()
+
+	return calls
+}
+
+// collectWallets merges configured, stored and learned wallets in that order of priority.
+func (w *CacheWarmer) collectWallets() []string {
+	seen := make(map[string]bool)
+	var wallets []string
+	add := func(list []string) {
+		for _, wallet := range list {
+			if wallet != "" && !seen[wallet] {
+				seen[wallet] = true
+				wallets = append(wallets, wallet)
+			}
+		}
+	}
+
+	add(w.staticWallets)
+
+	if w.watchlist != nil {
+		stored, err := w.watchlist.GetWatchlist()
+		if err != nil {
+			log.Printf("Cache warmer failed to load watchlist: %v", err)
+		}
+		add(stored)
+	}
+
+	if w.learnTopN > 0 {
+		add(w.balanceService.TopRequestedWallets(w.learnTopN))
+	}
+
+	return wallets
+}
@@ -0,0 +1,149 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookStore persists webhooks, their delivery log and dead-lettered
+// deliveries, and holds the lease electing the replica that delivers them
+type WebhookStore interface {
+	CreateWebhook(webhook *models.Webhook) error
+	ListWebhooks(apiKey string) ([]models.Webhook, error)
+	AllWebhooks() ([]models.Webhook, error)
+	DeleteWebhook(apiKey, id string) error
+	SaveDelivery(delivery *models.WebhookDelivery) error
+	ListDeliveries(apiKey, webhookID string, limit int) ([]models.WebhookDelivery, error)
+	SaveDeadLetter(delivery *models.WebhookDelivery) error
+	AcquireLease(name, holder string, ttl time.Duration) (bool, error)
+}
+
+func (ms *MongoService) CreateWebhook(webhook *models.Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWebhooks)
+	if _, err := collection.InsertOne(ctx, webhook); err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+func (ms *MongoService) ListWebhooks(apiKey string) ([]models.Webhook, error) {
+	return ms.findWebhooks(bson.M{"api_key": apiKey})
+}
+
+func (ms *MongoService) AllWebhooks() ([]models.Webhook, error) {
+	return ms.findWebhooks(bson.M{})
+}
+
+func (ms *MongoService) findWebhooks(filter bson.M) ([]models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWebhooks)
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	webhooks := []models.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (ms *MongoService) DeleteWebhook(apiKey, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWebhooks)
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "api_key": apiKey})
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// SaveDelivery inserts or replaces the delivery log entry for a delivery
+func (ms *MongoService) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return ms.upsertDelivery(config.AppConfig.MongoDBCollectionWebhookDeliveries, delivery)
+}
+
+func (ms *MongoService) SaveDeadLetter(delivery *models.WebhookDelivery) error {
+	return ms.upsertDelivery(config.AppConfig.MongoDBCollectionWebhookDeadLetters, delivery)
+}
+
+func (ms *MongoService) upsertDelivery(collectionName string, delivery *models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(collectionName)
+	opts := options.Replace().SetUpsert(true)
+	if _, err := collection.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery, opts); err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the most recent deliveries of a webhook, newest first
+func (ms *MongoService) ListDeliveries(apiKey, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionWebhookDeliveries)
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.M{"webhook_id": webhookID, "api_key": apiKey}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook deliveries: %w", err)
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// LeaseStore hands out named leases so work shared by all replicas is done by one of them at a time.
+type LeaseStore interface {
+	AcquireLease(name, holder string, ttl time.Duration) (bool, error)
+}
+
+// AcquireLease takes or renews the lease called name for holder until ttl from
+// now. It reports false while another holder's lease has not expired yet.
+func (ms *MongoService) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	collection := ms.database.Collection(config.AppConfig.MongoDBCollectionLeases)
+	filter := bson.M{"_id": name, "$or": bson.A{bson.M{"holder": holder}, bson.M{"expires_at": bson.M{"$lt": now}}}}
+	update := bson.M{"$set": bson.M{"holder": holder, "expires_at": now.Add(ttl)}}
+
+	// A lease held by someone else fails the filter, and the upsert then
+	// collides with the existing document
+	_, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	return true, nil
+}
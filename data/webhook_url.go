@@ -0,0 +1,87 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"nova-api/config"
+)
+
+var ErrInvalidWebhookURL = errors.New("invalid webhook url")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// ValidateWebhookURL checks that raw is an absolute http or https URL whose
+// host only resolves to public addresses, so a webhook cannot be pointed at
+// the server's own network or the cloud metadata endpoint. The check is
+// repeated when connecting, see newWebhookClient.
+func ValidateWebhookURL(raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhookURL)
+	}
+	if config.AppConfig.WebhookAllowPrivateURLs {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %s", ErrInvalidWebhookURL, target.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to the non-public address %s", ErrInvalidWebhookURL, target.Hostname(), addr.IP)
+		}
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address. This
+// excludes loopback, RFC 1918 and unique local, link-local (which includes the
+// 169.254.169.254 metadata endpoint), shared and unspecified addresses.
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// newWebhookClient returns the HTTP client for outbound webhook and alert
+// requests. Every connection is checked against the resolved address, so a
+// host that passed ValidateWebhookURL cannot later be rebound to a private
+// address. Proxies from the environment are ignored since the check would
+// then only see the proxy.
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if config.AppConfig.WebhookAllowPrivateURLs {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: connecting to the non-public address %s is not allowed", ErrInvalidWebhookURL, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
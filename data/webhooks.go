@@ -0,0 +1,650 @@
+package data
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+const (
+	webhookQueueSize = 1024
+
+	WebhookEventBalanceChanged = "balance.changed"
+	WebhookEventBalanceBelow   = "balance.below_threshold"
+	WebhookEventBalanceAbove   = "balance.above_threshold"
+
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusFailed    = "failed"
+
+	webhookLeaseName = "webhooks"
+)
+
+var ErrWebhookLimit = errors.New("webhook limit reached")
+
+// WebhookDispatcher watches the wallets of every registered webhook on the
+// BalanceFeed and POSTs an HMAC-signed event to the webhook's URL when one of
+// them changes. Failed deliveries are scheduled for a retry with exponential
+// backoff, without holding up a worker, and dead-lettered once
+// WebhookMaxAttempts is reached. Every attempt is recorded in the delivery log.
+//
+// With several replicas only the one holding the store's webhook lease sends
+// deliveries. Every replica reloads the webhooks from the store whenever it
+// renews or tries to take the lease, so webhooks registered or deleted on
+// another replica are picked up.
+type WebhookDispatcher struct {
+	store    WebhookStore
+	client   *http.Client
+	sub      *FeedSubscription
+	holder   string
+	leaseTTL time.Duration
+	leader   atomic.Bool
+
+	// registerMutex serializes Register, Delete and reload so the per-key
+	// limits hold and a reload cannot undo a change made while it reads the store
+	registerMutex sync.Mutex
+	// watchMutex serializes add and remove so the wallets of sub follow
+	// walletRefs. Unlike mutex it is held while sub subscribes upstream.
+	watchMutex sync.Mutex
+
+	mutex      sync.Mutex
+	webhooks   map[string]models.Webhook
+	walletRefs map[string]int
+	balances   map[string]float64
+
+	retryMutex sync.Mutex
+	retries    retryQueue
+	retryWake  chan struct{}
+
+	queue  chan *models.WebhookDelivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// retryQueue is a min-heap of deliveries ordered by their next attempt.
+type retryQueue []*models.WebhookDelivery
+
+func (q retryQueue) Len() int           { return len(q) }
+func (q retryQueue) Less(i, j int) bool { return q[i].NextAttemptAt.Before(*q[j].NextAttemptAt) }
+func (q retryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *retryQueue) Push(x any)        { *q = append(*q, x.(*models.WebhookDelivery)) }
+func (q *retryQueue) Pop() any {
+	old := *q
+	delivery := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return delivery
+}
+
+// NewWebhookDispatcher loads the stored webhooks and starts watching their wallets.
+func NewWebhookDispatcher(store WebhookStore, feed *BalanceFeed) (*WebhookDispatcher, error) {
+	webhooks, err := store.AllWebhooks()
+	if err != nil {
+		return nil, err
+	}
+
+	leaseTTL := time.Duration(config.AppConfig.WebhookLeaseTTL) * time.Second
+	if leaseTTL <= 0 {
+		leaseTTL = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatcher := &WebhookDispatcher{
+		store:      store,
+		client:     newWebhookClient(time.Duration(config.AppConfig.WebhookTimeout) * time.Second),
+		sub:        feed.Subscribe(webhookQueueSize),
+		holder:     newInstanceID(),
+		leaseTTL:   leaseTTL,
+		webhooks:   make(map[string]models.Webhook),
+		walletRefs: make(map[string]int),
+		balances:   make(map[string]float64),
+		retryWake:  make(chan struct{}, 1),
+		queue:      make(chan *models.WebhookDelivery, webhookQueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	for _, webhook := range webhooks {
+		if err := dispatcher.add(webhook); err != nil {
+			log.Printf("Skipping webhook %s: %v", webhook.ID, err)
+		}
+	}
+	dispatcher.renewLease()
+
+	workers := config.AppConfig.WebhookWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	dispatcher.wg.Add(workers + 3)
+	go dispatcher.listen()
+	go dispatcher.scheduleRetries()
+	go dispatcher.holdLease()
+	for i := 0; i < workers; i++ {
+		go dispatcher.work()
+	}
+
+	return dispatcher, nil
+}
+
+// Register stores a new webhook for apiKey and starts watching its wallets.
+// The returned webhook is the only place its signing secret is exposed.
+func (d *WebhookDispatcher) Register(apiKey string, request models.WebhookRequest) (*models.Webhook, error) {
+	if err := ValidateWebhookURL(request.URL); err != nil {
+		return nil, err
+	}
+	for _, wallet := range request.Wallets {
+		if _, err := solana.PublicKeyFromBase58(wallet); err != nil {
+			return nil, fmt.Errorf("%w %s: %v", rpc.ErrInvalidAddress, wallet, err)
+		}
+	}
+
+	d.registerMutex.Lock()
+	defer d.registerMutex.Unlock()
+
+	if err := d.checkLimits(apiKey, request.Wallets); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := models.Webhook{
+		ID:        primitive.NewObjectID().Hex(),
+		APIKey:    apiKey,
+		URL:       request.URL,
+		Wallets:   request.Wallets,
+		Threshold: request.Threshold,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := d.store.CreateWebhook(&webhook); err != nil {
+		return nil, err
+	}
+	if err := d.add(webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// checkLimits enforces WebhookMaxPerKey and WebhookMaxWalletsPerKey against
+// the stored webhooks of apiKey, which include those registered on other replicas.
+func (d *WebhookDispatcher) checkLimits(apiKey string, wallets []string) error {
+	existing, err := d.store.ListWebhooks(apiKey)
+	if err != nil {
+		return err
+	}
+
+	if max := config.AppConfig.WebhookMaxPerKey; max > 0 && len(existing) >= max {
+		return fmt.Errorf("%w: maximum %d webhooks per API key", ErrWebhookLimit, max)
+	}
+
+	watched := make(map[string]bool)
+	for _, webhook := range existing {
+		for _, wallet := range webhook.Wallets {
+			watched[wallet] = true
+		}
+	}
+	for _, wallet := range wallets {
+		watched[wallet] = true
+	}
+	if max := config.AppConfig.WebhookMaxWalletsPerKey; max > 0 && len(watched) > max {
+		return fmt.Errorf("%w: maximum %d wallets watched per API key", ErrWebhookLimit, max)
+	}
+	return nil
+}
+
+// List returns the webhooks of apiKey without their secrets.
+func (d *WebhookDispatcher) List(apiKey string) ([]models.Webhook, error) {
+	webhooks, err := d.store.ListWebhooks(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (d *WebhookDispatcher) Delete(apiKey, id string) error {
+	d.registerMutex.Lock()
+	defer d.registerMutex.Unlock()
+
+	if err := d.store.DeleteWebhook(apiKey, id); err != nil {
+		return err
+	}
+	d.remove(id)
+	return nil
+}
+
+// Deliveries returns the most recent delivery log entries of one of apiKey's webhooks.
+func (d *WebhookDispatcher) Deliveries(apiKey, id string, limit int) ([]models.WebhookDelivery, error) {
+	d.mutex.Lock()
+	webhook, exists := d.webhooks[id]
+	d.mutex.Unlock()
+	if !exists || webhook.APIKey != apiKey {
+		return nil, ErrWebhookNotFound
+	}
+
+	return d.store.ListDeliveries(apiKey, id, limit)
+}
+
+// Close stops watching wallets and waits for in-flight deliveries. Pending
+// retries are abandoned; the lease expires on its own.
+func (d *WebhookDispatcher) Close() {
+	d.cancel()
+	d.sub.Close()
+	d.wg.Wait()
+}
+
+// add starts watching a webhook's wallets. Adding a known webhook does nothing.
+// The feed subscribes upstream without mutex held, so deliveries keep flowing
+// meanwhile; the webhook is rolled back if that fails.
+func (d *WebhookDispatcher) add(webhook models.Webhook) error {
+	d.watchMutex.Lock()
+	defer d.watchMutex.Unlock()
+
+	d.mutex.Lock()
+	if _, exists := d.webhooks[webhook.ID]; exists {
+		d.mutex.Unlock()
+		return nil
+	}
+
+	var added []string
+	for _, wallet := range webhook.Wallets {
+		if d.walletRefs[wallet] == 0 {
+			added = append(added, wallet)
+		}
+		d.walletRefs[wallet]++
+	}
+	d.webhooks[webhook.ID] = webhook
+	d.mutex.Unlock()
+
+	if err := d.sub.Add(added...); err != nil {
+		d.mutex.Lock()
+		delete(d.webhooks, webhook.ID)
+		d.release(webhook.Wallets)
+		d.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) remove(id string) {
+	d.watchMutex.Lock()
+	defer d.watchMutex.Unlock()
+
+	d.mutex.Lock()
+	webhook, exists := d.webhooks[id]
+	if !exists {
+		d.mutex.Unlock()
+		return
+	}
+	delete(d.webhooks, id)
+	released := d.release(webhook.Wallets)
+	d.mutex.Unlock()
+
+	d.sub.Remove(released...)
+}
+
+// release drops a reference to each wallet and returns the wallets nobody
+// watches anymore. d.mutex must be held.
+func (d *WebhookDispatcher) release(wallets []string) []string {
+	var released []string
+	for _, wallet := range wallets {
+		d.walletRefs[wallet]--
+		if d.walletRefs[wallet] <= 0 {
+			delete(d.walletRefs, wallet)
+			delete(d.balances, wallet)
+			released = append(released, wallet)
+		}
+	}
+	return released
+}
+
+// holdLease renews or tries to take the webhook lease a few times per lease
+// period and reloads the webhooks each time.
+func (d *WebhookDispatcher) holdLease() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			d.renewLease()
+			d.reload()
+		}
+	}
+}
+
+func (d *WebhookDispatcher) renewLease() {
+	leader, err := d.store.AcquireLease(webhookLeaseName, d.holder, d.leaseTTL)
+	if err != nil {
+		// Stand down rather than risk a second replica delivering the same events
+		log.Printf("Failed to renew webhook lease: %v", err)
+		leader = false
+	}
+	if leader != d.leader.Load() {
+		log.Printf("Webhook delivery lease %s: leader=%t", d.holder, leader)
+	}
+	d.leader.Store(leader)
+}
+
+// reload brings the watched webhooks in line with the store.
+func (d *WebhookDispatcher) reload() {
+	d.registerMutex.Lock()
+	defer d.registerMutex.Unlock()
+
+	webhooks, err := d.store.AllWebhooks()
+	if err != nil {
+		log.Printf("Failed to reload webhooks: %v", err)
+		return
+	}
+
+	stored := make(map[string]bool, len(webhooks))
+	for _, webhook := range webhooks {
+		stored[webhook.ID] = true
+		if err := d.add(webhook); err != nil {
+			log.Printf("Skipping webhook %s: %v", webhook.ID, err)
+		}
+	}
+
+	d.mutex.Lock()
+	var deleted []string
+	for id := range d.webhooks {
+		if !stored[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	d.mutex.Unlock()
+	for _, id := range deleted {
+		d.remove(id)
+	}
+}
+
+func (d *WebhookDispatcher) listen() {
+	defer d.wg.Done()
+
+	for update := range d.sub.Updates() {
+		// Balances are tracked on every replica so a new leader evaluates
+		// thresholds from the right previous balance
+		deliveries := d.match(update)
+		if !d.leader.Load() {
+			continue
+		}
+		for _, delivery := range deliveries {
+			if err := d.store.SaveDelivery(delivery); err != nil {
+				log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+			}
+
+			select {
+			case d.queue <- delivery:
+			case <-d.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// match records the new balance and builds a delivery for every webhook the change triggers.
+func (d *WebhookDispatcher) match(update models.BalanceUpdate) []*models.WebhookDelivery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var previous *float64
+	if balance, known := d.balances[update.Wallet]; known {
+		previous = &balance
+	}
+	d.balances[update.Wallet] = update.Balance
+
+	var deliveries []*models.WebhookDelivery
+	for _, webhook := range d.webhooks {
+		if !containsWallet(webhook.Wallets, update.Wallet) {
+			continue
+		}
+
+		eventType, triggered := evaluateThreshold(webhook.Threshold, previous, update.Balance)
+		if !triggered {
+			continue
+		}
+
+		now := time.Now().UTC()
+		id := primitive.NewObjectID().Hex()
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			ID:        id,
+			WebhookID: webhook.ID,
+			APIKey:    webhook.APIKey,
+			Event: models.WebhookEvent{
+				ID:              id,
+				WebhookID:       webhook.ID,
+				Type:            eventType,
+				Wallet:          update.Wallet,
+				Balance:         update.Balance,
+				PreviousBalance: previous,
+				Time:            update.Time,
+			},
+			Status:    WebhookStatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	return deliveries
+}
+
+// evaluateThreshold reports whether a change triggers a webhook and with which event type.
+// A threshold triggers when the balance crosses it. When the previous balance
+// is unknown a balance already past the threshold triggers as well.
+func evaluateThreshold(threshold *models.WebhookThreshold, previous *float64, balance float64) (string, bool) {
+	if threshold == nil || (threshold.Below == nil && threshold.Above == nil) {
+		return WebhookEventBalanceChanged, true
+	}
+
+	if threshold.Below != nil && balance < *threshold.Below && (previous == nil || *previous >= *threshold.Below) {
+		return WebhookEventBalanceBelow, true
+	}
+	if threshold.Above != nil && balance > *threshold.Above && (previous == nil || *previous <= *threshold.Above) {
+		return WebhookEventBalanceAbove, true
+	}
+	return "", false
+}
+
+func (d *WebhookDispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case delivery := <-d.queue:
+			d.deliver(delivery)
+		}
+	}
+}
+
+// deliver makes one attempt. A failed attempt is scheduled for a retry, or
+// dead-lettered once WebhookMaxAttempts is reached.
+func (d *WebhookDispatcher) deliver(delivery *models.WebhookDelivery) {
+	d.mutex.Lock()
+	webhook, exists := d.webhooks[delivery.WebhookID]
+	d.mutex.Unlock()
+	if !exists {
+		return
+	}
+
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		log.Printf("Failed to encode webhook event %s: %v", delivery.ID, err)
+		return
+	}
+
+	maxAttempts := config.AppConfig.WebhookMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	delivery.Attempts++
+	delivery.StatusCode, err = d.post(webhook, delivery.ID, body)
+	delivery.UpdatedAt = time.Now().UTC()
+	delivery.NextAttemptAt = nil
+	if err == nil {
+		delivery.Status = WebhookStatusDelivered
+		delivery.Error = ""
+		d.save(delivery)
+		return
+	}
+	delivery.Error = err.Error()
+
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = WebhookStatusFailed
+		d.save(delivery)
+		if err := d.store.SaveDeadLetter(delivery); err != nil {
+			log.Printf("Failed to dead-letter webhook delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	// The backoff doubles after each failed attempt
+	backoff := time.Duration(config.AppConfig.WebhookRetryBackoffMs) * time.Millisecond << (delivery.Attempts - 1)
+	next := delivery.UpdatedAt.Add(backoff)
+	delivery.NextAttemptAt = &next
+	d.save(delivery)
+	d.scheduleRetry(delivery)
+}
+
+func (d *WebhookDispatcher) scheduleRetry(delivery *models.WebhookDelivery) {
+	d.retryMutex.Lock()
+	heap.Push(&d.retries, delivery)
+	d.retryMutex.Unlock()
+
+	select {
+	case d.retryWake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleRetries hands deliveries back to the workers once their next attempt is due.
+func (d *WebhookDispatcher) scheduleRetries() {
+	defer d.wg.Done()
+
+	for {
+		var due []*models.WebhookDelivery
+		wait := time.Duration(-1)
+
+		d.retryMutex.Lock()
+		now := time.Now()
+		for d.retries.Len() > 0 && !d.retries[0].NextAttemptAt.After(now) {
+			due = append(due, heap.Pop(&d.retries).(*models.WebhookDelivery))
+		}
+		if d.retries.Len() > 0 {
+			wait = d.retries[0].NextAttemptAt.Sub(now)
+		}
+		d.retryMutex.Unlock()
+
+		for _, delivery := range due {
+			select {
+			case d.queue <- delivery:
+			case <-d.ctx.Done():
+				return
+			}
+		}
+		if len(due) > 0 {
+			continue
+		}
+
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			fired = timer.C
+		}
+		select {
+		case <-d.ctx.Done():
+		case <-d.retryWake:
+		case <-fired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if d.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// post sends one attempt and returns the response status code. Any non-2xx response is an error.
+func (d *WebhookDispatcher) post(webhook models.Webhook, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nova-Delivery", deliveryID)
+	req.Header.Set("X-Nova-Signature", SignWebhookPayload(webhook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) save(delivery *models.WebhookDelivery) {
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// SignWebhookPayload returns the X-Nova-Signature header value for body: the
+// hex encoded HMAC-SHA256 of the raw body keyed with the webhook secret.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func containsWallet(wallets []string, wallet string) bool {
+	for _, w := range wallets {
+		if w == wallet {
+			return true
+		}
+	}
+	return false
+}
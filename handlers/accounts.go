@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"nova-api/config"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+type AccountService interface {
+	GetAccounts(addresses []string, encoding string, slice *models.DataSlice) ([]models.AccountInfo, error)
+}
+
+type AccountHandler struct {
+	accountService AccountService
+}
+
+func NewAccountHandler(accountService AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// AccountsHandler returns the full account info of up to MaxWalletsPerRequest addresses.
+func (ah *AccountHandler) AccountsHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.AccountsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	if len(request.Addresses) == 0 {
+		writeError(w, http.StatusBadRequest, "Addresses array cannot be empty")
+		return
+	}
+	if len(request.Addresses) > config.AppConfig.MaxWalletsPerRequest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many addresses requested. Maximum %d addresses allowed per request", config.AppConfig.MaxWalletsPerRequest))
+		return
+	}
+
+	switch request.Encoding {
+	case "", rpc.EncodingBase64:
+	case rpc.EncodingJSONParsed:
+		if request.DataSlice != nil {
+			writeError(w, http.StatusBadRequest, "data_slice cannot be used with jsonParsed")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "encoding must be base64 or jsonParsed")
+		return
+	}
+
+	accounts, err := ah.accountService.GetAccounts(request.Addresses, request.Encoding, request.DataSlice)
+	if err != nil {
+		log.Printf("Failed to get accounts: %v", err)
+		writeError(w, http.StatusBadGateway, "Failed to get accounts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: accounts, Success: true})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+type AddressService interface {
+	Validate(addresses []string, offline bool) []models.AddressValidation
+}
+
+type AddressHandler struct {
+	addressService AddressService
+}
+
+func NewAddressHandler(addressService AddressService) *AddressHandler {
+	return &AddressHandler{
+		addressService: addressService,
+	}
+}
+
+// ValidateAddressHandler validates and classifies up to MaxWalletsPerRequest addresses.
+func (ah *AddressHandler) ValidateAddressHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.ValidateAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	if len(request.Addresses) == 0 {
+		writeError(w, http.StatusBadRequest, "Addresses array cannot be empty")
+		return
+	}
+	if len(request.Addresses) > config.AppConfig.MaxWalletsPerRequest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many addresses requested. Maximum %d addresses allowed per request", config.AppConfig.MaxWalletsPerRequest))
+		return
+	}
+
+	validations := ah.addressService.Validate(request.Addresses, request.Offline)
+	writeJSON(w, http.StatusOK, models.Response{Data: validations, Success: true})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"nova-api/data"
+	"nova-api/middleware"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gorilla/mux"
+)
+
+type AlertService interface {
+	Register(apiKey string, request models.AlertRuleRequest) (*models.AlertRule, error)
+	List(apiKey string) ([]models.AlertRule, error)
+	Delete(apiKey, id string) error
+}
+
+type AlertHandler struct {
+	alertService AlertService
+}
+
+func NewAlertHandler(alertService AlertService) *AlertHandler {
+	return &AlertHandler{
+		alertService: alertService,
+	}
+}
+
+func (ah *AlertHandler) CreateAlertHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	if request.Wallet == "" {
+		writeError(w, http.StatusBadRequest, "wallet cannot be empty")
+		return
+	}
+
+	rule, err := ah.alertService.Register(middleware.APIKeyFromContext(r.Context()), request)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidAlertRule) || errors.Is(err, rpc.ErrInvalidAddress) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, data.ErrAlertLimit) {
+			writeError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		log.Printf("Failed to create alert rule: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create alert rule")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.Response{Data: rule, Success: true})
+}
+
+func (ah *AlertHandler) ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := ah.alertService.List(middleware.APIKeyFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Failed to list alert rules: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list alert rules")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: rules, Success: true})
+}
+
+func (ah *AlertHandler) DeleteAlertHandler(w http.ResponseWriter, r *http.Request) {
+	err := ah.alertService.Delete(middleware.APIKeyFromContext(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, data.ErrAlertRuleNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Failed to delete alert rule: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to delete alert rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Success: true})
+}
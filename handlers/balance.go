@@ -2,19 +2,48 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"nova-api/config"
+	"nova-api/data"
 	"nova-api/models"
+	"nova-api/rpc"
 )
 
 type BalanceService interface {
 	GetBalance(wallet string) (float64, error)
 }
 
+// StakeService reports the stake accounts of wallets for requests with include_staked.
+type StakeService interface {
+	Summaries(wallets []string, liquid []float64) ([]*models.StakeSummary, []error)
+}
+
+// TokenService lists the SPL token balances of a wallet for requests with include_tokens.
+type TokenService interface {
+	Holdings(walletAddress string) ([]models.TokenBalance, error)
+}
+
+// NameService resolves .sol domains given in place of wallet addresses.
+type NameService interface {
+	Resolve(domain string) (string, error)
+}
+
+// PriceService values balances for requests with quote=usd.
+type PriceService interface {
+	USDPrice(mint string) (float64, error)
+}
+
 type BalanceHandler struct {
 	balanceService BalanceService
+	stakeService   StakeService
+	tokenService   TokenService
+	priceService   PriceService
+	nameService    NameService
+	rentService    RentService
+	walletGroups   WalletGroupService
 }
 
 func NewBalanceHandler(balanceService BalanceService) *BalanceHandler {
@@ -23,6 +52,36 @@ func NewBalanceHandler(balanceService BalanceService) *BalanceHandler {
 	}
 }
 
+// SetStakeService enables include_staked. Without it the option is ignored.
+func (bh *BalanceHandler) SetStakeService(stakeService StakeService) {
+	bh.stakeService = stakeService
+}
+
+// SetTokenService enables include_tokens. Without it the option is ignored.
+func (bh *BalanceHandler) SetTokenService(tokenService TokenService) {
+	bh.tokenService = tokenService
+}
+
+// SetNameService enables .sol domains in the wallets of a request. Without it they fail to resolve.
+func (bh *BalanceHandler) SetNameService(nameService NameService) {
+	bh.nameService = nameService
+}
+
+// SetRentService enables include_rent. Without it the option is ignored.
+func (bh *BalanceHandler) SetRentService(rentService RentService) {
+	bh.rentService = rentService
+}
+
+// SetWalletGroups enables requests by group_id.
+func (bh *BalanceHandler) SetWalletGroups(walletGroups WalletGroupService) {
+	bh.walletGroups = walletGroups
+}
+
+// SetPriceService enables quote=usd. Without it every quote fails.
+func (bh *BalanceHandler) SetPriceService(priceService PriceService) {
+	bh.priceService = priceService
+}
+
 func (bh *BalanceHandler) GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
 	var request models.BalanceRequest
 
@@ -36,7 +95,14 @@ func (bh *BalanceHandler) GetBalanceHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if len(request.Wallets) == 0 {
+	var group *models.WalletGroup
+	if request.GroupID != "" {
+		var ok bool
+		if group, ok = groupWallets(w, r, bh.walletGroups, request.GroupID, request.Wallets); !ok {
+			return
+		}
+		request.Wallets = group.Wallets
+	} else if len(request.Wallets) == 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		response := models.Response{
 			Error: "Wallets array cannot be empty",
@@ -46,7 +112,7 @@ func (bh *BalanceHandler) GetBalanceHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if len(request.Wallets) > config.AppConfig.MaxWalletsPerRequest {
+	if group == nil && len(request.Wallets) > config.AppConfig.MaxWalletsPerRequest {
 		w.WriteHeader(http.StatusBadRequest)
 		response := models.Response{
 			Error: fmt.Sprintf("Too many wallets requested. Maximum %d wallets allowed per request", config.AppConfig.MaxWalletsPerRequest),
@@ -56,26 +122,235 @@ func (bh *BalanceHandler) GetBalanceHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	quote := r.URL.Query().Get("quote")
+	if quote != "" && quote != "usd" {
+		writeError(w, http.StatusBadRequest, "quote must be usd")
+		return
+	}
+
 	balances := make([]models.WalletBalance, 0, len(request.Wallets))
 	for _, wallet := range request.Wallets {
-		balance, err := bh.balanceService.GetBalance(wallet)
+		address, err := bh.resolve(wallet)
 		if err != nil {
 			balances = append(balances, models.WalletBalance{
 				Wallet: wallet,
 				Error:  err.Error(),
 			})
-		} else {
+			continue
+		}
+		resolved := ""
+		if address != wallet {
+			resolved = address
+		}
+
+		balance, err := bh.balanceService.GetBalance(address)
+		if err != nil {
 			balances = append(balances, models.WalletBalance{
-				Wallet:  wallet,
-				Balance: balance,
+				Wallet:          wallet,
+				ResolvedAddress: resolved,
+				Error:           err.Error(),
 			})
+		} else {
+			walletBalance := models.WalletBalance{
+				Wallet:          wallet,
+				ResolvedAddress: resolved,
+				Balance:         balance,
+			}
+			if request.IncludeTokens && bh.tokenService != nil {
+				if tokens, err := bh.tokenService.Holdings(address); err != nil {
+					walletBalance.TokensError = err.Error()
+				} else {
+					walletBalance.Tokens = tokens
+				}
+			}
+			balances = append(balances, walletBalance)
+		}
+	}
+
+	if request.IncludeStaked && bh.stakeService != nil {
+		bh.addStakeSummaries(balances)
+	}
+	if request.IncludeRent && bh.rentService != nil {
+		bh.addRentStatus(balances)
+	}
+	if quote == "usd" {
+		bh.quoteUSD(balances)
+	}
+
+	var result interface{} = balances
+	if group != nil {
+		result = models.GroupBalances{
+			Group:   models.WalletGroupRef{ID: group.ID, Name: group.Name},
+			Wallets: balances,
+			Totals:  BalanceTotals(balances, request.IncludeStaked && bh.stakeService != nil, quote == "usd"),
 		}
 	}
 
 	response := models.Response{
-		Data:    balances,
+		Data:    result,
 		Success: true,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// addStakeSummaries looks up the stake accounts of every wallet whose balance
+// was read. A failed stake lookup does not hide the balance that was read successfully.
+func (bh *BalanceHandler) addStakeSummaries(balances []models.WalletBalance) {
+	indexes := []int{}
+	addresses := []string{}
+	liquid := []float64{}
+	for i, balance := range balances {
+		if balance.Error != "" {
+			continue
+		}
+		address := balance.Wallet
+		if balance.ResolvedAddress != "" {
+			address = balance.ResolvedAddress
+		}
+		indexes = append(indexes, i)
+		addresses = append(addresses, address)
+		liquid = append(liquid, balance.Balance)
+	}
+	if len(addresses) == 0 {
+		return
+	}
+
+	summaries, errs := bh.stakeService.Summaries(addresses, liquid)
+	for j, i := range indexes {
+		if errs[j] != nil {
+			balances[i].Staked = &models.StakeSummary{Accounts: []models.StakeAccount{}, Total: liquid[j], Error: errs[j].Error()}
+			continue
+		}
+		balances[i].Staked = summaries[j]
+	}
+}
+
+// BalanceTotals adds up balances, with their staked SOL and USD values when requested.
+func BalanceTotals(balances []models.WalletBalance, includeStaked, quoted bool) models.BalanceTotals {
+	totals := models.BalanceTotals{}
+	staked, usd := 0.0, 0.0
+	for _, balance := range balances {
+		if balance.Error != "" {
+			totals.Partial = true
+			continue
+		}
+		totals.Balance += balance.Balance
+
+		if includeStaked {
+			if balance.Staked == nil || balance.Staked.Error != "" {
+				totals.Partial = true
+			} else {
+				staked += balance.Staked.Staked
+			}
+		}
+		if quoted {
+			if balance.USD == nil {
+				totals.Partial = true
+			} else {
+				usd += *balance.USD
+				if balance.Staked != nil && balance.Staked.StakedUSD != nil {
+					usd += *balance.Staked.StakedUSD
+				}
+			}
+		}
+	}
+
+	totals.Total = totals.Balance + staked
+	if includeStaked {
+		totals.Staked = &staked
+	}
+	if quoted {
+		totals.USD = &usd
+	}
+	return totals
+}
+
+// addRentStatus reports whether each wallet holds its rent-exempt minimum,
+// reading all of the wallets' accounts at once.
+func (bh *BalanceHandler) addRentStatus(balances []models.WalletBalance) {
+	indexes := []int{}
+	addresses := []string{}
+	for i, balance := range balances {
+		if balance.Error != "" {
+			continue
+		}
+		address := balance.Wallet
+		if balance.ResolvedAddress != "" {
+			address = balance.ResolvedAddress
+		}
+		indexes = append(indexes, i)
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		return
+	}
+
+	statuses, err := bh.rentService.RentStatus(addresses)
+	for j, i := range indexes {
+		switch {
+		case err != nil:
+			balances[i].RentError = err.Error()
+		case j < len(statuses) && statuses[j] != nil:
+			balances[i].Rent = statuses[j]
+		default:
+			balances[i].RentError = "account could not be read"
+		}
+	}
+}
+
+// resolve returns the owner address of .sol domains and any other wallet as it is.
+func (bh *BalanceHandler) resolve(wallet string) (string, error) {
+	if !rpc.IsSOLDomain(wallet) {
+		return wallet, nil
+	}
+	if bh.nameService == nil {
+		return "", errors.New(".sol domains are not supported")
+	}
+	return bh.nameService.Resolve(wallet)
+}
+
+// quoteUSD adds USD values to the balances, reading each price once per request.
+// Tokens without a price feed are left without a USD value.
+func (bh *BalanceHandler) quoteUSD(balances []models.WalletBalance) {
+	prices := map[string]float64{}
+	errs := map[string]error{}
+	price := func(mint string) (float64, error) {
+		if bh.priceService == nil {
+			return 0, errors.New("USD quotes are not available")
+		}
+		if _, done := prices[mint]; !done {
+			prices[mint], errs[mint] = bh.priceService.USDPrice(mint)
+		}
+		return prices[mint], errs[mint]
+	}
+	usd := func(amount, price float64) *float64 {
+		value := amount * price
+		return &value
+	}
+
+	for i := range balances {
+		balance := &balances[i]
+		if balance.Error != "" {
+			continue
+		}
+
+		for j := range balance.Tokens {
+			token := &balance.Tokens[j]
+			if tokenPrice, err := price(token.Mint); err == nil {
+				token.USD = usd(token.Amount, tokenPrice)
+			}
+		}
+
+		solPrice, err := price(data.NativeMint)
+		if err != nil {
+			balance.QuoteError = err.Error()
+			continue
+		}
+		balance.USD = usd(balance.Balance, solPrice)
+		if balance.Staked != nil && balance.Staked.Error == "" {
+			balance.Staked.StakedUSD = usd(balance.Staked.Staked, solPrice)
+			balance.Staked.TotalUSD = usd(balance.Staked.Total, solPrice)
+		}
+	}
+}
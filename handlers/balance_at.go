@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nova-api/data"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+type PastBalanceService interface {
+	BalanceAtSlot(walletAddress string, slot uint64) (*models.PastBalance, error)
+	BalanceAtTime(walletAddress string, at time.Time) (*models.PastBalance, error)
+}
+
+type PastBalanceHandler struct {
+	pastBalanceService PastBalanceService
+}
+
+func NewPastBalanceHandler(pastBalanceService PastBalanceService) *PastBalanceHandler {
+	return &PastBalanceHandler{
+		pastBalanceService: pastBalanceService,
+	}
+}
+
+// BalanceAtHandler returns the balance of ?wallet= as of ?slot= or ?timestamp= (RFC 3339 or unix seconds).
+func (ph *PastBalanceHandler) BalanceAtHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	wallet := query.Get("wallet")
+	if wallet == "" {
+		writeError(w, http.StatusBadRequest, "wallet cannot be empty")
+		return
+	}
+
+	slotValue, timestampValue := query.Get("slot"), query.Get("timestamp")
+	if (slotValue == "") == (timestampValue == "") {
+		writeError(w, http.StatusBadRequest, "Exactly one of slot or timestamp is required")
+		return
+	}
+
+	var result *models.PastBalance
+	var err error
+	if slotValue != "" {
+		slot, parseErr := strconv.ParseUint(slotValue, 10, 64)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, "Invalid slot")
+			return
+		}
+		result, err = ph.pastBalanceService.BalanceAtSlot(wallet, slot)
+	} else {
+		at, parseErr := parseHistoryTime(timestampValue, time.Time{})
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, "Invalid timestamp: "+parseErr.Error())
+			return
+		}
+		result, err = ph.pastBalanceService.BalanceAtTime(wallet, at)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, rpc.ErrInvalidAddress), errors.Is(err, data.ErrNotFinalized):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, data.ErrHistoryTooLong):
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			log.Printf("Failed to reconstruct balance of wallet %s: %v", wallet, err)
+			writeError(w, http.StatusBadGateway, "Failed to reconstruct balance from transaction history")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: result, Success: true})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nova-api/config"
+	"nova-api/middleware"
+	"nova-api/models"
+)
+
+// EventStreamHandler serves balance changes as Server-Sent Events for clients
+// that cannot use WebSockets. It sends the current balance of every requested
+// wallet and then each change. A client reconnecting with Last-Event-ID gets
+// the changes it missed instead, as long as they are still in the replay buffer.
+func (sh *StreamHandler) EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	apiKey := middleware.APIKeyFromContext(r.Context())
+
+	wallets := parseWalletList(r.URL.Query().Get("wallets"))
+	if len(wallets) == 0 {
+		writeError(w, http.StatusBadRequest, "wallets query parameter cannot be empty")
+		return
+	}
+	if len(wallets) > config.AppConfig.MaxWalletsPerRequest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many wallets requested. Maximum %d wallets allowed per request", config.AppConfig.MaxWalletsPerRequest))
+		return
+	}
+
+	var lastEventID uint64
+	resume := false
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid Last-Event-ID header")
+			return
+		}
+		lastEventID = id
+		resume = true
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	if !sh.reserve(apiKey, len(wallets)) {
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many subscriptions. Maximum %d wallets allowed per API key", config.AppConfig.StreamMaxWalletsPerKey))
+		return
+	}
+	defer sh.release(apiKey, len(wallets))
+
+	// Subscribe before reading the replay buffer so no change falls in between.
+	// Anything delivered twice is skipped by its ID below.
+	sub := sh.feed.Subscribe(config.AppConfig.StreamBufferSize)
+	defer sub.Close()
+	if err := sub.Add(wallets...); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var sent uint64
+	replayed := false
+	if resume {
+		missed, complete := sh.feed.Since(lastEventID, wallets)
+		if complete {
+			replayed = true
+			sent = lastEventID
+			for _, update := range missed {
+				if writeEvent(w, update) != nil {
+					return
+				}
+				sent = update.ID
+			}
+		}
+	}
+
+	if !replayed {
+		// Snapshot events carry the current feed position so a client that
+		// reconnects right away resumes from here
+		sent = sh.feed.LastID()
+		for _, wallet := range wallets {
+			balance, err := sh.balanceService.GetBalance(wallet)
+			if err != nil {
+				log.Printf("Failed to get initial balance for wallet %s: %v", wallet, err)
+				continue
+			}
+			sh.feed.Seed(wallet, balance)
+
+			update := models.BalanceUpdate{ID: sent, Wallet: wallet, Balance: balance, Time: time.Now().UTC()}
+			if writeEvent(w, update) != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.Duration(config.AppConfig.StreamHeartbeatInterval) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = 30 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			if update.ID <= sent {
+				continue
+			}
+			if writeEvent(w, update) != nil {
+				return
+			}
+			sent = update.ID
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, update models.BalanceUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: balance\ndata: %s\n\n", update.ID, payload)
+	return err
+}
+
+// parseWalletList splits a comma-separated wallet list, dropping blanks and duplicates.
+func parseWalletList(value string) []string {
+	var wallets []string
+	seen := make(map[string]bool)
+	for _, wallet := range strings.Split(value, ",") {
+		wallet = strings.TrimSpace(wallet)
+		if wallet == "" || seen[wallet] {
+			continue
+		}
+		seen[wallet] = true
+		wallets = append(wallets, wallet)
+	}
+	return wallets
+}
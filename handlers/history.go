@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nova-api/data"
+	"nova-api/models"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	defaultHistoryInterval = time.Hour
+	defaultHistoryRange    = 7 * 24 * time.Hour
+	maxHistoryPoints       = 2000
+)
+
+type HistoryHandler struct {
+	store data.SnapshotStore
+}
+
+func NewHistoryHandler(store data.SnapshotStore) *HistoryHandler {
+	return &HistoryHandler{
+		store: store,
+	}
+}
+
+// BalanceHistoryHandler returns the snapshots of ?wallet= between ?from= and ?to=
+// (RFC 3339 or unix seconds) downsampled to ?interval= buckets. ?aggregate=minmax
+// returns the minimum and maximum of each bucket instead of its last balance.
+func (hh *HistoryHandler) BalanceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	wallet := query.Get("wallet")
+	if _, err := solana.PublicKeyFromBase58(wallet); err != nil {
+		writeError(w, http.StatusBadRequest, "wallet must be a valid address")
+		return
+	}
+
+	now := time.Now().UTC()
+	to, err := parseHistoryTime(query.Get("to"), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid to: "+err.Error())
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-defaultHistoryRange))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid from: "+err.Error())
+		return
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	interval, err := parseHistoryInterval(query.Get("interval"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid interval: "+err.Error())
+		return
+	}
+	if to.Sub(from)/interval > maxHistoryPoints {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many points requested. Maximum %d intervals allowed per request", maxHistoryPoints))
+		return
+	}
+
+	aggregate := query.Get("aggregate")
+	if aggregate == "" {
+		aggregate = data.HistoryAggregateLast
+	}
+	if aggregate != data.HistoryAggregateLast && aggregate != data.HistoryAggregateMinMax {
+		writeError(w, http.StatusBadRequest, "aggregate must be last or minmax")
+		return
+	}
+
+	snapshots, err := hh.store.BalanceHistory(wallet, from, to)
+	if err != nil {
+		log.Printf("Failed to load balance history for wallet %s: %v", wallet, err)
+		writeError(w, http.StatusInternalServerError, "Failed to load balance history")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{
+		Data:    data.Downsample(snapshots, from, interval, aggregate),
+		Success: true,
+	})
+}
+
+func parseHistoryTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 or unix seconds")
+	}
+	return parsed.UTC(), nil
+}
+
+// parseHistoryInterval accepts Go durations such as 15m or 1h, and days such as 1d
+func parseHistoryInterval(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultHistoryInterval, nil
+	}
+
+	var interval time.Duration
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("expected a duration such as 15m, 1h or 1d")
+		}
+		interval = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("expected a duration such as 15m, 1h or 1d")
+		}
+		interval = parsed
+	}
+
+	if interval < time.Minute {
+		return 0, fmt.Errorf("must be at least 1m")
+	}
+	return interval, nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+type NetworkService interface {
+	Status(accounts []string) (*models.NetworkStatus, error)
+}
+
+type NetworkHandler struct {
+	networkService NetworkService
+}
+
+func NewNetworkHandler(networkService NetworkService) *NetworkHandler {
+	return &NetworkHandler{
+		networkService: networkService,
+	}
+}
+
+// NetworkHandler returns the cluster's status. ?accounts= is a comma separated
+// list of accounts the priority fees are scoped to.
+func (nh *NetworkHandler) NetworkHandler(w http.ResponseWriter, r *http.Request) {
+	var accounts []string
+	if value := r.URL.Query().Get("accounts"); value != "" {
+		for _, account := range strings.Split(value, ",") {
+			account = strings.TrimSpace(account)
+			if _, err := solana.PublicKeyFromBase58(account); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid account address %q", account))
+				return
+			}
+			accounts = append(accounts, account)
+		}
+	}
+	if len(accounts) > rpc.MaxPriorityFeeAccounts {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many accounts requested. Maximum %d accounts allowed", rpc.MaxPriorityFeeAccounts))
+		return
+	}
+
+	status, err := nh.networkService.Status(accounts)
+	if err != nil {
+		if errors.Is(err, rpc.ErrInvalidAddress) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Failed to load network status: %v", err)
+		writeError(w, http.StatusBadGateway, "Failed to load network status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: status, Success: true})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	defaultNFTsLimit = 50
+	maxNFTsLimit     = 100
+	maxNFTsPage      = 10000
+)
+
+type NFTService interface {
+	NFTs(walletAddress string, collections []string, page, limit int) (*models.NFTPage, error)
+}
+
+type NFTHandler struct {
+	nftService NFTService
+}
+
+func NewNFTHandler(nftService NFTService) *NFTHandler {
+	return &NFTHandler{
+		nftService: nftService,
+	}
+}
+
+// NFTsHandler returns a page of ?wallet='s NFTs. ?page= starts at 1, ?limit= is
+// the page size and each ?collection= keeps the NFTs of that collection.
+func (nh *NFTHandler) NFTsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	wallet := query.Get("wallet")
+	if wallet == "" {
+		writeError(w, http.StatusBadRequest, "wallet cannot be empty")
+		return
+	}
+
+	page := 1
+	if value := query.Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxNFTsPage {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("page must be between 1 and %d", maxNFTsPage))
+			return
+		}
+		page = parsed
+	}
+
+	limit := defaultNFTsLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxNFTsLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxNFTsLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	collections := query["collection"]
+	for _, collection := range collections {
+		if _, err := solana.PublicKeyFromBase58(collection); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid collection address %q", collection))
+			return
+		}
+	}
+
+	nfts, err := nh.nftService.NFTs(wallet, collections, page, limit)
+	if err != nil {
+		if errors.Is(err, rpc.ErrInvalidAddress) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Failed to list NFTs of wallet %s: %v", wallet, err)
+		writeError(w, http.StatusBadGateway, "Failed to load NFTs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: nfts, Success: true})
+}
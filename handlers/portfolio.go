@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"nova-api/config"
+	"nova-api/models"
+)
+
+type PortfolioService interface {
+	Portfolio(wallets []string) *models.Portfolio
+}
+
+type PortfolioHandler struct {
+	portfolioService PortfolioService
+	walletGroups     WalletGroupService
+}
+
+func NewPortfolioHandler(portfolioService PortfolioService) *PortfolioHandler {
+	return &PortfolioHandler{
+		portfolioService: portfolioService,
+	}
+}
+
+// SetWalletGroups enables requests by group_id.
+func (ph *PortfolioHandler) SetWalletGroups(walletGroups WalletGroupService) {
+	ph.walletGroups = walletGroups
+}
+
+// PortfolioHandler returns the SOL, tokens, staked SOL and NFT counts of up to
+// MaxWalletsPerRequest wallets, or of a wallet group, with their totals. Failed sections are reported
+// per wallet, so the response is always 200 for a valid request.
+func (ph *PortfolioHandler) PortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.PortfolioRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	var group *models.WalletGroup
+	if request.GroupID != "" {
+		var ok bool
+		if group, ok = groupWallets(w, r, ph.walletGroups, request.GroupID, request.Wallets); !ok {
+			return
+		}
+		request.Wallets = group.Wallets
+	} else if len(request.Wallets) == 0 {
+		writeError(w, http.StatusBadRequest, "Wallets array cannot be empty")
+		return
+	} else if len(request.Wallets) > config.AppConfig.MaxWalletsPerRequest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many wallets requested. Maximum %d wallets allowed per request", config.AppConfig.MaxWalletsPerRequest))
+		return
+	}
+
+	portfolio := ph.portfolioService.Portfolio(request.Wallets)
+	if group != nil {
+		portfolio.Group = &models.WalletGroupRef{ID: group.ID, Name: group.Name}
+	}
+	writeJSON(w, http.StatusOK, models.Response{Data: portfolio, Success: true})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+const maxRentSizes = 100
+
+type RentService interface {
+	Minimums(sizes []uint64) (*models.RentExemptions, error)
+	RentStatus(addresses []string) ([]*models.RentStatus, error)
+}
+
+type RentHandler struct {
+	rentService RentService
+}
+
+func NewRentHandler(rentService RentService) *RentHandler {
+	return &RentHandler{
+		rentService: rentService,
+	}
+}
+
+// RentExemptionHandler returns the rent-exempt minimum of each of ?sizes=, a
+// comma separated list of account data sizes in bytes.
+func (rh *RentHandler) RentExemptionHandler(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("sizes")
+	if value == "" {
+		writeError(w, http.StatusBadRequest, "sizes cannot be empty")
+		return
+	}
+
+	fields := strings.Split(value, ",")
+	if len(fields) > maxRentSizes {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many sizes requested. Maximum %d sizes allowed", maxRentSizes))
+		return
+	}
+	sizes := make([]uint64, 0, len(fields))
+	for _, field := range fields {
+		size, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil || size > rpc.MaxAccountDataSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid size %q, expected bytes between 0 and %d", field, rpc.MaxAccountDataSize))
+			return
+		}
+		sizes = append(sizes, size)
+	}
+
+	exemptions, err := rh.rentService.Minimums(sizes)
+	if err != nil {
+		log.Printf("Failed to load rent-exempt minimums: %v", err)
+		writeError(w, http.StatusBadGateway, "Failed to load rent-exempt minimums")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: exemptions, Success: true})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/middleware"
+	"nova-api/models"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamWriteWait       = 10 * time.Second
+	streamMaxMessageBytes = 64 * 1024
+	streamOutgoingBuffer  = 32
+)
+
+var upgrader = websocket.Upgrader{
+	// Browsers do not apply CORS to WebSockets, so the origin is checked against the same allow-list here
+	CheckOrigin: func(r *http.Request) bool { return middleware.OriginAllowed(r.Header.Get("Origin")) },
+}
+
+// StreamHandler pushes balance changes to clients. Every API key may watch at
+// most StreamMaxWalletsPerKey wallets across all of its connections.
+type StreamHandler struct {
+	feed           *data.BalanceFeed
+	balanceService BalanceService
+
+	mutex         sync.Mutex
+	walletsPerKey map[string]int
+}
+
+func NewStreamHandler(feed *data.BalanceFeed, balanceService BalanceService) *StreamHandler {
+	return &StreamHandler{
+		feed:           feed,
+		balanceService: balanceService,
+		walletsPerKey:  make(map[string]int),
+	}
+}
+
+// WebSocketHandler upgrades the connection and accepts subscribe and unsubscribe
+// requests. Balances are sent once on subscribe and again whenever they change.
+func (sh *StreamHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	apiKey := middleware.APIKeyFromContext(r.Context())
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client
+		return
+	}
+	defer conn.Close()
+
+	heartbeat := time.Duration(config.AppConfig.StreamHeartbeatInterval) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = 30 * time.Second
+	}
+	sub := sh.feed.Subscribe(config.AppConfig.StreamBufferSize)
+	outgoing := make(chan models.StreamMessage, streamOutgoingBuffer)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sh.writeLoop(conn, sub, outgoing, done, heartbeat)
+	}()
+
+	defer func() {
+		sh.release(apiKey, sub.Len())
+		sub.Close()
+		close(done)
+		wg.Wait()
+	}()
+
+	send := func(message models.StreamMessage) bool {
+		select {
+		case outgoing <- message:
+			return true
+		default:
+			// The client is not reading its messages, so stop serving it
+			conn.Close()
+			return false
+		}
+	}
+
+	conn.SetReadLimit(streamMaxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	})
+
+	for {
+		var request models.StreamRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+
+		var ok bool
+		switch request.Action {
+		case "subscribe":
+			ok = sh.subscribe(apiKey, sub, request.Wallets, send)
+		case "unsubscribe":
+			ok = sh.unsubscribe(apiKey, sub, request.Wallets, send)
+		default:
+			ok = send(models.StreamMessage{Type: "error", Error: fmt.Sprintf("Unknown action %q", request.Action)})
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func (sh *StreamHandler) subscribe(apiKey string, sub *data.FeedSubscription, wallets []string, send func(models.StreamMessage) bool) bool {
+	var added []string
+	seen := make(map[string]bool)
+	for _, wallet := range wallets {
+		if !seen[wallet] && !sub.Has(wallet) {
+			seen[wallet] = true
+			added = append(added, wallet)
+		}
+	}
+
+	if !sh.reserve(apiKey, len(added)) {
+		return send(models.StreamMessage{
+			Type:  "error",
+			Error: fmt.Sprintf("Too many subscriptions. Maximum %d wallets allowed per API key", config.AppConfig.StreamMaxWalletsPerKey),
+		})
+	}
+
+	if err := sub.Add(added...); err != nil {
+		sh.release(apiKey, len(added))
+		return send(models.StreamMessage{Type: "error", Error: err.Error()})
+	}
+
+	if !send(models.StreamMessage{Type: "subscribed", Data: added}) {
+		return false
+	}
+
+	for _, wallet := range added {
+		balance, err := sh.balanceService.GetBalance(wallet)
+		if err != nil {
+			log.Printf("Failed to get initial balance for wallet %s: %v", wallet, err)
+			continue
+		}
+		sh.feed.Seed(wallet, balance)
+
+		update := models.BalanceUpdate{Wallet: wallet, Balance: balance, Time: time.Now().UTC()}
+		if !send(models.StreamMessage{Type: "balance", Data: update}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (sh *StreamHandler) unsubscribe(apiKey string, sub *data.FeedSubscription, wallets []string, send func(models.StreamMessage) bool) bool {
+	var removed []string
+	for _, wallet := range wallets {
+		if sub.Has(wallet) {
+			sub.Remove(wallet)
+			removed = append(removed, wallet)
+		}
+	}
+	sh.release(apiKey, len(removed))
+
+	return send(models.StreamMessage{Type: "unsubscribed", Data: removed})
+}
+
+func (sh *StreamHandler) writeLoop(conn *websocket.Conn, sub *data.FeedSubscription, outgoing <-chan models.StreamMessage, done <-chan struct{}, heartbeat time.Duration) {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case message := <-outgoing:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			err = conn.WriteJSON(message)
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			err = conn.WriteJSON(models.StreamMessage{Type: "balance", Data: update})
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait))
+		}
+
+		if err != nil {
+			// Closing unblocks the read loop, which then cleans up
+			conn.Close()
+			return
+		}
+	}
+}
+
+// reserve counts n more wallets against the API key's limit, failing if it would be exceeded.
+func (sh *StreamHandler) reserve(apiKey string, n int) bool {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if sh.walletsPerKey[apiKey]+n > config.AppConfig.StreamMaxWalletsPerKey {
+		return false
+	}
+	sh.walletsPerKey[apiKey] += n
+	return true
+}
+
+func (sh *StreamHandler) release(apiKey string, n int) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	sh.walletsPerKey[apiKey] -= n
+	if sh.walletsPerKey[apiKey] <= 0 {
+		delete(sh.walletsPerKey, apiKey)
+	}
+}
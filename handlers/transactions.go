@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"nova-api/data"
+	"nova-api/models"
+	"nova-api/rpc"
+)
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+type TransactionService interface {
+	Transactions(walletAddress, cursor string, limit int) (*models.TransactionPage, error)
+}
+
+type TransactionHandler struct {
+	transactionService TransactionService
+}
+
+func NewTransactionHandler(transactionService TransactionService) *TransactionHandler {
+	return &TransactionHandler{
+		transactionService: transactionService,
+	}
+}
+
+// TransactionsHandler returns a page of ?wallet='s transactions. ?before= takes the
+// next_cursor of the previous page and ?limit= the page size.
+func (th *TransactionHandler) TransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	wallet := query.Get("wallet")
+	if wallet == "" {
+		writeError(w, http.StatusBadRequest, "wallet cannot be empty")
+		return
+	}
+
+	limit := defaultTransactionsLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxTransactionsLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxTransactionsLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	page, err := th.transactionService.Transactions(wallet, query.Get("before"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, rpc.ErrInvalidAddress), errors.Is(err, data.ErrInvalidCursor):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			log.Printf("Failed to list transactions of wallet %s: %v", wallet, err)
+			writeError(w, http.StatusBadGateway, "Failed to load transactions")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: page, Success: true})
+}
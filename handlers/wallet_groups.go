@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/middleware"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gorilla/mux"
+)
+
+type WalletGroupService interface {
+	Create(apiKey string, request models.WalletGroupRequest) (*models.WalletGroup, error)
+	List(apiKey string) ([]models.WalletGroup, error)
+	Get(apiKey, id string) (*models.WalletGroup, error)
+	Delete(apiKey, id string) error
+}
+
+type WalletGroupHandler struct {
+	walletGroupService WalletGroupService
+}
+
+func NewWalletGroupHandler(walletGroupService WalletGroupService) *WalletGroupHandler {
+	return &WalletGroupHandler{
+		walletGroupService: walletGroupService,
+	}
+}
+
+func (gh *WalletGroupHandler) CreateWalletGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.WalletGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		writeError(w, http.StatusBadRequest, "name cannot be empty")
+		return
+	}
+	if len(request.Wallets) == 0 {
+		writeError(w, http.StatusBadRequest, "Wallets array cannot be empty")
+		return
+	}
+	if len(request.Wallets) > config.AppConfig.MaxWalletsPerGroup {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many wallets requested. Maximum %d wallets allowed per group", config.AppConfig.MaxWalletsPerGroup))
+		return
+	}
+
+	group, err := gh.walletGroupService.Create(middleware.APIKeyFromContext(r.Context()), request)
+	if err != nil {
+		if errors.Is(err, rpc.ErrInvalidAddress) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Failed to create wallet group: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create wallet group")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.Response{Data: group, Success: true})
+}
+
+func (gh *WalletGroupHandler) ListWalletGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	groups, err := gh.walletGroupService.List(middleware.APIKeyFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Failed to list wallet groups: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list wallet groups")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: groups, Success: true})
+}
+
+func (gh *WalletGroupHandler) GetWalletGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group, err := gh.walletGroupService.Get(middleware.APIKeyFromContext(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, data.ErrWalletGroupNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Failed to load wallet group: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to load wallet group")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: group, Success: true})
+}
+
+func (gh *WalletGroupHandler) DeleteWalletGroupHandler(w http.ResponseWriter, r *http.Request) {
+	err := gh.walletGroupService.Delete(middleware.APIKeyFromContext(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, data.ErrWalletGroupNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Failed to delete wallet group: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to delete wallet group")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+// groupWallets loads the group a request names instead of its wallets. Groups
+// are limited by MaxWalletsPerGroup rather than MaxWalletsPerRequest. It writes
+// the error response and returns false when the group cannot be used.
+func groupWallets(w http.ResponseWriter, r *http.Request, groups WalletGroupService, groupID string, wallets []string) (*models.WalletGroup, bool) {
+	if len(wallets) > 0 {
+		writeError(w, http.StatusBadRequest, "Pass either wallets or group_id, not both")
+		return nil, false
+	}
+	if groups == nil {
+		writeError(w, http.StatusBadRequest, "Wallet groups are not supported")
+		return nil, false
+	}
+
+	group, err := groups.Get(middleware.APIKeyFromContext(r.Context()), groupID)
+	if err != nil {
+		if errors.Is(err, data.ErrWalletGroupNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return nil, false
+		}
+		log.Printf("Failed to load wallet group %s: %v", groupID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to load wallet group")
+		return nil, false
+	}
+
+	// Groups created before MAX_WALLETS_PER_GROUP was lowered are refused rather than cut short
+	if len(group.Wallets) > config.AppConfig.MaxWalletsPerGroup {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many wallets in group. Maximum %d wallets allowed per group", config.AppConfig.MaxWalletsPerGroup))
+		return nil, false
+	}
+	return group, true
+}
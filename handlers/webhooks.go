@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/middleware"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+type WebhookService interface {
+	Register(apiKey string, request models.WebhookRequest) (*models.Webhook, error)
+	List(apiKey string) ([]models.Webhook, error)
+	Delete(apiKey, id string) error
+	Deliveries(apiKey, id string, limit int) ([]models.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	webhookService WebhookService
+}
+
+func NewWebhookHandler(webhookService WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (wh *WebhookHandler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	if len(request.Wallets) == 0 {
+		writeError(w, http.StatusBadRequest, "Wallets array cannot be empty")
+		return
+	}
+	if len(request.Wallets) > config.AppConfig.MaxWalletsPerRequest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many wallets requested. Maximum %d wallets allowed per webhook", config.AppConfig.MaxWalletsPerRequest))
+		return
+	}
+	if threshold := request.Threshold; threshold != nil && threshold.Below != nil && threshold.Above != nil && *threshold.Below > *threshold.Above {
+		writeError(w, http.StatusBadRequest, "threshold below cannot be greater than above")
+		return
+	}
+
+	webhook, err := wh.webhookService.Register(middleware.APIKeyFromContext(r.Context()), request)
+	if err != nil {
+		if errors.Is(err, rpc.ErrInvalidAddress) || errors.Is(err, data.ErrInvalidWebhookURL) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, data.ErrWebhookLimit) {
+			writeError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		log.Printf("Failed to register webhook: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to register webhook")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.Response{Data: webhook, Success: true})
+}
+
+func (wh *WebhookHandler) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := wh.webhookService.List(middleware.APIKeyFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Failed to list webhooks: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: webhooks, Success: true})
+}
+
+func (wh *WebhookHandler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	err := wh.webhookService.Delete(middleware.APIKeyFromContext(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, data.ErrWebhookNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Failed to delete webhook: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Success: true})
+}
+
+// DeliveriesHandler returns the delivery log of a webhook, newest first. ?limit= caps the number of entries.
+func (wh *WebhookHandler) DeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeliveryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxDeliveryLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxDeliveryLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := wh.webhookService.Deliveries(middleware.APIKeyFromContext(r.Context()), mux.Vars(r)["id"], limit)
+	if err != nil {
+		if errors.Is(err, data.ErrWebhookNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Failed to list webhook deliveries: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list webhook deliveries")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.Response{Data: deliveries, Success: true})
+}
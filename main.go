@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"nova-api/data"
 	"nova-api/handlers"
 	"nova-api/middleware"
+	"nova-api/rpc"
 
 	"github.com/gorilla/mux"
 )
@@ -16,12 +18,13 @@ import (
 func main() {
 	config.Load()
 
-	balanceService := data.NewBalanceService(
-		config.AppConfig.SolanaRPCEndpoint,
-		config.AppConfig.DragonflyAddr,
-		config.AppConfig.DragonflyPassword,
-		config.AppConfig.DragonflyDB,
-	)
+	balanceCache, err := data.NewBalanceCache()
+	if err != nil {
+		log.Fatalf("Failed to initialize balance cache: %v", err)
+	}
+
+	rpcClient := rpc.NewSolanaRPC(config.AppConfig.SolanaRPCEndpoint)
+	balanceService := data.NewBalanceService(rpcClient, balanceCache)
 	defer balanceService.Close()
 
 	mongoService, err := data.NewMongoService()
@@ -30,16 +33,111 @@ func main() {
 	}
 	defer mongoService.Close()
 
+	var subscriptions *data.SubscriptionManager
+	if config.AppConfig.AccountSubscriptionsEnabled {
+		subscriptions = data.NewSubscriptionManager(config.AppConfig.SolanaWSEndpoint, rpcClient, balanceCache)
+		defer subscriptions.Close()
+	}
+
+	if config.AppConfig.CacheWarmerEnabled {
+		warmer := data.NewCacheWarmer(balanceService, mongoService)
+		if subscriptions != nil {
+			warmer.UseSubscriptions(subscriptions)
+		}
+		warmer.Start(context.Background())
+		defer warmer.Close()
+	}
+
+	if config.AppConfig.SnapshotsEnabled {
+		if err := mongoService.EnsureSnapshotCollection(); err != nil {
+			log.Fatalf("Failed to initialize balance snapshots: %v", err)
+		}
+		snapshotter := data.NewSnapshotter(balanceService, mongoService, mongoService, mongoService)
+		snapshotter.Start(context.Background())
+		defer snapshotter.Close()
+	}
+
+	balanceFeed := data.NewBalanceFeed(balanceService, subscriptions)
+	defer balanceFeed.Close()
+
+	webhookDispatcher, err := data.NewWebhookDispatcher(mongoService, balanceFeed)
+	if err != nil {
+		log.Fatalf("Failed to initialize webhooks: %v", err)
+	}
+	defer webhookDispatcher.Close()
+
+	rentService := data.NewRentService(rpcClient, balanceCache)
+	alertEvaluator, err := data.NewAlertEvaluator(mongoService, balanceFeed, balanceService, rentService)
+	if err != nil {
+		log.Fatalf("Failed to initialize alerts: %v", err)
+	}
+	defer alertEvaluator.Close()
+
+	tokenMetadata := data.NewTokenMetadataService(rpcClient, balanceCache)
+	tokenService := data.NewTokenService(rpcClient, balanceCache, tokenMetadata)
+	stakeService := data.NewStakeService(rpcClient, balanceCache)
+	nftService := data.NewNFTService(rpcClient, balanceCache)
+
+	walletGroupService := data.NewWalletGroupService(mongoService)
+
 	balanceHandler := handlers.NewBalanceHandler(balanceService)
+	balanceHandler.SetWalletGroups(walletGroupService)
+	balanceHandler.SetTokenService(tokenService)
+	balanceHandler.SetStakeService(stakeService)
+	balanceHandler.SetPriceService(data.NewPriceService(rpcClient, balanceCache))
+	balanceHandler.SetNameService(data.NewNameService(rpcClient, balanceCache))
+	balanceHandler.SetRentService(rentService)
+	streamHandler := handlers.NewStreamHandler(balanceFeed, balanceService)
+	webhookHandler := handlers.NewWebhookHandler(webhookDispatcher)
+	alertHandler := handlers.NewAlertHandler(alertEvaluator)
+	historyHandler := handlers.NewHistoryHandler(mongoService)
+	pastBalanceHandler := handlers.NewPastBalanceHandler(data.NewPastBalanceService(rpcClient, balanceCache))
+	transactionService := data.NewTransactionService(rpcClient, balanceCache)
+	transactionService.SetTokenMetadata(tokenMetadata)
+	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	accountHandler := handlers.NewAccountHandler(data.NewAccountService(rpcClient, balanceCache))
+	nftHandler := handlers.NewNFTHandler(nftService)
+	portfolioHandler := handlers.NewPortfolioHandler(data.NewPortfolioService(balanceService, tokenService, stakeService, nftService))
+	portfolioHandler.SetWalletGroups(walletGroupService)
+	walletGroupHandler := handlers.NewWalletGroupHandler(walletGroupService)
+	addressHandler := handlers.NewAddressHandler(data.NewAddressService(rpcClient))
+	networkHandler := handlers.NewNetworkHandler(data.NewNetworkService(rpcClient, balanceCache))
+	rentHandler := handlers.NewRentHandler(rentService)
 
 	router := mux.NewRouter()
 
 	router.Use(middleware.RateLimitMiddleware)
 	router.Use(middleware.CORSMiddleware)
 
+	// The streaming routes also accept the key as ?token= for browser clients
+	streams := router.PathPrefix("/api").Subrouter()
+	streams.Use(middleware.StreamAPIKeyAuth(mongoService))
+	streams.HandleFunc("/ws", streamHandler.WebSocketHandler).Methods("GET")
+	streams.HandleFunc("/balances/stream", streamHandler.EventStreamHandler).Methods("GET")
+
 	api := router.PathPrefix("/api").Subrouter()
 	api.Use(middleware.APIKeyAuth(mongoService))
 	api.HandleFunc("/get-balance", balanceHandler.GetBalanceHandler).Methods("POST")
+	api.HandleFunc("/webhooks", webhookHandler.CreateWebhookHandler).Methods("POST")
+	api.HandleFunc("/webhooks", webhookHandler.ListWebhooksHandler).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", webhookHandler.DeleteWebhookHandler).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.DeliveriesHandler).Methods("GET")
+	api.HandleFunc("/alerts", alertHandler.CreateAlertHandler).Methods("POST")
+	api.HandleFunc("/alerts", alertHandler.ListAlertsHandler).Methods("GET")
+	api.HandleFunc("/alerts/{id}", alertHandler.DeleteAlertHandler).Methods("DELETE")
+	api.HandleFunc("/balance-history", historyHandler.BalanceHistoryHandler).Methods("GET")
+	api.HandleFunc("/balance-at", pastBalanceHandler.BalanceAtHandler).Methods("GET")
+	api.HandleFunc("/transactions", transactionHandler.TransactionsHandler).Methods("GET")
+	api.HandleFunc("/accounts", accountHandler.AccountsHandler).Methods("POST")
+	api.HandleFunc("/nfts", nftHandler.NFTsHandler).Methods("GET")
+	api.HandleFunc("/validate-address", addressHandler.ValidateAddressHandler).Methods("POST")
+	api.HandleFunc("/portfolio", portfolioHandler.PortfolioHandler).Methods("POST")
+	api.HandleFunc("/network", networkHandler.NetworkHandler).Methods("GET")
+	api.HandleFunc("/rent-exemption", rentHandler.RentExemptionHandler).Methods("GET")
+	api.HandleFunc("/wallet-groups", walletGroupHandler.CreateWalletGroupHandler).Methods("POST")
+	api.HandleFunc("/wallet-groups", walletGroupHandler.ListWalletGroupsHandler).Methods("GET")
+	api.HandleFunc("/wallet-groups/{id}", walletGroupHandler.GetWalletGroupHandler).Methods("GET")
+	api.HandleFunc("/wallet-groups/{id}", walletGroupHandler.DeleteWalletGroupHandler).Methods("DELETE")
 
 	fmt.Printf("API Server starting on port %s\n", config.AppConfig.Port)
 
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -11,10 +12,29 @@ type APIKeyValidator interface {
 	ValidateAPIKey(key string) (*models.APIKey, error)
 }
 
+type contextKey string
+
+const apiKeyContextKey contextKey = "apiKey"
+
 func APIKeyAuth(validator APIKeyValidator) func(http.Handler) http.Handler {
+	return apiKeyAuth(validator, false)
+}
+
+// StreamAPIKeyAuth is APIKeyAuth for the streaming routes only. Browser
+// WebSocket and EventSource clients cannot set headers, so the key may also be
+// passed as ?token=. Everywhere else a key in the URL would end up in proxy and
+// access logs, so it is not accepted.
+func StreamAPIKeyAuth(validator APIKeyValidator) func(http.Handler) http.Handler {
+	return apiKeyAuth(validator, true)
+}
+
+func apiKeyAuth(validator APIKeyValidator, allowQueryToken bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			apiKey := r.Header.Get("X-Token")
+			if apiKey == "" && allowQueryToken {
+				apiKey = r.URL.Query().Get("token")
+			}
 			if apiKey == "" {
 				http.Error(w, "Missing X-Token header", http.StatusUnauthorized)
 				return
@@ -32,7 +52,14 @@ func APIKeyAuth(validator APIKeyValidator) func(http.Handler) http.Handler {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := context.WithValue(r.Context(), apiKeyContextKey, apiKey)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// APIKeyFromContext returns the API key that authenticated the request, or "" outside of APIKeyAuth.
+func APIKeyFromContext(ctx context.Context) string {
+	apiKey, _ := ctx.Value(apiKeyContextKey).(string)
+	return apiKey
+}
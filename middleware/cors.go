@@ -2,12 +2,45 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+
+	"nova-api/config"
 )
 
+// OriginAllowed reports whether a browser on origin may call the API, checked
+// against CORS_ALLOWED_ORIGINS. Requests without an Origin header do not come
+// from a browser page and are always allowed.
+func OriginAllowed(origin string) bool {
+	if origin == "" || allowsAnyOrigin() {
+		return true
+	}
+	for _, allowed := range config.AppConfig.CORSAllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func allowsAnyOrigin() bool {
+	for _, allowed := range config.AppConfig.CORSAllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return len(config.AppConfig.CORSAllowedOrigins) == 0
+}
+
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Nova team: Allow only correct domains
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := r.Header.Get("Origin")
+		switch {
+		case allowsAnyOrigin():
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && OriginAllowed(origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "POST")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Token")
 
@@ -16,7 +16,11 @@ type RateLimitEntry struct {
 	ExpiresAt time.Time
 }
 
-var rateLimiter = data.NewMemoryCache()
+// maxRateLimitEntries bounds how many clients are tracked at once, so a burst of
+// unique IPs evicts the least recently seen ones instead of growing memory.
+const maxRateLimitEntries = 100_000
+
+var rateLimiter = data.NewMemoryCacheWithLimit(maxRateLimitEntries)
 
 func RateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -65,6 +69,8 @@ func allowRequest(ip string, rateLimit int) bool {
 	return true
 }
 
+// ResetRateLimiterForTesting forgets every tracked client. The limiter itself is
+// reused so no additional cleanup goroutine is started.
 func ResetRateLimiterForTesting() {
-	rateLimiter = data.NewMemoryCache()
+	rateLimiter.Clear()
 }
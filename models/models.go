@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Response represents the API response structure
 type Response struct {
 	Data    interface{} `json:"data,omitempty"`
@@ -9,14 +14,35 @@ type Response struct {
 
 // BalanceRequest represents the request structure for balance queries
 type BalanceRequest struct {
+	// Wallets holds base58 addresses or .sol domains
 	Wallets []string `json:"wallets"`
+	// GroupID requests the wallets of a wallet group instead of Wallets
+	GroupID string `json:"group_id,omitempty"`
+	// IncludeStaked adds the wallet's stake accounts and a liquid plus staked total
+	IncludeStaked bool `json:"include_staked,omitempty"`
+	// IncludeTokens adds the wallet's SPL token balances
+	IncludeTokens bool `json:"include_tokens,omitempty"`
+	// IncludeRent adds whether the wallet holds its rent-exempt minimum
+	IncludeRent bool `json:"include_rent,omitempty"`
 }
 
 // WalletBalance represents a single wallet's balance information
 type WalletBalance struct {
-	Wallet  string  `json:"wallet"`
-	Balance float64 `json:"balance,omitempty"`
-	Error   string  `json:"error,omitempty"`
+	// Wallet echoes the requested wallet; for .sol domains ResolvedAddress is the owner they resolved to
+	Wallet          string         `json:"wallet"`
+	ResolvedAddress string         `json:"resolved_address,omitempty"`
+	Balance         float64        `json:"balance,omitempty"`
+	Staked          *StakeSummary  `json:"staked,omitempty"`
+	Tokens          []TokenBalance `json:"tokens,omitempty"`
+	// TokensError is set when include_tokens was requested but the token accounts could not be read
+	TokensError string `json:"tokens_error,omitempty"`
+	// Rent is set with include_rent; RentError explains why it is missing
+	Rent      *RentStatus `json:"rent,omitempty"`
+	RentError string      `json:"rent_error,omitempty"`
+	// USD is set with quote=usd; QuoteError explains why it is missing
+	USD        *float64 `json:"usd,omitempty"`
+	QuoteError string   `json:"quote_error,omitempty"`
+	Error      string   `json:"error,omitempty"`
 }
 
 type APIKey struct {
@@ -25,3 +51,457 @@ type APIKey struct {
 	// This is not used in the code but can be useful for tracking
 	Note string `bson:"note" json:"note"`
 }
+
+// BalanceUpdate is pushed to streaming clients when a watched wallet's balance changes
+type BalanceUpdate struct {
+	ID      uint64    `json:"id,omitempty"`
+	Wallet  string    `json:"wallet"`
+	Balance float64   `json:"balance"`
+	Time    time.Time `json:"time"`
+}
+
+// StreamRequest is sent by streaming clients to change the wallets they receive updates for
+type StreamRequest struct {
+	Action  string   `json:"action"`
+	Wallets []string `json:"wallets"`
+}
+
+// StreamMessage is sent to streaming clients. Type is one of "subscribed", "unsubscribed", "balance" or "error"
+type StreamMessage struct {
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// Webhook is an outbound subscription that POSTs balance changes of its wallets to URL.
+// Without a threshold every change is delivered.
+type Webhook struct {
+	ID        string            `bson:"_id" json:"id"`
+	APIKey    string            `bson:"api_key" json:"-"`
+	URL       string            `bson:"url" json:"url"`
+	Wallets   []string          `bson:"wallets" json:"wallets"`
+	Threshold *WebhookThreshold `bson:"threshold,omitempty" json:"threshold,omitempty"`
+	// Secret signs every payload. It is only returned when the webhook is created
+	Secret    string    `bson:"secret" json:"secret,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// WebhookThreshold limits deliveries to balances crossing below or above a value in SOL
+type WebhookThreshold struct {
+	Below *float64 `bson:"below,omitempty" json:"below,omitempty"`
+	Above *float64 `bson:"above,omitempty" json:"above,omitempty"`
+}
+
+// WebhookRequest is the payload for registering a webhook
+type WebhookRequest struct {
+	URL       string            `json:"url"`
+	Wallets   []string          `json:"wallets"`
+	Threshold *WebhookThreshold `json:"threshold,omitempty"`
+}
+
+// WebhookEvent is the body POSTed to a webhook URL
+type WebhookEvent struct {
+	ID              string    `bson:"id" json:"id"`
+	WebhookID       string    `bson:"webhook_id" json:"webhook_id"`
+	Type            string    `bson:"type" json:"type"`
+	Wallet          string    `bson:"wallet" json:"wallet"`
+	Balance         float64   `bson:"balance" json:"balance"`
+	PreviousBalance *float64  `bson:"previous_balance,omitempty" json:"previous_balance,omitempty"`
+	Time            time.Time `bson:"time" json:"time"`
+}
+
+// WebhookDelivery records the attempts made to deliver one event. Status is "pending", "delivered" or "failed"
+type WebhookDelivery struct {
+	ID         string       `bson:"_id" json:"id"`
+	WebhookID  string       `bson:"webhook_id" json:"webhook_id"`
+	APIKey     string       `bson:"api_key" json:"-"`
+	Event      WebhookEvent `bson:"event" json:"event"`
+	Status     string       `bson:"status" json:"status"`
+	Attempts   int          `bson:"attempts" json:"attempts"`
+	StatusCode int          `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string       `bson:"error,omitempty" json:"error,omitempty"`
+	// NextAttemptAt is when a pending delivery is retried
+	NextAttemptAt *time.Time `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// AlertRule is a condition on a wallet's balance. It fires once when the
+// condition becomes true and resolves once it stops being true, notifying every channel each time.
+type AlertRule struct {
+	ID             string               `bson:"_id" json:"id"`
+	APIKey         string               `bson:"api_key" json:"-"`
+	Name           string               `bson:"name" json:"name"`
+	Wallet         string               `bson:"wallet" json:"wallet"`
+	Condition      AlertCondition       `bson:"condition" json:"condition"`
+	Channels       []AlertChannelConfig `bson:"channels" json:"channels"`
+	State          string               `bson:"state" json:"state"`
+	StateChangedAt time.Time            `bson:"state_changed_at,omitempty" json:"state_changed_at,omitempty"`
+	CreatedAt      time.Time            `bson:"created_at" json:"created_at"`
+}
+
+// AlertCondition is one of "below" or "above" Value SOL, "drop_percent" (lost more than Value
+// percent within WindowSeconds) or "below_rent_exempt" (under the rent-exempt minimum for DataSize bytes)
+type AlertCondition struct {
+	Type          string  `bson:"type" json:"type"`
+	Value         float64 `bson:"value,omitempty" json:"value,omitempty"`
+	WindowSeconds int     `bson:"window_seconds,omitempty" json:"window_seconds,omitempty"`
+	DataSize      int     `bson:"data_size,omitempty" json:"data_size,omitempty"`
+}
+
+// AlertChannelConfig selects where notifications go: "log", "webhook" or "slack" (URL), or "smtp" (To)
+type AlertChannelConfig struct {
+	Type string   `bson:"type" json:"type"`
+	URL  string   `bson:"url,omitempty" json:"url,omitempty"`
+	To   []string `bson:"to,omitempty" json:"to,omitempty"`
+	// Secret signs webhook channel payloads. It is only returned when the rule is created
+	Secret string `bson:"secret,omitempty" json:"secret,omitempty"`
+}
+
+// AlertRuleRequest is the payload for creating an alert rule
+type AlertRuleRequest struct {
+	Name      string               `json:"name"`
+	Wallet    string               `json:"wallet"`
+	Condition AlertCondition       `json:"condition"`
+	Channels  []AlertChannelConfig `json:"channels"`
+}
+
+// AlertNotification is sent to an alert's channels when it fires or resolves
+type AlertNotification struct {
+	RuleID   string    `json:"rule_id"`
+	RuleName string    `json:"rule_name"`
+	Wallet   string    `json:"wallet"`
+	State    string    `json:"state"`
+	Balance  float64   `json:"balance"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// BalanceSnapshot is a wallet's balance recorded at a point in time
+type BalanceSnapshot struct {
+	Wallet  string    `bson:"wallet" json:"wallet"`
+	Balance float64   `bson:"balance" json:"balance"`
+	Time    time.Time `bson:"time" json:"time"`
+}
+
+// BalanceHistoryPoint summarizes the snapshots in one bucket starting at Time.
+// Balance is the last value, Min and Max are only set when min/max aggregation is requested
+type BalanceHistoryPoint struct {
+	Time    time.Time `json:"time"`
+	Balance *float64  `json:"balance,omitempty"`
+	Min     *float64  `json:"min,omitempty"`
+	Max     *float64  `json:"max,omitempty"`
+}
+
+// PastBalance is a wallet's balance reconstructed for a past slot or timestamp
+type PastBalance struct {
+	Wallet     string             `json:"wallet"`
+	Balance    float64            `json:"balance"`
+	Slot       uint64             `json:"slot,omitempty"`
+	Timestamp  *time.Time         `json:"timestamp,omitempty"`
+	Checkpoint *BalanceCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// BalanceCheckpoint is the transaction a past balance was read from. Position is
+// "post" when the balance is the one after the transaction and "pre" when it is the one before it
+type BalanceCheckpoint struct {
+	Signature string `json:"signature"`
+	Slot      uint64 `json:"slot"`
+	BlockTime int64  `json:"block_time,omitempty"`
+	Position  string `json:"position"`
+}
+
+// WalletTransaction is a finalized transaction as seen from one wallet
+type WalletTransaction struct {
+	Signature string `json:"signature"`
+	Slot      uint64 `json:"slot"`
+	BlockTime int64  `json:"block_time,omitempty"`
+	// Status is "success" or "failed"
+	Status string `json:"status"`
+	// Fee is the transaction fee in SOL, paid by the first signer
+	Fee       float64    `json:"fee"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+// Transfer is the net movement of SOL or one SPL token in or out of the wallet.
+// Counterparty is the account that moved the most in the opposite direction
+type Transfer struct {
+	// Type is "sol" or "spl"
+	Type         string         `json:"type"`
+	Mint         string         `json:"mint,omitempty"`
+	Decimals     uint8          `json:"decimals,omitempty"`
+	Direction    string         `json:"direction"`
+	Counterparty string         `json:"counterparty,omitempty"`
+	Amount       float64        `json:"amount"`
+	Token        *TokenMetadata `json:"token,omitempty"`
+}
+
+// TransactionPage is one page of a wallet's transactions, newest first. Pass
+// NextCursor as ?before= to get the next page; it is empty on the last page
+type TransactionPage struct {
+	Transactions []WalletTransaction `json:"transactions"`
+	NextCursor   string              `json:"next_cursor,omitempty"`
+}
+
+// StakeSummary lists the stake accounts a wallet is the staker or withdrawer of.
+// Staked is the SOL held in them, Total adds the wallet's own balance
+type StakeSummary struct {
+	Accounts  []StakeAccount `json:"accounts"`
+	Staked    float64        `json:"staked"`
+	Total     float64        `json:"total"`
+	StakedUSD *float64       `json:"staked_usd,omitempty"`
+	TotalUSD  *float64       `json:"total_usd,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// StakeAccount splits the SOL in a stake account by activation state. Rent is
+// the rent-exempt reserve, Inactive is everything neither delegated nor reserved
+type StakeAccount struct {
+	Address      string  `json:"address"`
+	Voter        string  `json:"voter,omitempty"`
+	Balance      float64 `json:"balance"`
+	Active       float64 `json:"active"`
+	Activating   float64 `json:"activating"`
+	Deactivating float64 `json:"deactivating"`
+	Inactive     float64 `json:"inactive"`
+	Rent         float64 `json:"rent"`
+}
+
+// AccountsRequest asks for the full account info of Addresses. Encoding is
+// base64 (the default) or jsonParsed; DataSlice only works with base64
+type AccountsRequest struct {
+	Addresses []string   `json:"addresses"`
+	Encoding  string     `json:"encoding,omitempty"`
+	DataSlice *DataSlice `json:"data_slice,omitempty"`
+}
+
+type DataSlice struct {
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+}
+
+// AccountInfo is one account of an AccountsRequest. Data is ["<base64>", "base64"],
+// or the parsed account for jsonParsed when the node knows the owner program
+type AccountInfo struct {
+	Address    string          `json:"address"`
+	Lamports   uint64          `json:"lamports"`
+	Owner      string          `json:"owner,omitempty"`
+	Executable bool            `json:"executable"`
+	RentEpoch  uint64          `json:"rent_epoch"`
+	Space      uint64          `json:"space"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// TokenBalance is a wallet's balance of one SPL token, summed over its token accounts
+type TokenBalance struct {
+	Mint     string         `json:"mint"`
+	Amount   float64        `json:"amount"`
+	Decimals uint8          `json:"decimals"`
+	Token    *TokenMetadata `json:"token,omitempty"`
+	USD      *float64       `json:"usd,omitempty"`
+}
+
+// TokenMetadata describes a mint for display. MetadataURI points at the
+// off-chain JSON of the token's on-chain metadata, LogoURI at its image
+type TokenMetadata struct {
+	Mint        string `json:"mint"`
+	Name        string `json:"name,omitempty"`
+	Symbol      string `json:"symbol,omitempty"`
+	Decimals    uint8  `json:"decimals"`
+	LogoURI     string `json:"logo_uri,omitempty"`
+	MetadataURI string `json:"metadata_uri,omitempty"`
+}
+
+// NFT is an NFT owned by a wallet. Mint is the asset ID of compressed NFTs,
+// which have no mint account
+type NFT struct {
+	Mint        string `json:"mint"`
+	Name        string `json:"name,omitempty"`
+	Symbol      string `json:"symbol,omitempty"`
+	MetadataURI string `json:"metadata_uri,omitempty"`
+	Collection  string `json:"collection,omitempty"`
+	// CollectionVerified is false for collections the NFT claims without the collection's signature
+	CollectionVerified bool `json:"collection_verified"`
+	Compressed         bool `json:"compressed"`
+}
+
+// NFTPage is one page of a wallet's NFTs. Total counts the NFTs matching the
+// filters on every page. CompressedError is set when compressed NFTs could
+// not be listed and only standard NFTs are returned
+type NFTPage struct {
+	NFTs            []NFT  `json:"nfts"`
+	Page            int    `json:"page"`
+	Limit           int    `json:"limit"`
+	Total           int    `json:"total"`
+	CompressedError string `json:"compressed_error,omitempty"`
+}
+
+// ValidateAddressRequest lists addresses to validate. With Offline, only the
+// checks that need no RPC call are made
+type ValidateAddressRequest struct {
+	Addresses []string `json:"addresses"`
+	Offline   bool     `json:"offline,omitempty"`
+}
+
+// AddressValidation describes an address. Curve is "wallet" for addresses on
+// the ed25519 curve and "pda" for program derived addresses off it. Exists,
+// Owner and AccountType are only set once the account was looked up;
+// AccountType is one of system_wallet, token_account, mint, program,
+// stake_account, vote_account, multisig or other
+type AddressValidation struct {
+	Address     string `json:"address"`
+	Valid       bool   `json:"valid"`
+	Error       string `json:"error,omitempty"`
+	Curve       string `json:"curve,omitempty"`
+	Exists      *bool  `json:"exists,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	AccountType string `json:"account_type,omitempty"`
+	LookupError string `json:"lookup_error,omitempty"`
+}
+
+// PortfolioRequest lists the wallets of a portfolio
+type PortfolioRequest struct {
+	Wallets []string `json:"wallets"`
+	// GroupID requests the wallets of a wallet group instead of Wallets
+	GroupID string `json:"group_id,omitempty"`
+}
+
+// NFTCount counts a wallet's NFTs. CompressedError is set when compressed NFTs
+// could not be listed and only standard NFTs are counted
+type NFTCount struct {
+	Total           int    `json:"total"`
+	Compressed      int    `json:"compressed"`
+	CompressedError string `json:"compressed_error,omitempty"`
+}
+
+// WalletPortfolio is one wallet of a portfolio. Each section is read on its
+// own: a section that failed is left out and explained by its *Error field
+type WalletPortfolio struct {
+	Wallet      string         `json:"wallet"`
+	SOL         *float64       `json:"sol,omitempty"`
+	SOLError    string         `json:"sol_error,omitempty"`
+	Tokens      []TokenBalance `json:"tokens,omitempty"`
+	TokensError string         `json:"tokens_error,omitempty"`
+	Staked      *StakeSummary  `json:"staked,omitempty"`
+	StakedError string         `json:"staked_error,omitempty"`
+	NFTs        *NFTCount      `json:"nfts,omitempty"`
+	NFTsError   string         `json:"nfts_error,omitempty"`
+	// Error is set for invalid wallets, which have no sections
+	Error string `json:"error,omitempty"`
+}
+
+// PortfolioTotals adds up the sections of every wallet. Tokens are summed per
+// mint. Partial is set when a section of some wallet failed and is missing from the totals
+type PortfolioTotals struct {
+	SOL     float64        `json:"sol"`
+	Staked  float64        `json:"staked"`
+	Total   float64        `json:"total"`
+	Tokens  []TokenBalance `json:"tokens"`
+	NFTs    int            `json:"nfts"`
+	Partial bool           `json:"partial,omitempty"`
+}
+
+// Portfolio is the per-wallet breakdown and cross-wallet totals of a set of wallets
+type Portfolio struct {
+	// Group is set when the portfolio was requested by group_id
+	Group   *WalletGroupRef   `json:"group,omitempty"`
+	Wallets []WalletPortfolio `json:"wallets"`
+	Totals  PortfolioTotals   `json:"totals"`
+}
+
+// WalletGroup is a named set of wallets of an API key, requested by ID instead of listing them
+type WalletGroup struct {
+	ID        string    `bson:"_id" json:"id"`
+	APIKey    string    `bson:"api_key" json:"-"`
+	Name      string    `bson:"name" json:"name"`
+	Wallets   []string  `bson:"wallets" json:"wallets"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// WalletGroupRequest is the payload for creating a wallet group
+type WalletGroupRequest struct {
+	Name    string   `json:"name"`
+	Wallets []string `json:"wallets"`
+}
+
+// WalletGroupRef names the group a response was requested for
+type WalletGroupRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GroupBalances is the response to a balance request by group_id
+type GroupBalances struct {
+	Group   WalletGroupRef  `json:"group"`
+	Wallets []WalletBalance `json:"wallets"`
+	Totals  BalanceTotals   `json:"totals"`
+}
+
+// BalanceTotals adds up the balances of a group. Staked is set with
+// include_staked and USD with quote=usd. Partial is set when a wallet, its
+// stake or its quote failed and is missing from the totals
+type BalanceTotals struct {
+	Balance float64  `json:"balance"`
+	Staked  *float64 `json:"staked,omitempty"`
+	Total   float64  `json:"total"`
+	USD     *float64 `json:"usd,omitempty"`
+	Partial bool     `json:"partial,omitempty"`
+}
+
+// NetworkStatus is a snapshot of the cluster's progress, throughput and priority fees
+type NetworkStatus struct {
+	Slot        uint64       `json:"slot"`
+	BlockHeight uint64       `json:"block_height"`
+	Epoch       NetworkEpoch `json:"epoch"`
+	// TPS is averaged over the recent performance samples and counts vote transactions
+	TPS float64 `json:"tps"`
+	// NonVoteTPS is left out when the RPC node does not report non-vote transactions
+	NonVoteTPS   *float64     `json:"non_vote_tps,omitempty"`
+	PriorityFees PriorityFees `json:"priority_fees"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// NetworkEpoch is the current epoch and how far into it the cluster is. Progress ranges from 0 to 1
+type NetworkEpoch struct {
+	Epoch        uint64  `json:"epoch"`
+	SlotIndex    uint64  `json:"slot_index"`
+	SlotsInEpoch uint64  `json:"slots_in_epoch"`
+	Progress     float64 `json:"progress"`
+}
+
+// PriorityFees are percentiles of the prioritization fees, in micro-lamports
+// per compute unit, paid in recent slots. Accounts is set when the fees are
+// scoped to transactions writing to those accounts
+type PriorityFees struct {
+	Accounts []string `json:"accounts,omitempty"`
+	Slots    int      `json:"slots"`
+	Min      uint64   `json:"min"`
+	P25      uint64   `json:"p25"`
+	P50      uint64   `json:"p50"`
+	P75      uint64   `json:"p75"`
+	P90      uint64   `json:"p90"`
+	P99      uint64   `json:"p99"`
+	Max      uint64   `json:"max"`
+}
+
+// RentExemption is the rent-exempt minimum for accounts holding DataSize bytes
+type RentExemption struct {
+	DataSize uint64  `json:"data_size"`
+	Lamports uint64  `json:"lamports"`
+	SOL      float64 `json:"sol"`
+}
+
+// RentExemptions are the rent-exempt minimums of the requested data sizes in Epoch
+type RentExemptions struct {
+	Epoch    uint64          `json:"epoch"`
+	Minimums []RentExemption `json:"minimums"`
+}
+
+// RentStatus compares an account's lamports to the rent-exempt minimum for its
+// data length. Accounts that do not exist have no data and no lamports
+type RentStatus struct {
+	DataSize   uint64  `json:"data_size"`
+	Minimum    float64 `json:"minimum"`
+	RentExempt bool    `json:"rent_exempt"`
+}
@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// MaxAccountsPerCall is the most accounts getMultipleAccounts accepts at once.
+const MaxAccountsPerCall = 100
+
+// Account data encodings accepted by GetAccounts.
+const (
+	EncodingBase64     = "base64"
+	EncodingJSONParsed = "jsonParsed"
+)
+
+// DataSlice limits the returned account data to Length bytes starting at Offset.
+type DataSlice struct {
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+}
+
+// AccountInfo is an account as returned by getAccountInfo. Data is the raw JSON
+// the node returned: a ["<data>", "base64"] pair, or an object for accounts the
+// node could parse when jsonParsed was requested.
+type AccountInfo struct {
+	Lamports   uint64          `json:"lamports"`
+	Owner      string          `json:"owner"`
+	Executable bool            `json:"executable"`
+	RentEpoch  uint64          `json:"rent_epoch"`
+	Space      uint64          `json:"space"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// GetAccounts returns the accounts at addresses in order, nil for accounts that
+// do not exist. Addresses are fetched in batches of MaxAccountsPerCall.
+func (s *SolanaRPC) GetAccounts(addresses []string, encoding string, slice *DataSlice) ([]*AccountInfo, error) {
+	pubkeys := make([]solana.PublicKey, len(addresses))
+	for i, address := range addresses {
+		pubkey, err := solana.PublicKeyFromBase58(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidAddress, address, err)
+		}
+		pubkeys[i] = pubkey
+	}
+
+	opts := &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingType(encoding),
+		Commitment: rpc.CommitmentFinalized,
+	}
+	if slice != nil {
+		opts.DataSlice = &rpc.DataSlice{Offset: &slice.Offset, Length: &slice.Length}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	accounts := make([]*AccountInfo, 0, len(addresses))
+	for start := 0; start < len(pubkeys); start += MaxAccountsPerCall {
+		end := min(start+MaxAccountsPerCall, len(pubkeys))
+
+		result, err := s.client.GetMultipleAccountsWithOpts(ctx, pubkeys[start:end], opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get accounts: %w", err)
+		}
+
+		for _, account := range result.Value {
+			if account == nil {
+				accounts = append(accounts, nil)
+				continue
+			}
+
+			info := &AccountInfo{
+				Lamports:   account.Lamports,
+				Owner:      account.Owner.String(),
+				Executable: account.Executable,
+				Space:      account.Space,
+			}
+			if account.RentEpoch != nil {
+				info.RentEpoch = account.RentEpoch.Uint64()
+			}
+			if account.Data != nil {
+				info.Data, err = json.Marshal(account.Data)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encode data of account %s: %w", addresses[len(accounts)], err)
+				}
+				// Older nodes do not report space; without a slice the data is the whole account
+				if info.Space == 0 && slice == nil {
+					info.Space = uint64(len(account.Data.GetBinary()))
+				}
+			}
+			accounts = append(accounts, info)
+		}
+	}
+	return accounts, nil
+}
+
+// Account kinds reported by ClassifyAccount.
+const (
+	AccountKindSystemWallet = "system_wallet"
+	AccountKindToken        = "token_account"
+	AccountKindMint         = "mint"
+	AccountKindProgram      = "program"
+	AccountKindStake        = "stake_account"
+	AccountKindVote         = "vote_account"
+	AccountKindMultisig     = "multisig"
+	AccountKindOther        = "other"
+)
+
+// Sizes of SPL Token accounts without extensions, and the Token-2022 account
+// type of extended accounts, which follows the padding to tokenAccountSize.
+const (
+	tokenAccountSize      = 165
+	tokenMultisigSize     = 355
+	token2022AccountToken = 2
+)
+
+// Token2022AccountTypeSlice selects the account type byte of Token-2022
+// accounts, all ClassifyAccount needs besides the account info.
+var Token2022AccountTypeSlice = DataSlice{Offset: token2022AccountTypePos, Length: 1}
+
+// ClassifyAccount tells what an account is from its owner program, executable
+// flag and size. accountType is the byte at Token2022AccountTypeSlice, which
+// tells extended Token-2022 mints from token accounts.
+func ClassifyAccount(owner string, executable bool, space uint64, accountType byte) string {
+	if executable {
+		return AccountKindProgram
+	}
+
+	switch owner {
+	case solana.SystemProgramID.String():
+		// System accounts with data are nonce accounts
+		if space == 0 {
+			return AccountKindSystemWallet
+		}
+	case solana.StakeProgramID.String():
+		return AccountKindStake
+	case solana.VoteProgramID.String():
+		return AccountKindVote
+	case solana.TokenProgramID.String(), solana.Token2022ProgramID.String():
+		switch {
+		case space == mintSize:
+			return AccountKindMint
+		case space == tokenAccountSize:
+			return AccountKindToken
+		case space == tokenMultisigSize:
+			return AccountKindMultisig
+		case space > tokenAccountSize && accountType == token2022AccountMint:
+			return AccountKindMint
+		case space > tokenAccountSize && accountType == token2022AccountToken:
+			return AccountKindToken
+		}
+	}
+	return AccountKindOther
+}
@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// MaxSignaturesPerPage is the largest page getSignaturesForAddress returns.
+const MaxSignaturesPerPage = 1000
+
+// ErrTransactionNotFound is returned when a transaction is unknown or no longer available from the node.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// SignatureInfo describes a finalized transaction that touched an address.
+type SignatureInfo struct {
+	Signature string
+	Slot      uint64
+	// BlockTime is the estimated unix time of the block, 0 when the node does not know it
+	BlockTime int64
+	Failed    bool
+}
+
+// BalanceChange is the SOL balance of one account before and after a transaction.
+type BalanceChange struct {
+	Slot        uint64
+	BlockTime   int64
+	PreBalance  float64
+	PostBalance float64
+}
+
+// GetBalanceAndSlot returns the finalized balance of an address together with
+// the slot it was read at. Unlike GetBalance a missing account is reported as 0.
+func (s *SolanaRPC) GetBalanceAndSlot(walletAddress string) (float64, uint64, error) {
+	pubkey, err := solana.PublicKeyFromBase58(walletAddress)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	balance, err := s.client.GetBalance(ctx, pubkey, BalanceCommitment)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return LamportsToSOL(balance.Value), balance.Context.Slot, nil
+}
+
+// GetSignatures returns up to limit finalized signatures for an address, newest
+// first, starting before the signature before (or at the tip when empty).
+func (s *SolanaRPC) GetSignatures(walletAddress, before string, limit int) ([]SignatureInfo, error) {
+	pubkey, err := solana.PublicKeyFromBase58(walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	opts := &rpc.GetSignaturesForAddressOpts{
+		Limit:      &limit,
+		Commitment: rpc.CommitmentFinalized,
+	}
+	if before != "" {
+		opts.Before, err = solana.SignatureFromBase58(before)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature %s: %w", before, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := s.client.GetSignaturesForAddressWithOpts(ctx, pubkey, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signatures: %w", err)
+	}
+
+	signatures := make([]SignatureInfo, 0, len(results))
+	for _, result := range results {
+		info := SignatureInfo{
+			Signature: result.Signature.String(),
+			Slot:      result.Slot,
+			Failed:    result.Err != nil,
+		}
+		if result.BlockTime != nil {
+			info.BlockTime = int64(*result.BlockTime)
+		}
+		signatures = append(signatures, info)
+	}
+	return signatures, nil
+}
+
+// GetBalanceChange returns the balance of walletAddress before and after the transaction.
+func (s *SolanaRPC) GetBalanceChange(signature, walletAddress string) (*BalanceChange, error) {
+	pubkey, err := solana.PublicKeyFromBase58(walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	result, keys, err := s.getTransaction(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		if !key.Equals(pubkey) {
+			continue
+		}
+		if i >= len(result.Meta.PreBalances) || i >= len(result.Meta.PostBalances) {
+			break
+		}
+
+		change := &BalanceChange{
+			Slot:        result.Slot,
+			PreBalance:  LamportsToSOL(result.Meta.PreBalances[i]),
+			PostBalance: LamportsToSOL(result.Meta.PostBalances[i]),
+		}
+		if result.BlockTime != nil {
+			change.BlockTime = int64(*result.BlockTime)
+		}
+		return change, nil
+	}
+	return nil, fmt.Errorf("account %s is not part of transaction %s", walletAddress, signature)
+}
+
+// getTransaction fetches a finalized transaction with its full account key list,
+// which is the order PreBalances and PostBalances are reported in.
+func (s *SolanaRPC) getTransaction(signature string) (*rpc.GetTransactionResult, []solana.PublicKey, error) {
+	sig, err := solana.SignatureFromBase58(signature)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid signature %s: %w", signature, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	maxVersion := uint64(0)
+	result, err := s.client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentFinalized,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotFound) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrTransactionNotFound, signature)
+		}
+		return nil, nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	if result == nil || result.Meta == nil || result.Transaction == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrTransactionNotFound, signature)
+	}
+
+	tx, err := result.Transaction.GetTransaction()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode transaction %s: %w", signature, err)
+	}
+
+	// Versioned transactions list the addresses loaded from lookup tables after the static keys
+	keys := append([]solana.PublicKey{}, tx.Message.AccountKeys...)
+	keys = append(keys, result.Meta.LoadedAddresses.Writable...)
+	keys = append(keys, result.Meta.LoadedAddresses.ReadOnly...)
+	return result, keys, nil
+}
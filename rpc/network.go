@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// MaxPriorityFeeAccounts is the most accounts getRecentPrioritizationFees accepts.
+const MaxPriorityFeeAccounts = 128
+
+// EpochInfo is the position of the cluster at confirmed commitment.
+type EpochInfo struct {
+	Slot         uint64
+	BlockHeight  uint64
+	Epoch        uint64
+	SlotIndex    uint64
+	SlotsInEpoch uint64
+}
+
+// PerformanceSample counts the transactions processed in a sample window.
+// NumNonVoteTransactions is nil for nodes that predate the field.
+type PerformanceSample struct {
+	Slot                   uint64  `json:"slot"`
+	NumTransactions        uint64  `json:"numTransactions"`
+	NumNonVoteTransactions *uint64 `json:"numNonVoteTransactions"`
+	NumSlots               uint64  `json:"numSlots"`
+	SamplePeriodSecs       uint16  `json:"samplePeriodSecs"`
+}
+
+// GetEpochInfo returns the current slot, block height and epoch. They come from
+// one getEpochInfo call, so they always describe the same slot.
+func (s *SolanaRPC) GetEpochInfo() (*EpochInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := s.client.GetEpochInfo(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get epoch info: %w", err)
+	}
+	return &EpochInfo{
+		Slot:         info.AbsoluteSlot,
+		BlockHeight:  info.BlockHeight,
+		Epoch:        info.Epoch,
+		SlotIndex:    info.SlotIndex,
+		SlotsInEpoch: info.SlotsInEpoch,
+	}, nil
+}
+
+// GetPerformanceSamples returns up to limit of the most recent performance
+// samples, newest first. Nodes take a sample about once a minute.
+func (s *SolanaRPC) GetPerformanceSamples(limit int) ([]PerformanceSample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// solana-go's result type has no numNonVoteTransactions, so the samples are decoded here
+	var samples []PerformanceSample
+	if err := s.client.RPCCallForInto(ctx, &samples, "getRecentPerformanceSamples", []interface{}{limit}); err != nil {
+		return nil, fmt.Errorf("failed to get performance samples: %w", err)
+	}
+	return samples, nil
+}
+
+// GetPrioritizationFees returns the lowest prioritization fee, in micro-lamports
+// per compute unit, that landed a transaction in each recent slot the node
+// remembers (up to 150). With accounts, only transactions writing to all of
+// them are considered.
+func (s *SolanaRPC) GetPrioritizationFees(accounts []string) ([]uint64, error) {
+	if len(accounts) > MaxPriorityFeeAccounts {
+		return nil, fmt.Errorf("too many accounts: %d, maximum %d", len(accounts), MaxPriorityFeeAccounts)
+	}
+	pubkeys := make(solana.PublicKeySlice, 0, len(accounts))
+	for _, account := range accounts {
+		pubkey, err := solana.PublicKeyFromBase58(account)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+		}
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := s.client.GetRecentPrioritizationFees(ctx, pubkeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prioritization fees: %w", err)
+	}
+
+	fees := make([]uint64, 0, len(results))
+	for _, result := range results {
+		fees = append(fees, result.PrioritizationFee)
+	}
+	return fees, nil
+}
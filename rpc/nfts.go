@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// MaxAssetsPerPage is the largest page getAssetsByOwner serves.
+const MaxAssetsPerPage = 1000
+
+// jsonRPCMethodNotFound is the JSON-RPC error code of nodes that do not implement a method
+const jsonRPCMethodNotFound = -32601
+
+// Metaplex token standards that mark a mint as fungible even when a wallet holds exactly one indivisible token
+const (
+	metaplexFungibleAsset = 1
+	metaplexFungible      = 2
+)
+
+// ErrDASUnsupported is returned when the RPC endpoint does not implement the Digital Asset Standard API.
+var ErrDASUnsupported = errors.New("RPC endpoint does not support the DAS API")
+
+// NFT is an NFT as described by its Metaplex metadata or, for compressed NFTs,
+// by the DAS API. Mint is the asset ID of compressed NFTs.
+type NFT struct {
+	Mint               string `json:"mint"`
+	Name               string `json:"name,omitempty"`
+	Symbol             string `json:"symbol,omitempty"`
+	URI                string `json:"uri,omitempty"`
+	Collection         string `json:"collection,omitempty"`
+	CollectionVerified bool   `json:"collection_verified,omitempty"`
+	Compressed         bool   `json:"compressed,omitempty"`
+	// Fungible is set for mints whose metadata declares a fungible token standard
+	Fungible bool `json:"fungible,omitempty"`
+}
+
+// AssetPage is one page of getAssetsByOwner. Burnt assets are left out of
+// Items but counted in Returned, so a full page can be told from the last one.
+type AssetPage struct {
+	Returned int
+	Items    []NFT
+}
+
+// GetNFTMetadata reads the Metaplex metadata accounts of mints. Mints without
+// one are left out of the result.
+func (s *SolanaRPC) GetNFTMetadata(mints []string) (map[string]*NFT, error) {
+	metadataKeys := make([]solana.PublicKey, len(mints))
+	for i, mint := range mints {
+		mintKey, err := solana.PublicKeyFromBase58(mint)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidAddress, mint, err)
+		}
+		if metadataKeys[i], _, err = solana.FindTokenMetadataAddress(mintKey); err != nil {
+			return nil, fmt.Errorf("failed to derive metadata address of %s: %w", mint, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	nfts := make(map[string]*NFT, len(mints))
+	for start := 0; start < len(metadataKeys); start += MaxAccountsPerCall {
+		end := min(start+MaxAccountsPerCall, len(metadataKeys))
+		result, err := s.client.GetMultipleAccountsWithOpts(ctx, metadataKeys[start:end], &rpc.GetMultipleAccountsOpts{
+			Encoding:   solana.EncodingBase64,
+			Commitment: rpc.CommitmentFinalized,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata accounts: %w", err)
+		}
+
+		for i, account := range result.Value {
+			if account == nil {
+				continue
+			}
+			nft, err := DecodeMetaplexNFT(account.Data.GetBinary())
+			if err != nil {
+				continue
+			}
+			nfts[mints[start+i]] = nft
+		}
+	}
+	return nfts, nil
+}
+
+// GetAssetsByOwner returns a page (starting at 1) of the assets owned by
+// ownerAddress through the DAS API, or ErrDASUnsupported.
+func (s *SolanaRPC) GetAssetsByOwner(ownerAddress string, page, limit int) (*AssetPage, error) {
+	if _, err := solana.PublicKeyFromBase58(ownerAddress); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var result struct {
+		Items []dasAsset `json:"items"`
+	}
+	params := map[string]interface{}{"ownerAddress": ownerAddress, "page": page, "limit": limit}
+	if err := s.callNamed(ctx, "getAssetsByOwner", params, &result); err != nil {
+		return nil, err
+	}
+
+	assets := &AssetPage{Returned: len(result.Items), Items: make([]NFT, 0, len(result.Items))}
+	for _, item := range result.Items {
+		if item.Burnt {
+			continue
+		}
+		nft := NFT{
+			Mint:       item.ID,
+			Name:       item.Content.Metadata.Name,
+			Symbol:     item.Content.Metadata.Symbol,
+			URI:        item.Content.JSONURI,
+			Compressed: item.Compression.Compressed,
+			Fungible:   item.Interface == "FungibleToken" || item.Interface == "FungibleAsset",
+		}
+		for _, group := range item.Grouping {
+			if group.Key == "collection" {
+				// Providers only started reporting verification lately and only list verified collections otherwise
+				nft.Collection = group.Value
+				nft.CollectionVerified = group.Verified == nil || *group.Verified
+			}
+		}
+		assets.Items = append(assets.Items, nft)
+	}
+	return assets, nil
+}
+
+// dasAsset is the part of a DAS asset that describes an NFT
+type dasAsset struct {
+	ID        string `json:"id"`
+	Interface string `json:"interface"`
+	Burnt     bool   `json:"burnt"`
+	Content   struct {
+		JSONURI  string `json:"json_uri"`
+		Metadata struct {
+			Name   string `json:"name"`
+			Symbol string `json:"symbol"`
+		} `json:"metadata"`
+	} `json:"content"`
+	Grouping []struct {
+		Key      string `json:"group_key"`
+		Value    string `json:"group_value"`
+		Verified *bool  `json:"verified"`
+	} `json:"grouping"`
+	Compression struct {
+		Compressed bool `json:"compressed"`
+	} `json:"compression"`
+}
+
+// callNamed sends a JSON-RPC request with named parameters, which the DAS API
+// expects but solana-go's client cannot send.
+func (s *SolanaRPC) callNamed(ctx context.Context, method string, params interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		// Plain Solana nodes behind some gateways answer unknown methods with an HTTP error page
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+			return ErrDASUnsupported
+		}
+		return fmt.Errorf("failed to call %s: status %d: %w", method, resp.StatusCode, err)
+	}
+	if response.Error != nil {
+		if response.Error.Code == jsonRPCMethodNotFound {
+			return ErrDASUnsupported
+		}
+		return fmt.Errorf("failed to call %s: %s", method, response.Error.Message)
+	}
+	if err := json.Unmarshal(response.Result, out); err != nil {
+		return fmt.Errorf("unexpected %s result: %w", method, err)
+	}
+	return nil
+}
+
+// DecodeMetaplexNFT reads a Metaplex metadata account up to its collection:
+// key, update authority, mint, name, symbol, URI, seller fee, creators, primary
+// sale and mutability flags, edition nonce, token standard and collection.
+// Accounts written before token standards and collections existed end early
+// and are returned without them.
+func DecodeMetaplexNFT(data []byte) (*NFT, error) {
+	if len(data) < 1 || data[0] != metaplexKeyMetadataV1 {
+		return nil, errors.New("not a Metaplex metadata account")
+	}
+	reader := borshReader{data: data, offset: 1 + 32}
+	nft := &NFT{Mint: reader.pubkey()}
+	nft.Name = reader.string()
+	nft.Symbol = reader.string()
+	nft.URI = reader.string()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	reader.skip(2)
+	if reader.option() {
+		reader.skip(int(reader.u32()) * (32 + 1 + 1))
+	}
+	reader.skip(1 + 1)
+	if reader.option() {
+		reader.skip(1)
+	}
+	if reader.option() {
+		standard := reader.u8()
+		nft.Fungible = reader.err == nil && (standard == metaplexFungibleAsset || standard == metaplexFungible)
+	}
+	if reader.option() {
+		verified := reader.u8() == 1
+		collection := reader.pubkey()
+		if reader.err == nil {
+			nft.Collection, nft.CollectionVerified = collection, verified
+		}
+	}
+	return nft, nil
+}
@@ -0,0 +1,143 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Pyth legacy (v2) price accounts start with this magic number.
+const pythMagic = 0xa1b2c3d4
+
+// Offsets into a Pyth v2 price account.
+const (
+	pythAccountTypeOffset = 8
+	pythExponentOffset    = 20
+	pythTimestampOffset   = 96
+	pythAggPriceOffset    = 208
+	pythAggConfOffset     = 216
+	pythAggStatusOffset   = 224
+	pythPriceAccountSize  = 240
+
+	pythAccountTypePrice = 3
+	pythStatusTrading    = 1
+)
+
+// pythPriceUpdateDiscriminator identifies the PriceUpdateV2 accounts written by the Pyth receiver program.
+var pythPriceUpdateDiscriminator = func() []byte {
+	hash := sha256.Sum256([]byte("account:PriceUpdateV2"))
+	return hash[:8]
+}()
+
+// OraclePrice is a decoded oracle price. Tradable is false when the publisher
+// reports the price as unusable (a non-trading status, or a partially verified update).
+type OraclePrice struct {
+	Price       float64   `json:"price"`
+	Confidence  float64   `json:"confidence"`
+	PublishTime time.Time `json:"publish_time"`
+	Tradable    bool      `json:"tradable"`
+}
+
+// DecodePythPrice decodes a Pyth price account: either a legacy v2 price account
+// or a PriceUpdateV2 account of the pull oracle.
+func DecodePythPrice(data []byte) (*OraclePrice, error) {
+	switch {
+	case len(data) >= 4 && binary.LittleEndian.Uint32(data) == pythMagic:
+		return decodePythV2(data)
+	case len(data) >= 8 && bytes.Equal(data[:8], pythPriceUpdateDiscriminator):
+		return decodePythPriceUpdate(data)
+	default:
+		return nil, errors.New("not a Pyth price account")
+	}
+}
+
+func decodePythV2(data []byte) (*OraclePrice, error) {
+	if len(data) < pythPriceAccountSize {
+		return nil, fmt.Errorf("price account has %d bytes, expected at least %d", len(data), pythPriceAccountSize)
+	}
+	if accountType := binary.LittleEndian.Uint32(data[pythAccountTypeOffset:]); accountType != pythAccountTypePrice {
+		return nil, fmt.Errorf("account type %d is not a Pyth price account", accountType)
+	}
+
+	scale := math.Pow10(int(int32(binary.LittleEndian.Uint32(data[pythExponentOffset:]))))
+	return &OraclePrice{
+		Price:       float64(int64(binary.LittleEndian.Uint64(data[pythAggPriceOffset:]))) * scale,
+		Confidence:  float64(binary.LittleEndian.Uint64(data[pythAggConfOffset:])) * scale,
+		PublishTime: time.Unix(int64(binary.LittleEndian.Uint64(data[pythTimestampOffset:])), 0).UTC(),
+		Tradable:    binary.LittleEndian.Uint32(data[pythAggStatusOffset:]) == pythStatusTrading,
+	}, nil
+}
+
+// decodePythPriceUpdate decodes a PriceUpdateV2: discriminator, write authority,
+// verification level (1 byte for Full, 2 for Partial) and the price feed message.
+func decodePythPriceUpdate(data []byte) (*OraclePrice, error) {
+	offset := 8 + 32
+	if len(data) <= offset {
+		return nil, errors.New("price update is truncated")
+	}
+
+	fullyVerified := data[offset] == 1
+	if fullyVerified {
+		offset++
+	} else {
+		offset += 2
+	}
+
+	// Feed ID, then price, confidence, exponent and publish time
+	offset += 32
+	if len(data) < offset+28 {
+		return nil, errors.New("price update is truncated")
+	}
+
+	price := int64(binary.LittleEndian.Uint64(data[offset:]))
+	confidence := binary.LittleEndian.Uint64(data[offset+8:])
+	scale := math.Pow10(int(int32(binary.LittleEndian.Uint32(data[offset+16:]))))
+	publishTime := int64(binary.LittleEndian.Uint64(data[offset+20:]))
+
+	return &OraclePrice{
+		Price:       float64(price) * scale,
+		Confidence:  float64(confidence) * scale,
+		PublishTime: time.Unix(publishTime, 0).UTC(),
+		Tradable:    fullyVerified,
+	}, nil
+}
+
+// GetPythPrice reads and decodes a Pyth price account at confirmed commitment,
+// since finalized prices already lag by a dozen seconds.
+func (s *SolanaRPC) GetPythPrice(priceAccount string) (*OraclePrice, error) {
+	pubkey, err := solana.PublicKeyFromBase58(priceAccount)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.client.GetAccountInfoWithOpts(ctx, pubkey, &rpc.GetAccountInfoOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotFound) {
+			return nil, fmt.Errorf("%w: price account %s", ErrAccountNotFound, priceAccount)
+		}
+		return nil, fmt.Errorf("failed to get price account: %w", err)
+	}
+	if result == nil || result.Value == nil {
+		return nil, fmt.Errorf("%w: price account %s", ErrAccountNotFound, priceAccount)
+	}
+
+	price, err := DecodePythPrice(result.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("price account %s: %w", priceAccount, err)
+	}
+	return price, nil
+}
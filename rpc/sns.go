@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// snsHashPrefix is prepended to names before hashing them into name account seeds
+const snsHashPrefix = "SPL Name Service"
+
+// snsOwnerOffset is where the owner follows the parent name in a name registry account
+const snsOwnerOffset = 32
+
+var (
+	// NameServiceProgramID owns every SNS name account.
+	NameServiceProgramID = solana.MustPublicKeyFromBase58("namesLPneVptA9Z5rqUDD9tMTWEJwofgaYwp8cawRkX")
+	// SOLTLDAuthority is the name account of the .sol top-level domain.
+	SOLTLDAuthority = solana.MustPublicKeyFromBase58("58PwtjSDuFHuUkYjH9BYnnQKHfwo9reZhC2zMJv9JPkx")
+)
+
+// ErrDomainNotFound is returned for .sol domains that are not registered.
+var ErrDomainNotFound = errors.New("domain not registered")
+
+// IsSOLDomain reports whether input is a .sol domain rather than an address.
+func IsSOLDomain(input string) bool {
+	return strings.HasSuffix(strings.ToLower(input), ".sol")
+}
+
+// SOLDomainKey derives the name account of a .sol domain such as "alice.sol".
+// Subdomains are not supported.
+func SOLDomainKey(domain string) (solana.PublicKey, error) {
+	name := strings.TrimSuffix(strings.ToLower(domain), ".sol")
+	if name == "" || strings.Contains(name, ".") {
+		return solana.PublicKey{}, fmt.Errorf("%w: %q is not a second-level .sol domain", ErrInvalidAddress, domain)
+	}
+
+	hashed := sha256.Sum256([]byte(snsHashPrefix + name))
+	// Domains have no name class, its seed is the zero key
+	key, _, err := solana.FindProgramAddress([][]byte{hashed[:], make([]byte, 32), SOLTLDAuthority[:]}, NameServiceProgramID)
+	if err != nil {
+		return solana.PublicKey{}, fmt.Errorf("failed to derive name account of %s: %w", domain, err)
+	}
+	return key, nil
+}
+
+// ResolveSOLDomain returns the owner of a .sol domain from its name account.
+func (s *SolanaRPC) ResolveSOLDomain(domain string) (string, error) {
+	key, err := SOLDomainKey(domain)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.client.GetAccountInfoWithOpts(ctx, key, &rpc.GetAccountInfoOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentFinalized,
+	})
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotFound) {
+			return "", fmt.Errorf("%w: %s", ErrDomainNotFound, domain)
+		}
+		return "", fmt.Errorf("failed to get name account: %w", err)
+	}
+	if result == nil || result.Value == nil {
+		return "", fmt.Errorf("%w: %s", ErrDomainNotFound, domain)
+	}
+	if !result.Value.Owner.Equals(NameServiceProgramID) {
+		return "", fmt.Errorf("name account of %s is not owned by the name service", domain)
+	}
+
+	data := result.Value.Data.GetBinary()
+	if len(data) < snsOwnerOffset+32 {
+		return "", fmt.Errorf("name account of %s is truncated", domain)
+	}
+	return solana.PublicKeyFromBytes(data[snsOwnerOffset : snsOwnerOffset+32]).String(), nil
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,32 +10,70 @@ import (
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
+var (
+	// ErrInvalidAddress is returned for input that is not a base58 public key. No RPC call is made.
+	ErrInvalidAddress = errors.New("invalid wallet address")
+	// ErrAccountNotFound is returned when the account does not exist on chain.
+	ErrAccountNotFound = errors.New("account not found")
+)
+
+// BalanceCommitment is the commitment balances are read at, whether they are
+// polled or pushed by an account subscription, so both report the same value.
+const BalanceCommitment = rpc.CommitmentFinalized
+
 type SolanaRPC struct {
 	client *rpc.Client
+	// endpoint is kept for methods outside the Solana RPC spec, which solana-go cannot call
+	endpoint string
 }
 
 func NewSolanaRPC(endpoint string) *SolanaRPC {
 	client := rpc.New(endpoint)
 	return &SolanaRPC{
-		client: client,
+		client:   client,
+		endpoint: endpoint,
 	}
 }
 
 func (s *SolanaRPC) GetBalance(walletAddress string) (float64, error) {
 	pubkey, err := solana.PublicKeyFromBase58(walletAddress)
 	if err != nil {
-		return 0, fmt.Errorf("invalid wallet address: %v", err)
+		return 0, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	balance, err := s.client.GetBalance(ctx, pubkey, rpc.CommitmentFinalized)
+	balance, err := s.client.GetBalance(ctx, pubkey, BalanceCommitment)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get balance: %v", err)
 	}
 
-	// Convert lamports to SOL
-	balanceSOL := float64(balance.Value) / 1_000_000_000
-	return balanceSOL, nil
+	// Accounts without lamports are purged by the runtime, so a zero balance means the account does not exist
+	if balance.Value == 0 {
+		return 0, ErrAccountNotFound
+	}
+
+	return LamportsToSOL(balance.Value), nil
+}
+
+// LamportsToSOL converts an amount in lamports to SOL.
+func LamportsToSOL(lamports uint64) float64 {
+	return float64(lamports) / 1_000_000_000
+}
+
+// MaxAccountDataSize is the most data an account can hold, 10 MiB
+const MaxAccountDataSize = 10 * 1024 * 1024
+
+// GetRentExemptMinimum returns the lamports an account holding dataSize bytes
+// needs to be rent exempt, as computed by the node from the current rent parameters.
+func (s *SolanaRPC) GetRentExemptMinimum(dataSize uint64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lamports, err := s.client.GetMinimumBalanceForRentExemption(ctx, dataSize, rpc.CommitmentFinalized)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rent-exempt minimum: %w", err)
+	}
+	return lamports, nil
 }
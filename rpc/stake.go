@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Layout of a StakeStateV2 account. Meta (rent reserve, authorities, lockup)
+// follows the 4 byte state tag, the delegation follows Meta for delegated accounts.
+const (
+	StakeAccountSize = 200
+
+	stakeStateInitialized = 1
+	stakeStateDelegated   = 2
+
+	stakeRentReserveOffset       = 4
+	stakeStakerOffset            = 12
+	stakeWithdrawerOffset        = 44
+	stakeVoterOffset             = 124
+	stakeAmountOffset            = 156
+	stakeActivationEpochOffset   = 164
+	stakeDeactivationEpochOffset = 172
+)
+
+// StakeAccount is a decoded stake account. Voter is empty and the epochs are
+// zero for accounts that were never delegated.
+type StakeAccount struct {
+	Address           string `json:"address"`
+	Lamports          uint64 `json:"lamports"`
+	RentExemptReserve uint64 `json:"rent_exempt_reserve"`
+	Staker            string `json:"staker"`
+	Withdrawer        string `json:"withdrawer"`
+	Voter             string `json:"voter,omitempty"`
+	Stake             uint64 `json:"stake,omitempty"`
+	ActivationEpoch   uint64 `json:"activation_epoch,omitempty"`
+	// DeactivationEpoch is math.MaxUint64 while the stake has not been deactivated
+	DeactivationEpoch uint64 `json:"deactivation_epoch,omitempty"`
+}
+
+// Delegated reports whether the account has ever been delegated to a validator.
+func (a StakeAccount) Delegated() bool {
+	return a.Voter != ""
+}
+
+// Deactivated reports whether deactivation of the delegation has been requested.
+func (a StakeAccount) Deactivated() bool {
+	return a.Delegated() && a.DeactivationEpoch != math.MaxUint64
+}
+
+// ParseStakeAccount decodes the data of a stake account. Uninitialized accounts
+// and other states are rejected.
+func ParseStakeAccount(address string, lamports uint64, data []byte) (*StakeAccount, error) {
+	if len(data) < StakeAccountSize {
+		return nil, fmt.Errorf("stake account %s has %d bytes, expected %d", address, len(data), StakeAccountSize)
+	}
+
+	state := binary.LittleEndian.Uint32(data[0:4])
+	if state != stakeStateInitialized && state != stakeStateDelegated {
+		return nil, fmt.Errorf("stake account %s is in unsupported state %d", address, state)
+	}
+
+	account := &StakeAccount{
+		Address:           address,
+		Lamports:          lamports,
+		RentExemptReserve: binary.LittleEndian.Uint64(data[stakeRentReserveOffset:]),
+		Staker:            solana.PublicKeyFromBytes(data[stakeStakerOffset : stakeStakerOffset+32]).String(),
+		Withdrawer:        solana.PublicKeyFromBytes(data[stakeWithdrawerOffset : stakeWithdrawerOffset+32]).String(),
+	}
+	if state == stakeStateDelegated {
+		account.Voter = solana.PublicKeyFromBytes(data[stakeVoterOffset : stakeVoterOffset+32]).String()
+		account.Stake = binary.LittleEndian.Uint64(data[stakeAmountOffset:])
+		account.ActivationEpoch = binary.LittleEndian.Uint64(data[stakeActivationEpochOffset:])
+		account.DeactivationEpoch = binary.LittleEndian.Uint64(data[stakeDeactivationEpochOffset:])
+	}
+	return account, nil
+}
+
+// GetStakeAccounts returns the stake accounts whose staker or withdrawer authority is walletAddress.
+// It runs two getProgramAccounts scans of the Stake program, one per authority,
+// in parallel. Many public RPC endpoints reject or heavily rate limit
+// getProgramAccounts on the Stake program, so this needs an RPC provider that serves it.
+func (s *SolanaRPC) GetStakeAccounts(walletAddress string) ([]StakeAccount, error) {
+	pubkey, err := solana.PublicKeyFromBase58(walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	offsets := []uint64{stakeStakerOffset, stakeWithdrawerOffset}
+	scans := make([]rpc.GetProgramAccountsResult, len(offsets))
+	errs := make([]error, len(offsets))
+	var wg sync.WaitGroup
+	for i, offset := range offsets {
+		wg.Add(1)
+		go func(i int, offset uint64) {
+			defer wg.Done()
+			scans[i], errs[i] = s.client.GetProgramAccountsWithOpts(ctx, solana.StakeProgramID, &rpc.GetProgramAccountsOpts{
+				Commitment: rpc.CommitmentFinalized,
+				Encoding:   solana.EncodingBase64,
+				Filters: []rpc.RPCFilter{
+					{DataSize: StakeAccountSize},
+					{Memcmp: &rpc.RPCFilterMemcmp{Offset: offset, Bytes: solana.Base58(pubkey.Bytes())}},
+				},
+			})
+		}(i, offset)
+	}
+	wg.Wait()
+
+	accounts := []StakeAccount{}
+	seen := map[string]bool{}
+	// The staker and withdrawer are usually the same key, so most accounts match both filters
+	for i, results := range scans {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to get stake accounts: %w", errs[i])
+		}
+
+		for _, result := range results {
+			address := result.Pubkey.String()
+			if seen[address] || result.Account == nil || result.Account.Data == nil {
+				continue
+			}
+			seen[address] = true
+
+			account, err := ParseStakeAccount(address, result.Account.Lamports, result.Account.Data.GetBinary())
+			if err != nil {
+				// Uninitialized accounts have no authorities worth reporting
+				continue
+			}
+			accounts = append(accounts, *account)
+		}
+	}
+	return accounts, nil
+}
+
+// GetEpoch returns the current finalized epoch.
+func (s *SolanaRPC) GetEpoch() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := s.client.GetEpochInfo(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get epoch info: %w", err)
+	}
+	return info.Epoch, nil
+}
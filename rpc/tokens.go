@@ -0,0 +1,277 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Layout of SPL mints. Token-2022 mints put the account type after the padding
+// to the token account size and the TLV extensions after that.
+const (
+	mintDecimalsOffset      = 44
+	mintSize                = 82
+	token2022AccountTypePos = 165
+	token2022AccountMint    = 1
+	token2022MetadataExt    = 19
+
+	metaplexKeyMetadataV1 = 4
+)
+
+// TokenHolding is a token account owned by a wallet.
+type TokenHolding struct {
+	Account  string `json:"account"`
+	Mint     string `json:"mint"`
+	Program  string `json:"program"`
+	Amount   uint64 `json:"amount"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// TokenMetadata is what the chain knows about a mint. Name, Symbol and URI come
+// from the Token-2022 metadata extension or, failing that, the Metaplex metadata account.
+type TokenMetadata struct {
+	Mint     string `json:"mint"`
+	Program  string `json:"program"`
+	Decimals uint8  `json:"decimals"`
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	URI      string `json:"uri,omitempty"`
+}
+
+// GetTokenAccounts returns the SPL Token and Token-2022 accounts owned by walletAddress.
+func (s *SolanaRPC) GetTokenAccounts(walletAddress string) ([]TokenHolding, error) {
+	pubkey, err := solana.PublicKeyFromBase58(walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	holdings := []TokenHolding{}
+	for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		result, err := s.client.GetTokenAccountsByOwner(ctx, pubkey,
+			&rpc.GetTokenAccountsConfig{ProgramId: program.ToPointer()},
+			&rpc.GetTokenAccountsOpts{Commitment: rpc.CommitmentFinalized, Encoding: solana.EncodingJSONParsed},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token accounts: %w", err)
+		}
+
+		for _, account := range result.Value {
+			if account.Account.Data == nil {
+				continue
+			}
+			holding, err := parseTokenAccount(account.Account.Data.GetRawJSON())
+			if err != nil {
+				return nil, fmt.Errorf("token account %s: %w", account.Pubkey, err)
+			}
+			holding.Account = account.Pubkey.String()
+			holding.Program = program.String()
+			holdings = append(holdings, *holding)
+		}
+	}
+	return holdings, nil
+}
+
+func parseTokenAccount(raw json.RawMessage) (*TokenHolding, error) {
+	var parsed struct {
+		Parsed struct {
+			Info struct {
+				Mint        string `json:"mint"`
+				TokenAmount struct {
+					Amount   string `json:"amount"`
+					Decimals uint8  `json:"decimals"`
+				} `json:"tokenAmount"`
+			} `json:"info"`
+		} `json:"parsed"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("unexpected encoding: %w", err)
+	}
+
+	info := parsed.Parsed.Info
+	amount, err := strconv.ParseUint(info.TokenAmount.Amount, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", info.TokenAmount.Amount)
+	}
+	return &TokenHolding{Mint: info.Mint, Amount: amount, Decimals: info.TokenAmount.Decimals}, nil
+}
+
+// GetTokenMetadatas reads mints together with their Metaplex metadata accounts
+// and returns the metadata or the error of each mint, in order. The accounts
+// are fetched in getMultipleAccounts batches of MaxAccountsPerCall.
+func (s *SolanaRPC) GetTokenMetadatas(mints []string) ([]*TokenMetadata, []error) {
+	metadatas := make([]*TokenMetadata, len(mints))
+	errs := make([]error, len(mints))
+
+	// Every mint takes two keys, the mint followed by its metadata account
+	indexes := []int{}
+	keys := []solana.PublicKey{}
+	for i, mint := range mints {
+		mintKey, err := solana.PublicKeyFromBase58(mint)
+		if err != nil {
+			errs[i] = fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+			continue
+		}
+		metadataKey, _, err := solana.FindTokenMetadataAddress(mintKey)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to derive metadata address of %s: %w", mint, err)
+			continue
+		}
+		indexes = append(indexes, i)
+		keys = append(keys, mintKey, metadataKey)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// MaxAccountsPerCall is even, so a mint is never split from its metadata account
+	for start := 0; start < len(keys); start += MaxAccountsPerCall {
+		end := min(start+MaxAccountsPerCall, len(keys))
+
+		result, err := s.client.GetMultipleAccountsWithOpts(ctx, keys[start:end], &rpc.GetMultipleAccountsOpts{
+			Encoding:   solana.EncodingBase64,
+			Commitment: rpc.CommitmentFinalized,
+		})
+		if err == nil && len(result.Value) != end-start {
+			err = fmt.Errorf("expected %d accounts, got %d", end-start, len(result.Value))
+		}
+		for j, i := range indexes[start/2 : end/2] {
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to get mint: %w", err)
+				continue
+			}
+			metadatas[i], errs[i] = decodeTokenMetadata(mints[i], result.Value[2*j], result.Value[2*j+1])
+		}
+	}
+	return metadatas, errs
+}
+
+// decodeTokenMetadata describes a mint from its account and its Metaplex
+// metadata account, either of which may be missing.
+func decodeTokenMetadata(mint string, mintAccount, metadataAccount *rpc.Account) (*TokenMetadata, error) {
+	if mintAccount == nil {
+		return nil, fmt.Errorf("%w: mint %s", ErrAccountNotFound, mint)
+	}
+	mintData := mintAccount.Data.GetBinary()
+	if len(mintData) < mintSize {
+		return nil, fmt.Errorf("%s is not a mint", mint)
+	}
+
+	metadata := &TokenMetadata{
+		Mint:     mint,
+		Program:  mintAccount.Owner.String(),
+		Decimals: mintData[mintDecimalsOffset],
+	}
+	if mintAccount.Owner.Equals(solana.Token2022ProgramID) {
+		if name, symbol, uri, found := DecodeToken2022Metadata(mintData); found {
+			metadata.Name, metadata.Symbol, metadata.URI = name, symbol, uri
+			return metadata, nil
+		}
+	}
+	if metadataAccount != nil {
+		if name, symbol, uri, err := DecodeMetaplexMetadata(metadataAccount.Data.GetBinary()); err == nil {
+			metadata.Name, metadata.Symbol, metadata.URI = name, symbol, uri
+		}
+	}
+	return metadata, nil
+}
+
+// DecodeMetaplexMetadata reads name, symbol and URI from a Metaplex metadata
+// account: key, update authority and mint, followed by three borsh strings
+// that are padded with NUL bytes.
+func DecodeMetaplexMetadata(data []byte) (name, symbol, uri string, err error) {
+	nft, err := DecodeMetaplexNFT(data)
+	if err != nil {
+		return "", "", "", err
+	}
+	return nft.Name, nft.Symbol, nft.URI, nil
+}
+
+// DecodeToken2022Metadata finds the token metadata extension of a Token-2022
+// mint. Its value holds the update authority and mint followed by name, symbol and URI.
+func DecodeToken2022Metadata(data []byte) (name, symbol, uri string, found bool) {
+	if len(data) <= token2022AccountTypePos || data[token2022AccountTypePos] != token2022AccountMint {
+		return "", "", "", false
+	}
+
+	for offset := token2022AccountTypePos + 1; offset+4 <= len(data); {
+		extension := binary.LittleEndian.Uint16(data[offset:])
+		length := int(binary.LittleEndian.Uint16(data[offset+2:]))
+		offset += 4
+		if offset+length > len(data) {
+			return "", "", "", false
+		}
+
+		if extension == token2022MetadataExt {
+			reader := borshReader{data: data[offset : offset+length], offset: 32 + 32}
+			name, symbol, uri = reader.string(), reader.string(), reader.string()
+			return name, symbol, uri, reader.err == nil
+		}
+		offset += length
+	}
+	return "", "", "", false
+}
+
+type borshReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *borshReader) string() string {
+	length := r.u32()
+	return strings.TrimRight(string(r.take(int(length))), "\x00")
+}
+
+func (r *borshReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.offset+n > len(r.data) {
+		r.err = errors.New("metadata is truncated")
+		return nil
+	}
+	value := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return value
+}
+
+func (r *borshReader) skip(n int) {
+	r.take(n)
+}
+
+func (r *borshReader) u8() uint8 {
+	if value := r.take(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (r *borshReader) u32() uint32 {
+	if value := r.take(4); value != nil {
+		return binary.LittleEndian.Uint32(value)
+	}
+	return 0
+}
+
+// option reads the tag of a borsh Option, false once the data has run out
+func (r *borshReader) option() bool {
+	return r.u8() == 1 && r.err == nil
+}
+
+func (r *borshReader) pubkey() string {
+	if value := r.take(32); value != nil {
+		return solana.PublicKeyFromBytes(value).String()
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// TransactionDetails is the part of a finalized transaction needed to work out
+// what it did to any of its accounts. Accounts, PreBalances and PostBalances are
+// index aligned, balances are in lamports.
+type TransactionDetails struct {
+	Signature     string         `json:"signature"`
+	Slot          uint64         `json:"slot"`
+	BlockTime     int64          `json:"block_time,omitempty"`
+	Fee           uint64         `json:"fee"`
+	Failed        bool           `json:"failed,omitempty"`
+	Accounts      []string       `json:"accounts"`
+	PreBalances   []uint64       `json:"pre_balances"`
+	PostBalances  []uint64       `json:"post_balances"`
+	TokenBalances []TokenBalance `json:"token_balances,omitempty"`
+}
+
+// TokenBalance is the balance of one token account before and after a transaction,
+// in raw units. Pre is 0 for accounts created by the transaction, Post for closed ones.
+type TokenBalance struct {
+	Account  string `json:"account"`
+	Owner    string `json:"owner"`
+	Mint     string `json:"mint"`
+	Decimals uint8  `json:"decimals"`
+	Pre      uint64 `json:"pre"`
+	Post     uint64 `json:"post"`
+}
+
+// GetTransactionDetails fetches a finalized transaction and flattens its balance changes.
+func (s *SolanaRPC) GetTransactionDetails(signature string) (*TransactionDetails, error) {
+	result, keys, err := s.getTransaction(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &TransactionDetails{
+		Signature:    signature,
+		Slot:         result.Slot,
+		Fee:          result.Meta.Fee,
+		Failed:       result.Meta.Err != nil,
+		Accounts:     make([]string, len(keys)),
+		PreBalances:  result.Meta.PreBalances,
+		PostBalances: result.Meta.PostBalances,
+	}
+	if result.BlockTime != nil {
+		details.BlockTime = int64(*result.BlockTime)
+	}
+	for i, key := range keys {
+		details.Accounts[i] = key.String()
+	}
+
+	// Token balances are keyed by account index; an account missing from one side was created or closed
+	byIndex := map[uint16]*TokenBalance{}
+	order := []uint16{}
+	balanceFor := func(index uint16, owner, mint string, decimals uint8) *TokenBalance {
+		if balance, exists := byIndex[index]; exists {
+			return balance
+		}
+		balance := &TokenBalance{Owner: owner, Mint: mint, Decimals: decimals}
+		if int(index) < len(details.Accounts) {
+			balance.Account = details.Accounts[index]
+		}
+		byIndex[index] = balance
+		order = append(order, index)
+		return balance
+	}
+
+	for _, pre := range result.Meta.PreTokenBalances {
+		amount, decimals, err := rawTokenAmount(pre.UiTokenAmount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token balance in transaction %s: %w", signature, err)
+		}
+		owner := ""
+		if pre.Owner != nil {
+			owner = pre.Owner.String()
+		}
+		balanceFor(pre.AccountIndex, owner, pre.Mint.String(), decimals).Pre = amount
+	}
+	for _, post := range result.Meta.PostTokenBalances {
+		amount, decimals, err := rawTokenAmount(post.UiTokenAmount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token balance in transaction %s: %w", signature, err)
+		}
+		owner := ""
+		if post.Owner != nil {
+			owner = post.Owner.String()
+		}
+		balance := balanceFor(post.AccountIndex, owner, post.Mint.String(), decimals)
+		balance.Post = amount
+		if balance.Owner == "" {
+			balance.Owner = owner
+		}
+	}
+
+	for _, index := range order {
+		details.TokenBalances = append(details.TokenBalances, *byIndex[index])
+	}
+	return details, nil
+}
+
+func rawTokenAmount(amount *rpc.UiTokenAmount) (uint64, uint8, error) {
+	if amount == nil {
+		return 0, 0, nil
+	}
+	raw, err := strconv.ParseUint(amount.Amount, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return raw, amount.Decimals, nil
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAccountRPC struct {
+	mock.Mock
+}
+
+func (m *MockAccountRPC) GetAccounts(addresses []string, encoding string, slice *rpc.DataSlice) ([]*rpc.AccountInfo, error) {
+	args := m.Called(addresses, encoding, slice)
+	return args.Get(0).([]*rpc.AccountInfo), args.Error(1)
+}
+
+var testAccountInfo = &rpc.AccountInfo{
+	Lamports:  1_000_000_000,
+	Owner:     "11111111111111111111111111111111",
+	RentEpoch: 18446744073709551615,
+	Data:      json.RawMessage(`["","base64"]`),
+}
+
+func TestGetAccountsReportsPerAddressAndCaches(t *testing.T) {
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockAccountRPC{}
+	mockRPC.On("GetAccounts", []string{testWallet, testOtherWallet}, "base64", (*rpc.DataSlice)(nil)).
+		Return([]*rpc.AccountInfo{testAccountInfo, nil}, nil).Once()
+	mockRPC.On("GetAccounts", []string{testOtherWallet}, "base64", (*rpc.DataSlice)(nil)).
+		Return([]*rpc.AccountInfo{nil}, nil).Once()
+	service := data.NewAccountService(mockRPC, cache)
+
+	accounts, err := service.GetAccounts([]string{testWallet, testOtherWallet, "not-an-address"}, "", nil)
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+	assert.Equal(t, testWallet, accounts[0].Address)
+	assert.Equal(t, uint64(1_000_000_000), accounts[0].Lamports)
+	assert.Equal(t, testAccountInfo.Owner, accounts[0].Owner)
+	assert.JSONEq(t, `["","base64"]`, string(accounts[0].Data))
+	assert.Equal(t, rpc.ErrAccountNotFound.Error(), accounts[1].Error)
+	assert.Contains(t, accounts[2].Error, rpc.ErrInvalidAddress.Error())
+
+	// Found accounts are cached, missing ones are asked for again
+	accounts, err = service.GetAccounts([]string{testWallet, testOtherWallet}, "base64", nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1_000_000_000), accounts[0].Lamports)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestGetAccountsCachesPerSlice(t *testing.T) {
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	slice := &rpc.DataSlice{Offset: 0, Length: 8}
+	mockRPC := &MockAccountRPC{}
+	mockRPC.On("GetAccounts", []string{testWallet}, "base64", (*rpc.DataSlice)(nil)).Return([]*rpc.AccountInfo{testAccountInfo}, nil).Once()
+	mockRPC.On("GetAccounts", []string{testWallet}, "base64", slice).Return([]*rpc.AccountInfo{testAccountInfo}, nil).Once()
+	service := data.NewAccountService(mockRPC, cache)
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetAccounts([]string{testWallet}, "base64", nil)
+		require.NoError(t, err)
+		_, err = service.GetAccounts([]string{testWallet}, "base64", &models.DataSlice{Offset: 0, Length: 8})
+		require.NoError(t, err)
+	}
+	mockRPC.AssertExpectations(t)
+}
+
+func TestAccountsHandlerValidation(t *testing.T) {
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockAccountRPC{}
+	mockRPC.On("GetAccounts", []string{testWallet}, "jsonParsed", (*rpc.DataSlice)(nil)).Return([]*rpc.AccountInfo{testAccountInfo}, nil)
+	accountHandler := handlers.NewAccountHandler(data.NewAccountService(mockRPC, cache))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		accountHandler.AccountsHandler(rr, httptest.NewRequest("POST", "/api/accounts", bytes.NewBufferString(body)))
+		return rr
+	}
+
+	rr := post(`{"addresses": ["` + testWallet + `"], "encoding": "jsonParsed"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data []models.AccountInfo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, uint64(18446744073709551615), response.Data[0].RentEpoch)
+
+	invalid := []string{
+		`not json`,
+		`{"addresses": []}`,
+		`{"addresses": ["` + testWallet + `"], "encoding": "base58"}`,
+		`{"addresses": ["` + testWallet + `"], "encoding": "jsonParsed", "data_slice": {"offset": 0, "length": 8}}`,
+	}
+	for _, body := range invalid {
+		assert.Equal(t, http.StatusBadRequest, post(body).Code, body)
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyAccount(t *testing.T) {
+	system := solana.SystemProgramID.String()
+	token := solana.TokenProgramID.String()
+	token2022 := solana.Token2022ProgramID.String()
+
+	for _, tc := range []struct {
+		name        string
+		owner       string
+		executable  bool
+		space       uint64
+		accountType byte
+		expected    string
+	}{
+		{"wallet", system, false, 0, 0, rpc.AccountKindSystemWallet},
+		{"nonce", system, false, 80, 0, rpc.AccountKindOther},
+		{"token account", token, false, 165, 0, rpc.AccountKindToken},
+		{"mint", token, false, 82, 0, rpc.AccountKindMint},
+		{"multisig", token, false, 355, 0, rpc.AccountKindMultisig},
+		{"extended mint", token2022, false, 234, 1, rpc.AccountKindMint},
+		{"extended token account", token2022, false, 170, 2, rpc.AccountKindToken},
+		{"program", solana.BPFLoaderUpgradeableProgramID.String(), true, 36, 0, rpc.AccountKindProgram},
+		{"program data", solana.BPFLoaderUpgradeableProgramID.String(), false, 4000, 0, rpc.AccountKindOther},
+		{"stake", solana.StakeProgramID.String(), false, 200, 0, rpc.AccountKindStake},
+		{"vote", solana.VoteProgramID.String(), false, 3762, 0, rpc.AccountKindVote},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, rpc.ClassifyAccount(tc.owner, tc.executable, tc.space, tc.accountType))
+		})
+	}
+}
+
+func TestValidateAddressOffline(t *testing.T) {
+	pda, _, err := solana.FindTokenMetadataAddress(solana.MustPublicKeyFromBase58(testMint))
+	require.NoError(t, err)
+
+	wallet := data.ValidateAddress(testWallet)
+	assert.True(t, wallet.Valid)
+	assert.Equal(t, "wallet", wallet.Curve)
+
+	derived := data.ValidateAddress(pda.String())
+	assert.True(t, derived.Valid)
+	assert.Equal(t, "pda", derived.Curve)
+
+	for _, invalid := range []string{"", "not-base58!", "3yZe7d"} {
+		validation := data.ValidateAddress(invalid)
+		assert.False(t, validation.Valid, invalid)
+		assert.NotEmpty(t, validation.Error, invalid)
+		assert.Empty(t, validation.Curve, invalid)
+	}
+
+	// Offline validation never reaches the RPC node
+	mockRPC := &MockAccountRPC{}
+	validations := data.NewAddressService(mockRPC).Validate([]string{testWallet, "nope"}, true)
+	require.Len(t, validations, 2)
+	assert.Nil(t, validations[0].Exists)
+	mockRPC.AssertNotCalled(t, "GetAccounts", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestValidateAddressLookup(t *testing.T) {
+	slice := rpc.Token2022AccountTypeSlice
+	mockRPC := &MockAccountRPC{}
+	mockRPC.On("GetAccounts", []string{testWallet, testMint, testOtherWallet}, rpc.EncodingBase64, &slice).Return([]*rpc.AccountInfo{
+		testAccountInfo,
+		{Owner: solana.Token2022ProgramID.String(), Space: 300, Data: json.RawMessage(`["AQ==","base64"]`)},
+		nil,
+	}, nil).Once()
+	mockRPC.On("GetAccounts", []string{testWallet}, rpc.EncodingBase64, &slice).Return([]*rpc.AccountInfo(nil), errors.New("rpc unavailable"))
+	service := data.NewAddressService(mockRPC)
+
+	validations := service.Validate([]string{testWallet, "nope", testMint, testOtherWallet}, false)
+	require.Len(t, validations, 4)
+	require.NotNil(t, validations[0].Exists)
+	assert.True(t, *validations[0].Exists)
+	assert.Equal(t, rpc.AccountKindSystemWallet, validations[0].AccountType)
+	assert.Nil(t, validations[1].Exists)
+	assert.Equal(t, solana.Token2022ProgramID.String(), validations[2].Owner)
+	assert.Equal(t, rpc.AccountKindMint, validations[2].AccountType)
+	require.NotNil(t, validations[3].Exists)
+	assert.False(t, *validations[3].Exists)
+	assert.Empty(t, validations[3].AccountType)
+
+	// The offline checks survive a failed lookup
+	validations = service.Validate([]string{testWallet}, false)
+	assert.True(t, validations[0].Valid)
+	assert.Equal(t, "wallet", validations[0].Curve)
+	assert.Nil(t, validations[0].Exists)
+	assert.Equal(t, "rpc unavailable", validations[0].LookupError)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestValidateAddressHandler(t *testing.T) {
+	handler := handlers.NewAddressHandler(data.NewAddressService(&MockAccountRPC{}))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ValidateAddressHandler(rr, httptest.NewRequest("POST", "/api/validate-address", bytes.NewBufferString(body)))
+		return rr
+	}
+
+	rr := post(`{"addresses": ["` + testWallet + `", "nope"], "offline": true}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data []models.AddressValidation `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.True(t, response.Data[0].Valid)
+	assert.False(t, response.Data[1].Valid)
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"addresses": []}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+}
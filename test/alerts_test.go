@@ -0,0 +1,586 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/middleware"
+	"nova-api/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAlertStore is an in-memory AlertStore
+type memoryAlertStore struct {
+	mutex  sync.Mutex
+	rules  map[string]models.AlertRule
+	leases map[string]memoryLease
+}
+
+func newMemoryAlertStore() *memoryAlertStore {
+	return &memoryAlertStore{rules: make(map[string]models.AlertRule), leases: make(map[string]memoryLease)}
+}
+
+func (s *memoryAlertStore) CreateAlertRule(rule *models.AlertRule) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.rules[rule.ID] = *rule
+	return nil
+}
+
+func (s *memoryAlertStore) ListAlertRules(apiKey string) ([]models.AlertRule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rules := []models.AlertRule{}
+	for _, rule := range s.rules {
+		if rule.APIKey == apiKey {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (s *memoryAlertStore) AllAlertRules() ([]models.AlertRule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rules := []models.AlertRule{}
+	for _, rule := range s.rules {
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (s *memoryAlertStore) DeleteAlertRule(apiKey, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if rule, exists := s.rules[id]; !exists || rule.APIKey != apiKey {
+		return data.ErrAlertRuleNotFound
+	}
+	delete(s.rules, id)
+	return nil
+}
+
+func (s *memoryAlertStore) UpdateAlertState(id, state string, changedAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rule := s.rules[id]
+	rule.State = state
+	rule.StateChangedAt = changedAt
+	s.rules[id] = rule
+	return nil
+}
+
+func (s *memoryAlertStore) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lease, exists := s.leases[name]
+	if exists && lease.holder != holder && time.Now().Before(lease.expires) {
+		return false, nil
+	}
+	s.leases[name] = memoryLease{holder: holder, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryAlertStore) State(id string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.rules[id].State
+}
+
+// recordingAlertChannel collects the notifications it is sent
+type recordingAlertChannel struct {
+	mutex         sync.Mutex
+	notifications []models.AlertNotification
+}
+
+func (c *recordingAlertChannel) Send(notification models.AlertNotification) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.notifications = append(c.notifications, notification)
+	return nil
+}
+
+func (c *recordingAlertChannel) States() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var states []string
+	for _, notification := range c.notifications {
+		states = append(states, notification.State)
+	}
+	return states
+}
+
+func newRecordingChannel() *recordingAlertChannel {
+	channel := &recordingAlertChannel{}
+	data.RegisterAlertChannel("recording", func(models.AlertChannelConfig) (data.AlertChannel, error) {
+		return channel, nil
+	})
+	return channel
+}
+
+func newTestAlertEvaluator(t *testing.T, store data.AlertStore, currentBalance float64) (*data.AlertEvaluator, *data.BalanceFeed) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", mock.Anything).Return(currentBalance, nil)
+
+	rentRPC := &MockRentRPC{}
+	rentRPC.On("GetEpoch").Return(uint64(500), nil).Maybe()
+	rentRPC.On("GetRentExemptMinimum", uint64(0)).Return(uint64(890_880), nil).Maybe()
+	rentRPC.On("GetRentExemptMinimum", uint64(165)).Return(uint64(2_039_280), nil).Maybe()
+
+	service := data.NewBalanceService(mockRPC, data.NoopBalanceCache{})
+	feed := data.NewBalanceFeed(service, nil)
+	evaluator, err := data.NewAlertEvaluator(store, feed, service, data.NewRentService(rentRPC, data.NoopBalanceCache{}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		evaluator.Close()
+		feed.Close()
+	})
+	return evaluator, feed
+}
+
+func TestAlertFiresOnceAndResolves(t *testing.T) {
+	verifyNoLeaks(t)
+	channel := newRecordingChannel()
+	store := newMemoryAlertStore()
+	evaluator, feed := newTestAlertEvaluator(t, store, 150)
+
+	rule, err := evaluator.Register("key-1", models.AlertRuleRequest{
+		Name:      "treasury low",
+		Wallet:    testWallet,
+		Condition: models.AlertCondition{Type: "below", Value: 100},
+		Channels:  []models.AlertChannelConfig{{Type: "recording"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", rule.State)
+
+	for _, balance := range []float64{90, 80, 70} {
+		feed.Publish(testWallet, balance)
+	}
+	require.Eventually(t, func() bool { return store.State(rule.ID) == "firing" }, time.Second, 10*time.Millisecond)
+
+	feed.Publish(testWallet, 120)
+	require.Eventually(t, func() bool { return len(channel.States()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"firing", "resolved"}, channel.States())
+	assert.Equal(t, "resolved", store.State(rule.ID))
+}
+
+func TestAlertDropPercentWithinWindow(t *testing.T) {
+	verifyNoLeaks(t)
+	channel := newRecordingChannel()
+	evaluator, feed := newTestAlertEvaluator(t, newMemoryAlertStore(), 100)
+
+	_, err := evaluator.Register("key-1", models.AlertRuleRequest{
+		Wallet:    testWallet,
+		Condition: models.AlertCondition{Type: "drop_percent", Value: 10, WindowSeconds: 3600},
+		Channels:  []models.AlertChannelConfig{{Type: "recording"}},
+	})
+	require.NoError(t, err)
+
+	feed.Publish(testWallet, 95)
+	feed.Publish(testWallet, 89)
+	require.Eventually(t, func() bool { return len(channel.States()) == 1 }, time.Second, 10*time.Millisecond)
+
+	notification := channel.notifications[0]
+	assert.Equal(t, "firing", notification.State)
+	assert.Contains(t, notification.Message, "dropped 11.00%")
+}
+
+func TestAlertBelowRentExemptEvaluatesCurrentBalance(t *testing.T) {
+	verifyNoLeaks(t)
+	channel := newRecordingChannel()
+	evaluator, _ := newTestAlertEvaluator(t, newMemoryAlertStore(), 0.0005)
+
+	rule, err := evaluator.Register("key-1", models.AlertRuleRequest{
+		Wallet:    testWallet,
+		Condition: models.AlertCondition{Type: "below_rent_exempt"},
+		Channels:  []models.AlertChannelConfig{{Type: "recording"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "firing", rule.State)
+
+	require.Eventually(t, func() bool { return len(channel.States()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Contains(t, channel.notifications[0].Message, "rent-exempt minimum 0.00089088 SOL")
+
+	// The minimum is the node's for the rule's data size
+	rule, err = evaluator.Register("key-1", models.AlertRuleRequest{
+		Wallet:    testWallet,
+		Condition: models.AlertCondition{Type: "below_rent_exempt", DataSize: 165},
+		Channels:  []models.AlertChannelConfig{{Type: "recording"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "firing", rule.State)
+	require.Eventually(t, func() bool { return len(channel.States()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Contains(t, channel.notifications[1].Message, "rent-exempt minimum 0.00203928 SOL for 165 bytes")
+}
+
+func TestAlertSlackChannelPostsText(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) { c.WebhookAllowPrivateURLs = true })
+
+	received := make(chan map[string]string, 1)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+	}))
+	defer sink.Close()
+
+	channel, err := data.NewAlertChannel(models.AlertChannelConfig{Type: "slack", URL: sink.URL})
+	require.NoError(t, err)
+	require.NoError(t, channel.Send(models.AlertNotification{RuleName: "treasury low", State: "firing", Message: "balance 90 SOL"}))
+	assert.Equal(t, "[FIRING] treasury low: balance 90 SOL", (<-received)["text"])
+
+	_, err = data.NewAlertChannel(models.AlertChannelConfig{Type: "slack"})
+	assert.Error(t, err)
+	_, err = data.NewAlertChannel(models.AlertChannelConfig{Type: "pager"})
+	assert.Error(t, err)
+}
+
+func TestAlertHandlers(t *testing.T) {
+	verifyNoLeaks(t)
+
+	mockAuth := &MockAPIKeyValidator{}
+	mockAuth.On("ValidateAPIKey", "valid-key").Return(&models.APIKey{ID: "valid-key"}, nil)
+
+	evaluator, _ := newTestAlertEvaluator(t, newMemoryAlertStore(), 5)
+	alertHandler := handlers.NewAlertHandler(evaluator)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middleware.APIKeyAuth(mockAuth))
+	api.HandleFunc("/alerts", alertHandler.CreateAlertHandler).Methods("POST")
+	api.HandleFunc("/alerts", alertHandler.ListAlertsHandler).Methods("GET")
+	api.HandleFunc("/alerts/{id}", alertHandler.DeleteAlertHandler).Methods("DELETE")
+
+	do := func(method, path string, body interface{}) (*httptest.ResponseRecorder, models.Response) {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("X-Token", "valid-key")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var response models.Response
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	invalid := []models.AlertRuleRequest{
+		{Wallet: testWallet, Condition: models.AlertCondition{Type: "sideways"}},
+		{Wallet: testWallet, Condition: models.AlertCondition{Type: "drop_percent", Value: 10}},
+		{Wallet: "not-a-wallet", Condition: models.AlertCondition{Type: "below", Value: 1}},
+		{Wallet: testWallet, Condition: models.AlertCondition{Type: "below", Value: 1}, Channels: []models.AlertChannelConfig{{Type: "webhook"}}},
+	}
+	for _, request := range invalid {
+		rr, _ := do("POST", "/api/alerts", request)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, request)
+	}
+
+	rr, response := do("POST", "/api/alerts", models.AlertRuleRequest{Wallet: testWallet, Condition: models.AlertCondition{Type: "below", Value: 1}})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	created := response.Data.(map[string]interface{})
+	assert.Equal(t, "ok", created["state"])
+	id := created["id"].(string)
+
+	rr, response = do("GET", "/api/alerts", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, response.Data, 1)
+
+	rr, _ = do("DELETE", "/api/alerts/"+id, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr, _ = do("DELETE", "/api/alerts/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAlertRulesRejectUnsafeChannels(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) {
+		c.SMTPAddr = "127.0.0.1:25"
+		c.AlertMaxRecipientsPerKey = 2
+		c.WebhookAllowPrivateURLs = false
+	})
+	evaluator, _ := newTestAlertEvaluator(t, newMemoryAlertStore(), 5)
+	register := func(apiKey, name string, channels ...models.AlertChannelConfig) error {
+		_, err := evaluator.Register(apiKey, models.AlertRuleRequest{
+			Name:      name,
+			Wallet:    testWallet,
+			Condition: models.AlertCondition{Type: "below", Value: 1},
+			Channels:  channels,
+		})
+		return err
+	}
+	smtpTo := func(to ...string) models.AlertChannelConfig {
+		return models.AlertChannelConfig{Type: "smtp", To: to}
+	}
+
+	assert.ErrorIs(t, register("key-1", "low\r\nBcc: attacker@example.com"), data.ErrInvalidAlertRule)
+	assert.ErrorIs(t, register("key-1", "low", smtpTo("ops@example.com\r\nBcc: attacker@example.com")), data.ErrInvalidAlertRule)
+	assert.ErrorIs(t, register("key-1", "low", smtpTo("not an address")), data.ErrInvalidAlertRule)
+	assert.ErrorIs(t, register("key-1", "low", smtpTo("a@example.com, b@example.com")), data.ErrInvalidAlertRule, "one address per entry")
+	for _, url := range []string{"http://127.0.0.1/hook", "http://169.254.169.254/latest/meta-data", "http://10.0.0.1/hook"} {
+		assert.ErrorIs(t, register("key-1", "low", models.AlertChannelConfig{Type: "webhook", URL: url}), data.ErrInvalidAlertRule, url)
+		assert.ErrorIs(t, register("key-1", "low", models.AlertChannelConfig{Type: "slack", URL: url}), data.ErrInvalidAlertRule, url)
+	}
+
+	// Recipients are counted once per API key, whatever their case
+	require.NoError(t, register("key-1", "low", smtpTo("Ops <ops@example.com>", "oncall@example.com")))
+	require.NoError(t, register("key-1", "lower", smtpTo("OPS@example.com")))
+	assert.ErrorIs(t, register("key-1", "lowest", smtpTo("cfo@example.com")), data.ErrAlertLimit)
+	assert.NoError(t, register("key-2", "low", smtpTo("cfo@example.com")))
+}
+
+func TestAlertWebhookChannelSignsPayload(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) { c.WebhookAllowPrivateURLs = true })
+
+	type received struct {
+		signature string
+		body      []byte
+	}
+	requests := make(chan received, 1)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{signature: r.Header.Get("X-Nova-Signature"), body: body}
+	}))
+	defer sink.Close()
+
+	evaluator, _ := newTestAlertEvaluator(t, newMemoryAlertStore(), 0.5)
+	rule, err := evaluator.Register("key-1", models.AlertRuleRequest{
+		Wallet:    testWallet,
+		Condition: models.AlertCondition{Type: "below", Value: 1},
+		Channels:  []models.AlertChannelConfig{{Type: "webhook", URL: sink.URL, Secret: "chosen-by-client"}},
+	})
+	require.NoError(t, err)
+	secret := rule.Channels[0].Secret
+	require.NotEmpty(t, secret)
+	assert.NotEqual(t, "chosen-by-client", secret)
+
+	select {
+	case request := <-requests:
+		assert.Equal(t, data.SignWebhookPayload(secret, request.body), request.signature)
+	case <-time.After(2 * time.Second):
+		t.Fatal("alert webhook was not called")
+	}
+
+	rules, err := evaluator.List("key-1")
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Empty(t, rules[0].Channels[0].Secret)
+}
+
+// fakeSMTPServer records the recipients and message of every mail it accepts
+type fakeSMTPServer struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mutex      sync.Mutex
+	recipients []string
+	messages   []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &fakeSMTPServer{listener: listener}
+	server.wg.Add(1)
+	go func() {
+		defer server.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		server.wg.Wait()
+	})
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mutex.Lock()
+			s.recipients = append(s.recipients, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			s.mutex.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var message strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message.WriteString(line)
+			}
+			s.mutex.Lock()
+			s.messages = append(s.messages, message.String())
+			s.mutex.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestAlertEmailCannotInjectHeaders(t *testing.T) {
+	verifyNoLeaks(t)
+	server := newFakeSMTPServer(t)
+	withConfig(t, func(c *config.Config) {
+		c.SMTPAddr = server.listener.Addr().String()
+		c.SMTPUsername = ""
+		c.SMTPFrom = "Nova <nova@example.com>"
+	})
+
+	channel, err := data.NewAlertChannel(models.AlertChannelConfig{Type: "smtp", To: []string{"Ops Team <ops@example.com>"}})
+	require.NoError(t, err)
+	require.NoError(t, channel.Send(models.AlertNotification{
+		// Rules stored before names were validated could still carry line breaks
+		RuleName: "low\r\nBcc: attacker@example.com",
+		State:    "firing",
+		Message:  "balance 0.5 SOL, threshold below 1 SOL",
+		Time:     time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}))
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	assert.Equal(t, []string{"ops@example.com"}, server.recipients)
+	require.Len(t, server.messages, 1)
+
+	headers, body, found := strings.Cut(server.messages[0], "\r\n\r\n")
+	require.True(t, found)
+	for _, line := range strings.Split(headers, "\r\n") {
+		assert.False(t, strings.HasPrefix(strings.ToLower(line), "bcc:"), line)
+	}
+	assert.Contains(t, headers, "From: \"Nova\" <nova@example.com>\r\n")
+	assert.Contains(t, headers, "To: \"Ops Team\" <ops@example.com>\r\n")
+	assert.Contains(t, headers, "Subject: =?utf-8?q?")
+	assert.Contains(t, body, "balance 0.5 SOL")
+}
+
+func TestAlertOnlyLeaseHolderNotifies(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) { c.AlertLeaseTTL = 1 })
+	channel := newRecordingChannel()
+
+	// Two replicas sharing one store, each with its own feed
+	store := newMemoryAlertStore()
+	first, _ := newTestAlertEvaluator(t, store, 50)
+	second, secondFeed := newTestAlertEvaluator(t, store, 50)
+
+	// Registered on the replica that does not notify, and picked up by the one that does
+	rule, err := second.Register("key-1", models.AlertRuleRequest{
+		Wallet:    testWallet,
+		Condition: models.AlertCondition{Type: "below", Value: 100},
+		Channels:  []models.AlertChannelConfig{{Type: "recording"}},
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(channel.States()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []string{"firing"}, channel.States())
+	assert.Equal(t, "firing", store.State(rule.ID))
+
+	// Once the leader is gone the other replica takes over from the same state
+	first.Close()
+	assert.Eventually(t, func() bool {
+		secondFeed.Publish(testWallet, 120+float64(time.Now().UnixNano()%1000))
+		return len(channel.States()) == 2
+	}, 3*time.Second, 100*time.Millisecond)
+	assert.Equal(t, []string{"firing", "resolved"}, channel.States())
+	assert.Equal(t, "resolved", store.State(rule.ID))
+}
+
+func TestAlertEvaluatesWhileAnotherRuleSubscribes(t *testing.T) {
+	verifyNoLeaks(t)
+	channel := newRecordingChannel()
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	balances := newFakeSubscriptionRPC()
+	balances.Set(testWallet, 50, 1)
+	balances.Set(testOtherWallet, 50, 1)
+	subscriptions := data.NewSubscriptionManager(fake.URL(), balances, data.NoopBalanceCache{})
+	defer subscriptions.Close()
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", mock.Anything).Return(50.0, nil)
+	service := data.NewBalanceService(mockRPC, data.NoopBalanceCache{})
+	feed := data.NewBalanceFeed(service, subscriptions)
+	defer feed.Close()
+	evaluator, err := data.NewAlertEvaluator(newMemoryAlertStore(), feed, service, data.NewRentService(&MockRentRPC{}, data.NoopBalanceCache{}))
+	require.NoError(t, err)
+	defer evaluator.Close()
+
+	register := func(wallet string) error {
+		_, err := evaluator.Register("key-1", models.AlertRuleRequest{
+			Wallet:    wallet,
+			Condition: models.AlertCondition{Type: "below", Value: 10},
+			Channels:  []models.AlertChannelConfig{{Type: "recording"}},
+		})
+		return err
+	}
+	require.NoError(t, register(testWallet))
+	require.Eventually(t, func() bool { return subscriptions.IsActive(testWallet) }, 2*time.Second, 10*time.Millisecond)
+
+	// The second rule's upstream subscription hangs in the handshake
+	release := fake.Hold(testOtherWallet)
+	registered := make(chan error, 1)
+	go func() { registered <- register(testOtherWallet) }()
+	require.Eventually(t, func() bool { return feed.WatchedWallets() == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	feed.Publish(testWallet, 5)
+	assert.Eventually(t, func() bool { return len(channel.States()) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	release()
+	assert.NoError(t, <-registered)
+	assert.Eventually(t, func() bool { return subscriptions.IsActive(testOtherWallet) }, 2*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,253 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTransaction struct {
+	signature string
+	change    rpc.BalanceChange
+}
+
+// fakePastBalanceRPC serves a wallet history, newest transaction first, the way getSignaturesForAddress pages it
+type fakePastBalanceRPC struct {
+	current       float64
+	finalizedSlot uint64
+	history       []fakeTransaction
+
+	mutex            sync.Mutex
+	signatureCalls   int
+	transactionCalls int
+}
+
+// newFakePastBalanceRPC builds a history of n transactions: transaction i lands
+// at slot 10*(i+1) and block time 1_700_000_000+i and raises the balance from i to i+1 SOL.
+func newFakePastBalanceRPC(n int) *fakePastBalanceRPC {
+	fake := &fakePastBalanceRPC{current: float64(n), finalizedSlot: uint64(10*n + 100)}
+	for i := n - 1; i >= 0; i-- {
+		fake.history = append(fake.history, fakeTransaction{
+			signature: fmt.Sprintf("sig-%d", i),
+			change: rpc.BalanceChange{
+				Slot:        uint64(10 * (i + 1)),
+				BlockTime:   int64(1_700_000_000 + i),
+				PreBalance:  float64(i),
+				PostBalance: float64(i + 1),
+			},
+		})
+	}
+	return fake
+}
+
+func (f *fakePastBalanceRPC) GetBalanceAndSlot(walletAddress string) (float64, uint64, error) {
+	if walletAddress != testWallet {
+		return 0, 0, rpc.ErrInvalidAddress
+	}
+	return f.current, f.finalizedSlot, nil
+}
+
+func (f *fakePastBalanceRPC) GetSignatures(walletAddress, before string, limit int) ([]rpc.SignatureInfo, error) {
+	f.mutex.Lock()
+	f.signatureCalls++
+	f.mutex.Unlock()
+
+	start := 0
+	if before != "" {
+		for i, tx := range f.history {
+			if tx.signature == before {
+				start = i + 1
+			}
+		}
+	}
+
+	signatures := []rpc.SignatureInfo{}
+	for _, tx := range f.history[start:] {
+		if len(signatures) == limit {
+			break
+		}
+		signatures = append(signatures, rpc.SignatureInfo{Signature: tx.signature, Slot: tx.change.Slot, BlockTime: tx.change.BlockTime})
+	}
+	return signatures, nil
+}
+
+func (f *fakePastBalanceRPC) GetBalanceChange(signature, walletAddress string) (*rpc.BalanceChange, error) {
+	f.mutex.Lock()
+	f.transactionCalls++
+	f.mutex.Unlock()
+
+	for _, tx := range f.history {
+		if tx.signature == signature {
+			change := tx.change
+			return &change, nil
+		}
+	}
+	return nil, rpc.ErrTransactionNotFound
+}
+
+func newTestPastBalanceService(t *testing.T, fake *fakePastBalanceRPC, maxSignatures int) *data.PastBalanceService {
+	withConfig(t, func(c *config.Config) {
+		c.BalanceAtMaxSignatures = maxSignatures
+		c.BalanceAtCacheTTLDays = 7
+	})
+
+	cache := data.NewMemoryBalanceCache(100)
+	t.Cleanup(func() { cache.Close() })
+	return data.NewPastBalanceService(fake, cache)
+}
+
+func TestBalanceAtSlot(t *testing.T) {
+	tests := []struct {
+		name      string
+		slot      uint64
+		balance   float64
+		signature string
+		position  string
+	}{
+		{"between transactions", 25, 2, "sig-1", "post"},
+		{"at a transaction", 30, 3, "sig-2", "post"},
+		{"after the last transaction", 60, 5, "sig-4", "post"},
+		{"before the first transaction", 5, 0, "sig-0", "pre"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestPastBalanceService(t, newFakePastBalanceRPC(5), 0)
+
+			result, err := service.BalanceAtSlot(testWallet, tt.slot)
+			require.NoError(t, err)
+			assert.Equal(t, tt.balance, result.Balance)
+			assert.Equal(t, tt.slot, result.Slot)
+			require.NotNil(t, result.Checkpoint)
+			assert.Equal(t, tt.signature, result.Checkpoint.Signature)
+			assert.Equal(t, tt.position, result.Checkpoint.Position)
+		})
+	}
+}
+
+func TestBalanceAtSlotWalksPagesAndCaches(t *testing.T) {
+	fake := newFakePastBalanceRPC(1500)
+	service := newTestPastBalanceService(t, fake, 0)
+
+	result, err := service.BalanceAtSlot(testWallet, 55)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, result.Balance)
+	assert.Equal(t, 2, fake.signatureCalls)
+	assert.Equal(t, 1, fake.transactionCalls)
+
+	// The slot is finalized, so the answer is served from the cache from now on
+	cached, err := service.BalanceAtSlot(testWallet, 55)
+	require.NoError(t, err)
+	assert.Equal(t, result, cached)
+	assert.Equal(t, 2, fake.signatureCalls)
+	assert.Equal(t, 1, fake.transactionCalls)
+}
+
+// ttlRecordingCache remembers the TTL every key was last written with
+type ttlRecordingCache struct {
+	data.BalanceCache
+	mutex sync.Mutex
+	ttls  map[string]time.Duration
+}
+
+func (c *ttlRecordingCache) Set(key, value string, ttl time.Duration) error {
+	c.mutex.Lock()
+	c.ttls[key] = ttl
+	c.mutex.Unlock()
+	return c.BalanceCache.Set(key, value, ttl)
+}
+
+func TestBalanceAtCacheEntriesExpire(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.BalanceAtCacheTTLDays = 2 })
+	memory := data.NewMemoryBalanceCache(100)
+	defer memory.Close()
+	cache := &ttlRecordingCache{BalanceCache: memory, ttls: make(map[string]time.Duration)}
+
+	_, err := data.NewPastBalanceService(newFakePastBalanceRPC(5), cache).BalanceAtSlot(testWallet, 25)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"balance:at:" + testWallet + ":slot:25": 48 * time.Hour,
+		"balance:tx:" + testWallet + ":sig-1":   48 * time.Hour,
+	}, cache.ttls)
+
+	// Without a TTL nothing is cached
+	withConfig(t, func(c *config.Config) { c.BalanceAtCacheTTLDays = 0 })
+	cache.ttls = make(map[string]time.Duration)
+	_, err = data.NewPastBalanceService(newFakePastBalanceRPC(5), cache).BalanceAtSlot(testWallet, 35)
+	require.NoError(t, err)
+	assert.Empty(t, cache.ttls)
+}
+
+func TestBalanceAtSlotRejectsUnfinalizedAndLongHistories(t *testing.T) {
+	service := newTestPastBalanceService(t, newFakePastBalanceRPC(5), 0)
+	_, err := service.BalanceAtSlot(testWallet, 10_000)
+	assert.ErrorIs(t, err, data.ErrNotFinalized)
+
+	service = newTestPastBalanceService(t, newFakePastBalanceRPC(5), 3)
+	_, err = service.BalanceAtSlot(testWallet, 15)
+	assert.ErrorIs(t, err, data.ErrHistoryTooLong)
+}
+
+func TestBalanceAtTime(t *testing.T) {
+	service := newTestPastBalanceService(t, newFakePastBalanceRPC(5), 0)
+
+	at := time.Unix(1_700_000_002, 500_000_000)
+	result, err := service.BalanceAtTime(testWallet, at)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, result.Balance)
+	assert.Equal(t, "sig-2", result.Checkpoint.Signature)
+	require.NotNil(t, result.Timestamp)
+
+	_, err = service.BalanceAtTime(testWallet, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, data.ErrNotFinalized)
+}
+
+func TestBalanceAtHandler(t *testing.T) {
+	service := newTestPastBalanceService(t, newFakePastBalanceRPC(5), 0)
+	pastBalanceHandler := handlers.NewPastBalanceHandler(service)
+
+	get := func(query string) (*httptest.ResponseRecorder, models.PastBalance) {
+		req := httptest.NewRequest("GET", "/api/balance-at?"+query, nil)
+		rr := httptest.NewRecorder()
+		pastBalanceHandler.BalanceAtHandler(rr, req)
+
+		var response struct {
+			Data models.PastBalance `json:"data"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response.Data
+	}
+
+	rr, result := get("wallet=" + testWallet + "&slot=45")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 4.0, result.Balance)
+
+	rr, result = get("wallet=" + testWallet + "&timestamp=1700000000")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1.0, result.Balance)
+
+	for query, status := range map[string]int{
+		"wallet=" + testWallet:                         http.StatusBadRequest,
+		"wallet=" + testWallet + "&slot=1&timestamp=1": http.StatusBadRequest,
+		"wallet=" + testWallet + "&slot=abc":           http.StatusBadRequest,
+		"wallet=" + testWallet + "&slot=99999999":      http.StatusBadRequest,
+		"wallet=" + testOtherWallet + "&slot=10":       http.StatusBadRequest,
+		"wallet=" + testWallet + "&timestamp=tomorrow": http.StatusBadRequest,
+	} {
+		rr, _ := get(query)
+		assert.Equal(t, status, rr.Code, query)
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBalanceCache(t *testing.T) {
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	_, found, err := cache.GetBalance("wallet1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, cache.SetBalance("wallet1", 2.5))
+	balance, found, err := cache.GetBalance("wallet1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 2.5, balance)
+
+	assert.NoError(t, cache.Set("note", "hello", time.Minute))
+	value, err := cache.Get("note")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", value)
+}
+
+func TestNoopBalanceCache(t *testing.T) {
+	cache := data.NoopBalanceCache{}
+
+	assert.NoError(t, cache.SetBalance("wallet1", 2.5))
+	_, found, err := cache.GetBalance("wallet1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestNewBalanceCacheFromConfig(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.CacheBackend = "memory" })
+	cache, err := data.NewBalanceCache()
+	assert.NoError(t, err)
+	assert.IsType(t, &data.MemoryBalanceCache{}, cache)
+	cache.Close()
+
+	config.AppConfig.CacheBackend = "none"
+	cache, err = data.NewBalanceCache()
+	assert.NoError(t, err)
+	assert.IsType(t, data.NoopBalanceCache{}, cache)
+
+	config.AppConfig.CacheBackend = "memcached"
+	_, err = data.NewBalanceCache()
+	assert.Error(t, err)
+}
+
+func TestBalanceServiceServesFromCache(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", "wallet1").Return(1.25, nil).Once()
+
+	service := data.NewBalanceService(mockRPC, data.NewMemoryBalanceCache(100))
+	defer service.Close()
+
+	for i := 0; i < 3; i++ {
+		balance, err := service.GetBalance("wallet1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1.25, balance)
+	}
+
+	mockRPC.AssertExpectations(t)
+}
+
+func TestBalanceServiceWithoutCache(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", "wallet1").Return(0.0, fmt.Errorf("rpc unavailable")).Twice()
+
+	service := data.NewBalanceService(mockRPC, data.NoopBalanceCache{})
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetBalance("wallet1")
+		assert.Error(t, err)
+	}
+
+	mockRPC.AssertExpectations(t)
+}
@@ -9,12 +9,15 @@ import (
 	"nova-api/models"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
 )
 
 func TestMain(m *testing.M) {
 	config.Load()
 	config.AppConfig.RateLimitRequestsPerMin = 5
-	m.Run()
+
+	// Goroutines started during package init, such as the shared rate limiter, are expected to live for the whole run
+	goleak.VerifyTestMain(m, goleak.IgnoreCurrent())
 }
 
 func TestSingleWalletBalance(t *testing.T) {
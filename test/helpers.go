@@ -29,6 +29,15 @@ func (m *MockAPIKeyValidator) ValidateAPIKey(key string) (*models.APIKey, error)
 	return args.Get(0).(*models.APIKey), args.Error(1)
 }
 
+type MockBalanceRPC struct {
+	mock.Mock
+}
+
+func (m *MockBalanceRPC) GetBalance(walletAddress string) (float64, error) {
+	args := m.Called(walletAddress)
+	return args.Get(0).(float64), args.Error(1)
+}
+
 type MockBalanceHandler struct {
 	mock.Mock
 }
@@ -84,6 +93,14 @@ func (m *MockBalanceHandler) GetBalanceHandler(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(response)
 }
 
+// withConfig applies change to the global config for the rest of the test.
+func withConfig(t *testing.T, change func(*config.Config)) {
+	t.Helper()
+	original := *config.AppConfig
+	t.Cleanup(func() { *config.AppConfig = original })
+	change(config.AppConfig)
+}
+
 func CreateTestServer(validator *MockAPIKeyValidator) *httptest.Server {
 	balanceHandler := &MockBalanceHandler{}
 
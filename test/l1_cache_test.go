@@ -0,0 +1,279 @@
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis speaks enough RESP2 for CacheService: GET, SET, PUBLISH and
+// SUBSCRIBE. It counts the commands it receives so tests can tell which reads
+// reached Redis.
+type fakeRedis struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	values      map[string]string
+	commands    map[string]int
+	conns       map[net.Conn]bool
+	subscribers map[net.Conn]bool
+	wg          sync.WaitGroup
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &fakeRedis{
+		listener:    listener,
+		values:      make(map[string]string),
+		commands:    make(map[string]int),
+		conns:       make(map[net.Conn]bool),
+		subscribers: make(map[net.Conn]bool),
+	}
+	server.wg.Add(1)
+	go server.accept()
+	t.Cleanup(server.close)
+	return server
+}
+
+func (s *fakeRedis) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeRedis) count(command string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.commands[command]
+}
+
+func (s *fakeRedis) subscriberCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.subscribers)
+}
+
+func (s *fakeRedis) close() {
+	s.listener.Close()
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+}
+
+func (s *fakeRedis) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		delete(s.subscribers, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		s.handle(conn, args)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn, args []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	command := strings.ToLower(args[0])
+	s.commands[command]++
+
+	var reply string
+	switch {
+	case command == "get" && len(args) == 2:
+		value, exists := s.values[args[1]]
+		if !exists {
+			reply = "$-1\r\n"
+		} else {
+			reply = bulkString(value)
+		}
+	case command == "set" && len(args) >= 3:
+		s.values[args[1]] = args[2]
+		reply = "+OK\r\n"
+	case command == "publish" && len(args) == 3:
+		message := "*3\r\n" + bulkString("message") + bulkString(args[1]) + bulkString(args[2])
+		for subscriber := range s.subscribers {
+			subscriber.Write([]byte(message))
+		}
+		reply = fmt.Sprintf(":%d\r\n", len(s.subscribers))
+	case command == "subscribe" && len(args) == 2:
+		s.subscribers[conn] = true
+		reply = "*3\r\n" + bulkString("subscribe") + bulkString(args[1]) + ":1\r\n"
+	case command == "ping" && s.subscribers[conn]:
+		reply = "*2\r\n" + bulkString("pong") + bulkString("")
+	case command == "ping":
+		reply = "+PONG\r\n"
+	default:
+		// HELLO and CLIENT SETINFO are optional; go-redis falls back to RESP2
+		reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+	conn.Write([]byte(reply))
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected RESP line %q", line)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("unexpected RESP array %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected RESP bulk string %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulkString(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func newL1CacheService(t *testing.T, server *fakeRedis) *data.CacheService {
+	cache := data.NewCacheService(server.addr(), "", 0)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestL1CacheHitSkipsRedis(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) {
+		c.BalanceL1CacheTTL = 60
+		c.BalanceL1CacheSize = 100
+	})
+	server := newFakeRedis(t)
+	cache := newL1CacheService(t, server)
+
+	require.NoError(t, cache.SetBalance(testWallet, 1.5))
+	for i := 0; i < 3; i++ {
+		balance, found, err := cache.GetBalance(testWallet)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 1.5, balance)
+	}
+	assert.Equal(t, 0, server.count("get"))
+
+	// Wallets missing from L1 are read from Redis and kept in L1 afterwards
+	server.mutex.Lock()
+	server.values["balance:"+testOtherWallet] = "2.5"
+	server.mutex.Unlock()
+	for i := 0; i < 3; i++ {
+		balance, found, err := cache.GetBalance(testOtherWallet)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, 2.5, balance)
+	}
+	assert.Equal(t, 1, server.count("get"))
+}
+
+func TestL1CacheEntriesExpireAfterTTL(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) {
+		c.BalanceL1CacheTTL = 1
+		c.BalanceL1CacheSize = 100
+	})
+	server := newFakeRedis(t)
+	cache := newL1CacheService(t, server)
+
+	require.NoError(t, cache.SetBalance(testWallet, 1.5))
+	_, _, err := cache.GetBalance(testWallet)
+	require.NoError(t, err)
+	assert.Equal(t, 0, server.count("get"))
+
+	// A value written to Redis behind the cache's back shows up once L1 expires
+	server.mutex.Lock()
+	server.values["balance:"+testWallet] = "3"
+	server.mutex.Unlock()
+	time.Sleep(1100 * time.Millisecond)
+
+	balance, found, err := cache.GetBalance(testWallet)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 3.0, balance)
+	assert.Equal(t, 1, server.count("get"))
+}
+
+func TestL1CacheInvalidatedByOtherInstances(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) {
+		c.BalanceL1CacheTTL = 60
+		c.BalanceL1CacheSize = 100
+	})
+	server := newFakeRedis(t)
+	writer := newL1CacheService(t, server)
+	reader := newL1CacheService(t, server)
+	require.Eventually(t, func() bool { return server.subscriberCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, writer.SetBalance(testWallet, 1.0))
+	balance, _, err := reader.GetBalance(testWallet)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, balance)
+
+	// Without the invalidation the reader would keep serving 1.0 from L1 for a minute
+	require.NoError(t, writer.SetBalance(testWallet, 2.0))
+	assert.Eventually(t, func() bool {
+		balance, _, err := reader.GetBalance(testWallet)
+		return err == nil && balance == 2.0
+	}, time.Second, 10*time.Millisecond)
+
+	// The writer's own L1 was updated directly and never read Redis
+	balance, _, err = writer.GetBalance(testWallet)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, balance)
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"nova-api/data"
+	"nova-api/middleware"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMemoryCacheBoundedSize(t *testing.T) {
+	cache := data.NewMemoryCacheWithLimit(64)
+	defer cache.Close()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("ip-%d", i), i, time.Minute)
+	}
+
+	assert.LessOrEqual(t, cache.Len(), 64)
+	assert.GreaterOrEqual(t, cache.Stats().Evictions, uint64(1000-64))
+
+	// The most recent write always survives eviction
+	value, found := cache.Get("ip-999")
+	assert.True(t, found)
+	assert.Equal(t, 999, value)
+}
+
+func TestMemoryCacheLimitIsGlobal(t *testing.T) {
+	// 10 is not a multiple of the shard count, and 10 keys are bound to share some shards
+	cache := data.NewMemoryCacheWithLimit(10)
+	defer cache.Close()
+
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), i, time.Minute)
+	}
+	assert.Equal(t, 10, cache.Len())
+	assert.Equal(t, uint64(0), cache.Stats().Evictions)
+
+	// The least recently used entry is evicted, whichever shard it is in
+	_, found := cache.Get("key-0")
+	assert.True(t, found)
+	cache.Set("key-10", 10, time.Minute)
+	assert.Equal(t, 10, cache.Len())
+	_, found = cache.Get("key-1")
+	assert.False(t, found)
+	_, found = cache.Get("key-0")
+	assert.True(t, found)
+
+	for i := 11; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), i, time.Minute)
+	}
+	assert.Equal(t, 10, cache.Len())
+	cache.Delete("key-999")
+	evictions := cache.Stats().Evictions
+	cache.Set("key-1000", 1000, time.Minute)
+	assert.Equal(t, 10, cache.Len())
+	assert.Equal(t, evictions, cache.Stats().Evictions, "deleted entries free their slot")
+}
+
+func TestMemoryCacheUnboundedByDefault(t *testing.T) {
+	cache := data.NewMemoryCache()
+	defer cache.Close()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), i, time.Minute)
+	}
+
+	assert.Equal(t, 1000, cache.Len())
+	assert.Zero(t, cache.Stats().Evictions)
+}
+
+func TestMemoryCacheStats(t *testing.T) {
+	cache := data.NewMemoryCache()
+	defer cache.Close()
+
+	cache.Set("present", "value", time.Minute)
+	cache.Set("expired", "value", -time.Second)
+
+	_, found := cache.Get("present")
+	assert.True(t, found)
+	_, found = cache.Get("missing")
+	assert.False(t, found)
+	_, found = cache.Get("expired")
+	assert.False(t, found)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestMemoryCacheRangeAndClear(t *testing.T) {
+	cache := data.NewMemoryCache()
+	defer cache.Close()
+
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, time.Minute)
+	cache.Set("stale", 3, -time.Second)
+
+	seen := map[string]interface{}{}
+	cache.Range(func(key string, value interface{}) bool {
+		seen[key] = value
+		return true
+	})
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, seen)
+
+	visits := 0
+	cache.Range(func(key string, value interface{}) bool {
+		visits++
+		return false
+	})
+	assert.Equal(t, 1, visits)
+
+	cache.Clear()
+	assert.Zero(t, cache.Len())
+	_, found := cache.Get("a")
+	assert.False(t, found)
+}
+
+func TestMemoryCacheCloseStopsCleanup(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cache := data.NewMemoryCache()
+	cache.Set("key", "value", time.Minute)
+	cache.Close()
+
+	// Closing twice is harmless and the cache remains readable
+	cache.Close()
+	value, found := cache.Get("key")
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+}
+
+func TestMemoryCacheContextStopsCleanup(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cache := data.NewMemoryCacheWithOptions(data.MemoryCacheOptions{Context: ctx})
+	cancel()
+	cache.Close()
+}
+
+func TestMemoryCacheCleanupInterval(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cache := data.NewMemoryCacheWithOptions(data.MemoryCacheOptions{
+		CleanupInterval: 10 * time.Millisecond,
+	})
+	defer cache.Close()
+
+	cache.Set("short-lived", "value", time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return cache.Len() == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestResetRateLimiterDoesNotLeak(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for i := 0; i < 5; i++ {
+		middleware.ResetRateLimiterForTesting()
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotFoundWalletsAreNegativeCached(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", "ghost-wallet").Return(0.0, rpc.ErrAccountNotFound).Once()
+
+	service := data.NewBalanceService(mockRPC, data.NewMemoryBalanceCache(100))
+	defer service.Close()
+
+	for i := 0; i < 3; i++ {
+		balance, err := service.GetBalance("ghost-wallet")
+		assert.NoError(t, err)
+		assert.Zero(t, balance)
+	}
+
+	mockRPC.AssertExpectations(t)
+}
+
+func TestRefreshOverwritesBalanceOfClosedWallet(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", testWallet).Return(0.0, rpc.ErrAccountNotFound).Once()
+
+	cache := data.NewMemoryBalanceCache(100)
+	service := data.NewBalanceService(mockRPC, cache)
+	defer service.Close()
+	assert.NoError(t, cache.SetBalance(testWallet, 5))
+
+	balance, err := service.RefreshBalance(testWallet)
+	assert.NoError(t, err)
+	assert.Zero(t, balance)
+
+	// The old balance is gone without another RPC call
+	balance, err = service.GetBalance(testWallet)
+	assert.NoError(t, err)
+	assert.Zero(t, balance)
+	mockRPC.AssertExpectations(t)
+}
+
+// failingBalanceCache cannot store balances, like a cache whose Redis is down
+type failingBalanceCache struct {
+	data.NoopBalanceCache
+}
+
+func (failingBalanceCache) SetBalance(walletAddress string, balance float64) error {
+	return errors.New("connection refused")
+}
+
+func TestRefreshReturnsBalanceWhenCacheFails(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", testWallet).Return(2.5, nil).Once()
+
+	balance, err := data.NewBalanceService(mockRPC, failingBalanceCache{}).RefreshBalance(testWallet)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, balance)
+}
+
+func TestUpstreamErrorsAreNegativeCached(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", "flaky-wallet").Return(0.0, fmt.Errorf("failed to get balance: 503")).Once()
+
+	service := data.NewBalanceService(mockRPC, data.NewMemoryBalanceCache(100))
+	defer service.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := service.GetBalance("flaky-wallet")
+		assert.EqualError(t, err, "failed to get balance: 503")
+	}
+
+	mockRPC.AssertExpectations(t)
+}
+
+func TestInvalidAddressesAreNotNegativeCached(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", "not-base58").Return(0.0, fmt.Errorf("%w: bad input", rpc.ErrInvalidAddress)).Twice()
+
+	service := data.NewBalanceService(mockRPC, data.NewMemoryBalanceCache(100))
+	defer service.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetBalance("not-base58")
+		assert.ErrorIs(t, err, rpc.ErrInvalidAddress)
+	}
+
+	mockRPC.AssertExpectations(t)
+}
+
+func TestNegativeCachingCanBeDisabled(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.BalanceNotFoundCacheTTL = 0
+		c.BalanceErrorCacheTTL = 0
+	})
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", "ghost-wallet").Return(0.0, rpc.ErrAccountNotFound).Twice()
+	mockRPC.On("GetBalance", "flaky-wallet").Return(0.0, fmt.Errorf("timeout")).Twice()
+
+	service := data.NewBalanceService(mockRPC, data.NewMemoryBalanceCache(100))
+	defer service.Close()
+
+	for i := 0; i < 2; i++ {
+		service.GetBalance("ghost-wallet")
+		service.GetBalance("flaky-wallet")
+	}
+
+	mockRPC.AssertExpectations(t)
+}
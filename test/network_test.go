@@ -0,0 +1,230 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockNetworkRPC struct {
+	mock.Mock
+}
+
+func (m *MockNetworkRPC) GetEpochInfo() (*rpc.EpochInfo, error) {
+	args := m.Called()
+	info, _ := args.Get(0).(*rpc.EpochInfo)
+	return info, args.Error(1)
+}
+
+func (m *MockNetworkRPC) GetPerformanceSamples(limit int) ([]rpc.PerformanceSample, error) {
+	args := m.Called(limit)
+	samples, _ := args.Get(0).([]rpc.PerformanceSample)
+	return samples, args.Error(1)
+}
+
+func (m *MockNetworkRPC) GetPrioritizationFees(accounts []string) ([]uint64, error) {
+	args := m.Called(accounts)
+	fees, _ := args.Get(0).([]uint64)
+	return fees, args.Error(1)
+}
+
+func TestPriorityFeePercentiles(t *testing.T) {
+	fees := make([]uint64, 0, 100)
+	for fee := uint64(100); fee >= 1; fee-- {
+		fees = append(fees, fee)
+	}
+	percentiles := data.PriorityFeePercentiles(fees)
+	assert.Equal(t, models.PriorityFees{Slots: 100, Min: 1, P25: 25, P50: 50, P75: 75, P90: 90, P99: 99, Max: 100}, percentiles)
+	assert.Equal(t, uint64(100), fees[0], "the fees are not sorted in place")
+
+	single := data.PriorityFeePercentiles([]uint64{7})
+	assert.Equal(t, uint64(7), single.Min)
+	assert.Equal(t, uint64(7), single.P50)
+	assert.Equal(t, uint64(7), single.Max)
+
+	assert.Equal(t, models.PriorityFees{}, data.PriorityFeePercentiles(nil))
+}
+
+func TestNetworkStatusIsSharedAndCached(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.NetworkCacheTTL = 30 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	nonVote := uint64(600)
+	mockRPC := &MockNetworkRPC{}
+	mockRPC.On("GetEpochInfo").Return(&rpc.EpochInfo{
+		Slot: 250_000_100, BlockHeight: 230_000_000, Epoch: 578, SlotIndex: 108_000, SlotsInEpoch: 432_000,
+	}, nil).Once()
+	mockRPC.On("GetPerformanceSamples", mock.Anything).Return([]rpc.PerformanceSample{
+		{NumTransactions: 3000, NumNonVoteTransactions: &nonVote, SamplePeriodSecs: 60},
+		{NumTransactions: 3000, NumNonVoteTransactions: &nonVote, SamplePeriodSecs: 60},
+	}, nil).Once()
+	mockRPC.On("GetPrioritizationFees", []string(nil)).Return([]uint64{0, 100, 5000}, nil).Once()
+	service := data.NewNetworkService(mockRPC, cache)
+
+	// Concurrent callers share one refresh
+	var wg sync.WaitGroup
+	statuses := make([]*models.NetworkStatus, 10)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, err := service.Status(nil)
+			assert.NoError(t, err)
+			statuses[i] = status
+		}(i)
+	}
+	wg.Wait()
+
+	status := statuses[0]
+	require.NotNil(t, status)
+	assert.Equal(t, uint64(250_000_100), status.Slot)
+	assert.Equal(t, uint64(230_000_000), status.BlockHeight)
+	assert.Equal(t, uint64(578), status.Epoch.Epoch)
+	assert.Equal(t, 0.25, status.Epoch.Progress)
+	assert.Equal(t, 50.0, status.TPS)
+	require.NotNil(t, status.NonVoteTPS)
+	assert.Equal(t, 10.0, *status.NonVoteTPS)
+	assert.Equal(t, 3, status.PriorityFees.Slots)
+	assert.Equal(t, uint64(100), status.PriorityFees.P50)
+	assert.Equal(t, uint64(5000), status.PriorityFees.Max)
+	for _, other := range statuses[1:] {
+		assert.Equal(t, status.UpdatedAt, other.UpdatedAt)
+	}
+	mockRPC.AssertExpectations(t)
+}
+
+// blockingNetworkRPC holds reads of the fees across all transactions until released
+type blockingNetworkRPC struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *blockingNetworkRPC) GetEpochInfo() (*rpc.EpochInfo, error) {
+	return &rpc.EpochInfo{Slot: 1}, nil
+}
+
+func (f *blockingNetworkRPC) GetPerformanceSamples(limit int) ([]rpc.PerformanceSample, error) {
+	return nil, nil
+}
+
+func (f *blockingNetworkRPC) GetPrioritizationFees(accounts []string) ([]uint64, error) {
+	if len(accounts) == 0 {
+		close(f.started)
+		<-f.release
+	}
+	return []uint64{1}, nil
+}
+
+func TestNetworkStatusRefreshesOtherKeysConcurrently(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) { c.NetworkCacheTTL = 30 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	fake := &blockingNetworkRPC{started: make(chan struct{}), release: make(chan struct{})}
+	service := data.NewNetworkService(fake, cache)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := service.Status(nil)
+		assert.NoError(t, err)
+	}()
+	<-fake.started
+
+	// A slow refresh of the global fees does not hold up fees scoped to accounts
+	scoped := make(chan error, 1)
+	go func() {
+		_, err := service.Status([]string{testWallet})
+		scoped <- err
+	}()
+	select {
+	case err := <-scoped:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("the scoped status waited for the global refresh")
+	}
+
+	close(fake.release)
+	<-done
+}
+
+func TestNetworkStatusScopedToAccounts(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.NetworkCacheTTL = 30 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockNetworkRPC{}
+	mockRPC.On("GetEpochInfo").Return(&rpc.EpochInfo{Slot: 1}, nil)
+	mockRPC.On("GetPerformanceSamples", mock.Anything).Return([]rpc.PerformanceSample{{NumTransactions: 10}}, nil)
+	mockRPC.On("GetPrioritizationFees", []string{testWallet, testMint}).Return([]uint64{42}, nil).Once()
+	mockRPC.On("GetPrioritizationFees", []string(nil)).Return(nil, errors.New("rpc unavailable")).Once()
+	mockRPC.On("GetPrioritizationFees", []string(nil)).Return([]uint64{1}, nil).Once()
+	service := data.NewNetworkService(mockRPC, cache)
+
+	// The same accounts in any order and repeated share a cache entry
+	for _, accounts := range [][]string{{testWallet, testMint}, {testMint, testWallet, testMint}} {
+		status, err := service.Status(accounts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{testWallet, testMint}, status.PriorityFees.Accounts)
+		assert.Equal(t, uint64(42), status.PriorityFees.P50)
+		assert.Nil(t, status.NonVoteTPS, "samples without non-vote counts")
+		assert.Equal(t, 0.0, status.TPS, "samples without a period")
+	}
+
+	// Failures are not cached
+	_, err := service.Status(nil)
+	assert.EqualError(t, err, "rpc unavailable")
+	status, err := service.Status(nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), status.PriorityFees.Max)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestNetworkHandler(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.NetworkCacheTTL = 0 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockNetworkRPC{}
+	mockRPC.On("GetEpochInfo").Return(&rpc.EpochInfo{Slot: 99}, nil)
+	mockRPC.On("GetPerformanceSamples", mock.Anything).Return([]rpc.PerformanceSample{}, nil)
+	mockRPC.On("GetPrioritizationFees", []string{testWallet}).Return([]uint64{}, nil)
+	mockRPC.On("GetPrioritizationFees", []string(nil)).Return(nil, errors.New("rpc unavailable"))
+	handler := handlers.NewNetworkHandler(data.NewNetworkService(mockRPC, cache))
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.NetworkHandler(rr, httptest.NewRequest("GET", "/api/network"+query, nil))
+		return rr
+	}
+
+	rr := get("?accounts=" + testWallet)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data models.NetworkStatus `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, uint64(99), response.Data.Slot)
+	assert.Equal(t, []string{testWallet}, response.Data.PriorityFees.Accounts)
+
+	assert.Equal(t, http.StatusBadGateway, get("").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?accounts="+testWallet+",nope").Code)
+	tooMany := strings.TrimSuffix(strings.Repeat(testWallet+",", rpc.MaxPriorityFeeAccounts+1), ",")
+	assert.Equal(t, http.StatusBadRequest, get("?accounts="+tooMany).Code)
+}
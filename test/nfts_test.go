@@ -0,0 +1,249 @@
+package test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testCollection      = "J1S9H3QjnRtBbbuD4HjPV6RpRhwuk4zKbxsnCHuTgh9w"
+	testOtherCollection = "SMBtHCCC6RYRutFEPb4gZqeBLUZbMNhRKaMKZZLHi7W"
+	testCompressedAsset = "JEKKtnGvjiZ8GtATnMVgadHU41AuTbFkMW8oD2tdyV9X"
+)
+
+type MockNFTRPC struct {
+	mock.Mock
+}
+
+func (m *MockNFTRPC) GetTokenAccounts(walletAddress string) ([]rpc.TokenHolding, error) {
+	args := m.Called(walletAddress)
+	holdings, _ := args.Get(0).([]rpc.TokenHolding)
+	return holdings, args.Error(1)
+}
+
+func (m *MockNFTRPC) GetNFTMetadata(mints []string) (map[string]*rpc.NFT, error) {
+	args := m.Called(mints)
+	nfts, _ := args.Get(0).(map[string]*rpc.NFT)
+	return nfts, args.Error(1)
+}
+
+func (m *MockNFTRPC) GetAssetsByOwner(ownerAddress string, page, limit int) (*rpc.AssetPage, error) {
+	args := m.Called(ownerAddress, page, limit)
+	assets, _ := args.Get(0).(*rpc.AssetPage)
+	return assets, args.Error(1)
+}
+
+// metaplexNFTAccount encodes a metadata account with one creator, a token standard and a collection
+func metaplexNFTAccount(mint, name string, tokenStandard uint8, collection string, verified bool) []byte {
+	account := append([]byte{4}, make([]byte, 32)...)
+	account = append(account, solana.MustPublicKeyFromBase58(mint).Bytes()...)
+	account = append(account, borshStrings(name, "NFT", "https://example.com/nft.json")...)
+	account = binary.LittleEndian.AppendUint16(account, 500)
+	account = append(account, 1)
+	account = binary.LittleEndian.AppendUint32(account, 1)
+	account = append(account, make([]byte, 34)...)
+	account = append(account, 1, 0)
+	account = append(account, 1, 255)
+	account = append(account, 1, tokenStandard)
+	if collection == "" {
+		return append(account, 0)
+	}
+	account = append(account, 1, 0)
+	if verified {
+		account[len(account)-1] = 1
+	}
+	return append(account, solana.MustPublicKeyFromBase58(collection).Bytes()...)
+}
+
+func TestDecodeMetaplexNFT(t *testing.T) {
+	nft, err := rpc.DecodeMetaplexNFT(metaplexNFTAccount(testMint, "Degen #1", 4, testCollection, true))
+	require.NoError(t, err)
+	assert.Equal(t, testMint, nft.Mint)
+	assert.Equal(t, "Degen #1", nft.Name)
+	assert.Equal(t, "https://example.com/nft.json", nft.URI)
+	assert.Equal(t, testCollection, nft.Collection)
+	assert.True(t, nft.CollectionVerified)
+	assert.False(t, nft.Fungible)
+
+	nft, err = rpc.DecodeMetaplexNFT(metaplexNFTAccount(testMint, "Gold", 2, "", false))
+	require.NoError(t, err)
+	assert.Empty(t, nft.Collection)
+	assert.True(t, nft.Fungible)
+
+	// Accounts from before collections end after the URI
+	account := metaplexNFTAccount(testMint, "Old", 0, "", false)
+	nft, err = rpc.DecodeMetaplexNFT(account[:1+32+32+4+32+4+3+4+28])
+	require.NoError(t, err)
+	assert.Equal(t, "Old", nft.Name)
+	assert.Empty(t, nft.Collection)
+}
+
+func TestGetAssetsByOwner(t *testing.T) {
+	var params map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "getAssetsByOwner", request.Method)
+		params = request.Params
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"total":2,"limit":1000,"page":1,"items":[
+			{"id":"` + testCompressedAsset + `","interface":"V1_NFT","burnt":false,
+			 "content":{"json_uri":"https://example.com/1.json","metadata":{"name":"Drip #1","symbol":"DRIP"}},
+			 "grouping":[{"group_key":"collection","group_value":"` + testCollection + `"}],
+			 "compression":{"compressed":true}},
+			{"id":"` + testMint + `","interface":"V1_NFT","burnt":true,"compression":{"compressed":true}}
+		]}}`))
+	}))
+	defer server.Close()
+
+	assets, err := rpc.NewSolanaRPC(server.URL).GetAssetsByOwner(testWallet, 2, 10)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ownerAddress": testWallet, "page": 2.0, "limit": 10.0}, params)
+	assert.Equal(t, 2, assets.Returned)
+	assert.Equal(t, []rpc.NFT{{
+		Mint:               testCompressedAsset,
+		Name:               "Drip #1",
+		Symbol:             "DRIP",
+		URI:                "https://example.com/1.json",
+		Collection:         testCollection,
+		CollectionVerified: true,
+		Compressed:         true,
+	}}, assets.Items)
+
+	unsupported := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`))
+	}))
+	defer unsupported.Close()
+	_, err = rpc.NewSolanaRPC(unsupported.URL).GetAssetsByOwner(testWallet, 1, 10)
+	assert.ErrorIs(t, err, rpc.ErrDASUnsupported)
+}
+
+func TestNFTsMergeFilterAndPage(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.NFTCacheTTL = 60 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockNFTRPC{}
+	mockRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{
+		{Mint: testMint, Amount: 1, Decimals: 0},
+		{Mint: testOtherWallet, Amount: 1, Decimals: 0},
+		{Mint: testBonkMint, Amount: 1, Decimals: 5},
+		{Mint: testVoter, Amount: 1, Decimals: 0},
+	}, nil).Once()
+	mockRPC.On("GetNFTMetadata", []string{testMint, testOtherWallet, testVoter}).Return(map[string]*rpc.NFT{
+		testMint:        {Name: "B", Collection: testCollection, CollectionVerified: true},
+		testOtherWallet: {Name: "Fake", Collection: testCollection},
+		testVoter:       {Name: "Gold", Fungible: true},
+	}, nil).Once()
+	mockRPC.On("GetAssetsByOwner", testWallet, 1, rpc.MaxAssetsPerPage).Return(&rpc.AssetPage{Returned: 3, Items: []rpc.NFT{
+		{Mint: testCompressedAsset, Name: "A", Collection: testCollection, CollectionVerified: true, Compressed: true},
+		{Mint: testOtherCollection, Name: "Standard", Collection: testCollection, CollectionVerified: true},
+		{Mint: testBonkMint, Name: "Bonk", Compressed: true, Fungible: true},
+	}}, nil).Once()
+	service := data.NewNFTService(mockRPC, cache)
+
+	all, err := service.NFTs(testWallet, nil, 1, 50)
+	require.NoError(t, err)
+	assert.Equal(t, 3, all.Total)
+	assert.Empty(t, all.CompressedError)
+
+	page, err := service.NFTs(testWallet, []string{testCollection}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.NFTs, 1)
+	assert.Equal(t, testCompressedAsset, page.NFTs[0].Mint)
+	assert.True(t, page.NFTs[0].Compressed)
+
+	page, err = service.NFTs(testWallet, []string{testCollection}, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, page.NFTs, 1)
+	assert.Equal(t, testMint, page.NFTs[0].Mint)
+	assert.Equal(t, "B", page.NFTs[0].Name)
+
+	page, err = service.NFTs(testWallet, []string{testCollection}, 3, 1)
+	require.NoError(t, err)
+	assert.Empty(t, page.NFTs)
+
+	// (page-1)*limit would overflow
+	page, err = service.NFTs(testWallet, nil, math.MaxInt/2, 100)
+	require.NoError(t, err)
+	assert.Empty(t, page.NFTs)
+	assert.Equal(t, 3, page.Total)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestNFTsWithoutDAS(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.NFTCacheTTL = 0 })
+	cache := data.NewMemoryBalanceCache(10)
+	defer cache.Close()
+
+	mockRPC := &MockNFTRPC{}
+	mockRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{{Mint: testMint, Amount: 1}}, nil)
+	mockRPC.On("GetNFTMetadata", []string{testMint}).Return(map[string]*rpc.NFT{}, nil)
+	mockRPC.On("GetAssetsByOwner", testWallet, 1, rpc.MaxAssetsPerPage).Return(nil, rpc.ErrDASUnsupported).Once()
+	service := data.NewNFTService(mockRPC, cache)
+
+	// The endpoint is only asked once whether it implements DAS
+	for i := 0; i < 2; i++ {
+		page, err := service.NFTs(testWallet, nil, 1, 50)
+		require.NoError(t, err)
+		require.Len(t, page.NFTs, 1)
+		assert.Equal(t, testMint, page.NFTs[0].Mint)
+		assert.Equal(t, rpc.ErrDASUnsupported.Error(), page.CompressedError)
+	}
+	mockRPC.AssertExpectations(t)
+}
+
+func TestNFTsHandler(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.NFTCacheTTL = 0 })
+	cache := data.NewMemoryBalanceCache(10)
+	defer cache.Close()
+
+	mockRPC := &MockNFTRPC{}
+	mockRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{}, nil)
+	mockRPC.On("GetTokenAccounts", testOtherWallet).Return(nil, errors.New("rpc unavailable"))
+	mockRPC.On("GetAssetsByOwner", testWallet, 1, rpc.MaxAssetsPerPage).Return(&rpc.AssetPage{}, nil)
+	handler := handlers.NewNFTHandler(data.NewNFTService(mockRPC, cache))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.NFTsHandler(rr, httptest.NewRequest("GET", "/api/nfts?"+query, nil))
+		return rr
+	}
+
+	rr := get("wallet=" + testWallet + "&collection=" + testCollection + "&page=2&limit=10")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data models.NFTPage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Data.Page)
+	assert.Equal(t, 10, response.Data.Limit)
+	assert.NotNil(t, response.Data.NFTs)
+
+	assert.Equal(t, http.StatusBadRequest, get("").Code)
+	assert.Equal(t, http.StatusBadRequest, get("wallet="+testWallet+"&limit=101").Code)
+	assert.Equal(t, http.StatusBadRequest, get("wallet="+testWallet+"&page=0").Code)
+	assert.Equal(t, http.StatusBadRequest, get("wallet="+testWallet+"&page=10001").Code)
+	assert.Equal(t, http.StatusBadRequest, get("wallet="+testWallet+"&page=4611686018427387904").Code)
+	assert.Equal(t, http.StatusBadRequest, get("wallet="+testWallet+"&collection=nope").Code)
+	assert.Equal(t, http.StatusBadGateway, get("wallet="+testOtherWallet).Code)
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortfolio(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.BalanceCacheTTL = 30
+		c.StakeCacheTTL = 30
+		c.NFTCacheTTL = 30
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(1.0, nil).Once()
+	balanceRPC.On("GetBalance", testOtherWallet).Return(0.0, errors.New("balance unavailable"))
+
+	tokenRPC := &MockTokenRPC{}
+	tokenRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{{Mint: testMint, Amount: 2_000_000, Decimals: 6}}, nil).Once()
+	tokenRPC.On("GetTokenAccounts", testOtherWallet).Return([]rpc.TokenHolding{{Mint: testMint, Amount: 500_000, Decimals: 6}}, nil).Once()
+	tokenRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{Mint: testMint, Decimals: 6, Symbol: "USDC"}, nil)
+
+	stakeRPC := &MockStakeRPC{}
+	stakeRPC.On("GetStakeAccounts", testWallet).Return([]rpc.StakeAccount{{
+		Address: testVoter, Lamports: 3_000_000_000, Voter: testVoter, Stake: 3_000_000_000,
+		ActivationEpoch: 10, DeactivationEpoch: math.MaxUint64,
+	}}, nil).Once()
+	stakeRPC.On("GetStakeAccounts", testOtherWallet).Return([]rpc.StakeAccount{}, nil).Once()
+	stakeRPC.On("GetEpoch").Return(uint64(500), nil).Once()
+
+	nftRPC := &MockNFTRPC{}
+	nftRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{}, nil).Once()
+	nftRPC.On("GetAssetsByOwner", testWallet, 1, rpc.MaxAssetsPerPage).Return(&rpc.AssetPage{Returned: 1, Items: []rpc.NFT{
+		{Mint: testCompressedAsset, Compressed: true},
+	}}, nil).Once()
+	nftRPC.On("GetTokenAccounts", testOtherWallet).Return(nil, errors.New("nfts unavailable"))
+
+	service := data.NewPortfolioService(
+		data.NewBalanceService(balanceRPC, cache),
+		data.NewTokenService(tokenRPC, cache, data.NewTokenMetadataService(tokenRPC, cache)),
+		data.NewStakeService(stakeRPC, cache),
+		data.NewNFTService(nftRPC, cache),
+	)
+
+	// The second read is served from the cache of every section that succeeded
+	for i := 0; i < 2; i++ {
+		portfolio := service.Portfolio([]string{testWallet, testOtherWallet, "nope"})
+		require.Len(t, portfolio.Wallets, 3)
+
+		wallet := portfolio.Wallets[0]
+		require.NotNil(t, wallet.SOL)
+		assert.Equal(t, 1.0, *wallet.SOL)
+		require.Len(t, wallet.Tokens, 1)
+		require.NotNil(t, wallet.Staked)
+		assert.Equal(t, 3.0, wallet.Staked.Staked)
+		assert.Equal(t, 4.0, wallet.Staked.Total)
+		assert.Equal(t, &models.NFTCount{Total: 1, Compressed: 1}, wallet.NFTs)
+
+		other := portfolio.Wallets[1]
+		assert.Nil(t, other.SOL)
+		assert.Equal(t, "balance unavailable", other.SOLError)
+		require.Len(t, other.Tokens, 1)
+		require.NotNil(t, other.Staked)
+		assert.Equal(t, 0.0, other.Staked.Total)
+		assert.Nil(t, other.NFTs)
+		assert.Equal(t, "nfts unavailable", other.NFTsError)
+
+		assert.Contains(t, portfolio.Wallets[2].Error, rpc.ErrInvalidAddress.Error())
+		assert.Nil(t, portfolio.Wallets[2].Staked)
+
+		totals := portfolio.Totals
+		assert.Equal(t, 1.0, totals.SOL)
+		assert.Equal(t, 3.0, totals.Staked)
+		assert.Equal(t, 4.0, totals.Total)
+		assert.Equal(t, 1, totals.NFTs)
+		require.Len(t, totals.Tokens, 1)
+		assert.Equal(t, 2.5, totals.Tokens[0].Amount)
+		assert.True(t, totals.Partial)
+	}
+	tokenRPC.AssertExpectations(t)
+	stakeRPC.AssertExpectations(t)
+	nftRPC.AssertExpectations(t)
+}
+
+func TestPortfolioTotalsSortTokens(t *testing.T) {
+	sol := 2.0
+	totals := data.PortfolioTotals([]models.WalletPortfolio{
+		{Wallet: testWallet, SOL: &sol, Tokens: []models.TokenBalance{{Mint: testMint, Amount: 1}, {Mint: testBonkMint, Amount: 5}}},
+		{Wallet: testOtherWallet, SOL: &sol, Tokens: []models.TokenBalance{{Mint: testMint, Amount: 10}}},
+	})
+	assert.Equal(t, 4.0, totals.Total)
+	assert.False(t, totals.Partial)
+	require.Len(t, totals.Tokens, 2)
+	assert.Equal(t, testMint, totals.Tokens[0].Mint)
+	assert.Equal(t, 11.0, totals.Tokens[0].Amount)
+}
+
+func TestPortfolioHandlerValidation(t *testing.T) {
+	handler := handlers.NewPortfolioHandler(data.NewPortfolioService(nil, nil, nil, nil))
+	post := func(body string) int {
+		rr := httptest.NewRecorder()
+		handler.PortfolioHandler(rr, httptest.NewRequest("POST", "/api/portfolio", bytes.NewBufferString(body)))
+		return rr.Code
+	}
+
+	wallets := make([]string, config.AppConfig.MaxWalletsPerRequest+1)
+	tooMany, _ := json.Marshal(models.PortfolioRequest{Wallets: wallets})
+	assert.Equal(t, http.StatusBadRequest, post(string(tooMany)))
+	assert.Equal(t, http.StatusBadRequest, post(`{"wallets": []}`))
+	assert.Equal(t, http.StatusBadRequest, post(`{`))
+}
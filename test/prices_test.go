@@ -0,0 +1,199 @@
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// The synthetic fixtures are hand-built in the getAccountInfo response format
+// to pin the account layouts and the checks on top of them. Both hold SOL/USD
+// at 145.23456789 ± 0.07512345, published at 2024-06-10T06:13:20Z. Accounts
+// recorded from mainnet are checked in pyth_recording_test.go.
+const (
+	legacyPriceFixture = "pyth_sol_usd_v2_synthetic.json"
+	pullPriceFixture   = "pyth_sol_usd_price_update_synthetic.json"
+)
+
+var fixturePublishTime = time.Unix(1718000000, 0).UTC()
+
+type MockPriceRPC struct {
+	mock.Mock
+}
+
+func (m *MockPriceRPC) GetPythPrice(priceAccount string) (*rpc.OraclePrice, error) {
+	args := m.Called(priceAccount)
+	price, _ := args.Get(0).(*rpc.OraclePrice)
+	return price, args.Error(1)
+}
+
+// loadPriceFixture returns the account data of a getAccountInfo response in testdata
+func loadPriceFixture(t *testing.T, name string) []byte {
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return accountInfoData(t, raw)
+}
+
+// accountInfoData returns the account data of a base64 getAccountInfo result
+func accountInfoData(t *testing.T, raw []byte) []byte {
+	var response struct {
+		Value struct {
+			Data [2]string `json:"data"`
+		} `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &response))
+	require.Equal(t, "base64", response.Value.Data[1])
+
+	decoded, err := base64.StdEncoding.DecodeString(response.Value.Data[0])
+	require.NoError(t, err)
+	return decoded
+}
+
+func withPriceConfig(t *testing.T, maxAge int) {
+	withConfig(t, func(c *config.Config) {
+		c.PriceFeeds = nil
+		c.PriceMaxAge = maxAge
+		c.PriceMaxConfidenceBps = 200
+		c.PriceCacheTTL = 5
+	})
+}
+
+func TestDecodePythPriceFixtures(t *testing.T) {
+	for _, fixture := range []string{legacyPriceFixture, pullPriceFixture} {
+		t.Run(fixture, func(t *testing.T) {
+			price, err := rpc.DecodePythPrice(loadPriceFixture(t, fixture))
+			require.NoError(t, err)
+			assert.InDelta(t, 145.23456789, price.Price, 1e-9)
+			assert.InDelta(t, 0.07512345, price.Confidence, 1e-12)
+			assert.Equal(t, fixturePublishTime, price.PublishTime)
+			assert.True(t, price.Tradable)
+		})
+	}
+}
+
+func TestDecodePythPriceRejectsUnusableAccounts(t *testing.T) {
+	_, err := rpc.DecodePythPrice(make([]byte, 3312))
+	assert.Error(t, err)
+
+	// A halted legacy feed decodes but is not tradable
+	legacy := loadPriceFixture(t, legacyPriceFixture)
+	binary.LittleEndian.PutUint32(legacy[224:], 2)
+	price, err := rpc.DecodePythPrice(legacy)
+	require.NoError(t, err)
+	assert.False(t, price.Tradable)
+
+	// A partially verified update carries the signature count, shifting the message by a byte
+	pull := loadPriceFixture(t, pullPriceFixture)
+	partial := append(append(append([]byte{}, pull[:40]...), 0, 3), pull[41:]...)
+	price, err = rpc.DecodePythPrice(partial)
+	require.NoError(t, err)
+	assert.InDelta(t, 145.23456789, price.Price, 1e-9)
+	assert.False(t, price.Tradable)
+
+	_, err = rpc.DecodePythPrice(pull[:60])
+	assert.Error(t, err)
+}
+
+func TestUSDPriceChecks(t *testing.T) {
+	fixture, err := rpc.DecodePythPrice(loadPriceFixture(t, pullPriceFixture))
+	require.NoError(t, err)
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	// The fixture is old, so it only passes with a generous maximum age
+	withPriceConfig(t, int(time.Since(fixturePublishTime).Seconds())+3600)
+	mockRPC := &MockPriceRPC{}
+	mockRPC.On("GetPythPrice", "7UVimffxr9ow1uXYxsr4LHAcV58mLzhmwaeKvJ1pjLiE").Return(fixture, nil).Once()
+	service := data.NewPriceService(mockRPC, cache)
+
+	price, err := service.USDPrice(data.NativeMint)
+	require.NoError(t, err)
+	assert.InDelta(t, 145.23456789, price, 1e-9)
+
+	_, err = service.USDPrice(testMint)
+	assert.ErrorIs(t, err, data.ErrNoPriceFeed)
+
+	// The cached price is checked again on every read
+	withConfig(t, func(c *config.Config) { c.PriceMaxAge = 60 })
+	_, err = service.USDPrice(data.NativeMint)
+	assert.ErrorIs(t, err, data.ErrPriceStale)
+	mockRPC.AssertExpectations(t)
+
+	uncertain := *fixture
+	uncertain.PublishTime = time.Now()
+	uncertain.Confidence = 5
+	uncertainRPC := &MockPriceRPC{}
+	uncertainRPC.On("GetPythPrice", mock.Anything).Return(&uncertain, nil)
+	uncertainCache := data.NewMemoryBalanceCache(10)
+	defer uncertainCache.Close()
+	_, err = data.NewPriceService(uncertainRPC, uncertainCache).USDPrice(data.NativeMint)
+	assert.ErrorIs(t, err, data.ErrPriceUncertain)
+}
+
+func TestBalanceQuoteUSD(t *testing.T) {
+	withPriceConfig(t, 60)
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+	priceCache := data.NewMemoryBalanceCache(10)
+	defer priceCache.Close()
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(2.0, nil)
+	priceRPC := &MockPriceRPC{}
+	priceRPC.On("GetPythPrice", mock.Anything).Return(&rpc.OraclePrice{Price: 150, Confidence: 0.1, PublishTime: time.Now(), Tradable: true}, nil).Once()
+	priceRPC.On("GetPythPrice", mock.Anything).Return(nil, errors.New("rpc unavailable"))
+
+	balanceHandler := handlers.NewBalanceHandler(data.NewBalanceService(balanceRPC, cache))
+	balanceHandler.SetPriceService(data.NewPriceService(priceRPC, priceCache))
+
+	post := func(query string) (*httptest.ResponseRecorder, []models.WalletBalance) {
+		body := bytes.NewBufferString(`{"wallets": ["` + testWallet + `"]}`)
+		rr := httptest.NewRecorder()
+		balanceHandler.GetBalanceHandler(rr, httptest.NewRequest("POST", "/api/get-balance?"+query, body))
+
+		var response struct {
+			Data []models.WalletBalance `json:"data"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response.Data
+	}
+
+	rr, balances := post("quote=usd")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotNil(t, balances[0].USD)
+	assert.Equal(t, 300.0, *balances[0].USD)
+
+	_, balances = post("")
+	assert.Nil(t, balances[0].USD)
+
+	rr, _ = post("quote=eur")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Without a price the balance is still returned
+	uncachedCache := data.NewMemoryBalanceCache(10)
+	defer uncachedCache.Close()
+	balanceHandler.SetPriceService(data.NewPriceService(priceRPC, uncachedCache))
+	rr, balances = post("quote=usd")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2.0, balances[0].Balance)
+	assert.Nil(t, balances[0].USD)
+	assert.Equal(t, "rpc unavailable", balances[0].QuoteError)
+}
@@ -0,0 +1,179 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRentRPC struct {
+	MockAccountRPC
+}
+
+func (m *MockRentRPC) GetEpoch() (uint64, error) {
+	args := m.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *MockRentRPC) GetRentExemptMinimum(dataSize uint64) (uint64, error) {
+	args := m.Called(dataSize)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func TestRentMinimumsAreCachedPerEpoch(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.EpochCacheTTL = 0
+		c.AccountCacheTTL = 0
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockRentRPC{}
+	mockRPC.On("GetEpoch").Return(uint64(500), nil).Twice()
+	mockRPC.On("GetEpoch").Return(uint64(501), nil).Once()
+	mockRPC.On("GetRentExemptMinimum", uint64(165)).Return(uint64(2_039_280), nil).Twice()
+	mockRPC.On("GetRentExemptMinimum", uint64(0)).Return(uint64(890_880), nil).Twice()
+	service := data.NewRentService(mockRPC, cache)
+
+	for i := 0; i < 2; i++ {
+		exemptions, err := service.Minimums([]uint64{165, 0})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(500), exemptions.Epoch)
+		assert.Equal(t, []models.RentExemption{
+			{DataSize: 165, Lamports: 2_039_280, SOL: 0.00203928},
+			{DataSize: 0, Lamports: 890_880, SOL: 0.00089088},
+		}, exemptions.Minimums)
+	}
+
+	// A new epoch reads the minimums again
+	exemptions, err := service.Minimums([]uint64{165, 0})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(501), exemptions.Epoch)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestRentStatus(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.EpochCacheTTL = 0
+		c.AccountCacheTTL = 0
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockRentRPC{}
+	mockRPC.On("GetAccounts", []string{testWallet, testMint, testOtherWallet}, rpc.EncodingBase64, &rpc.DataSlice{}).Return([]*rpc.AccountInfo{
+		{Lamports: 1_000_000_000, Owner: "11111111111111111111111111111111"},
+		{Lamports: 1_000_000, Owner: "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA", Space: 82},
+		nil,
+	}, nil)
+	mockRPC.On("GetEpoch").Return(uint64(500), nil)
+	mockRPC.On("GetRentExemptMinimum", uint64(0)).Return(uint64(890_880), nil).Once()
+	mockRPC.On("GetRentExemptMinimum", uint64(82)).Return(uint64(1_461_600), nil).Once()
+
+	statuses, err := data.NewRentService(mockRPC, cache).RentStatus([]string{testWallet, testMint, testOtherWallet, "nope"})
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	assert.Equal(t, &models.RentStatus{DataSize: 0, Minimum: 0.00089088, RentExempt: true}, statuses[0])
+	assert.Equal(t, &models.RentStatus{DataSize: 82, Minimum: 0.0014616, RentExempt: false}, statuses[1])
+	assert.Equal(t, &models.RentStatus{DataSize: 0, Minimum: 0.00089088, RentExempt: false}, statuses[2], "missing accounts are not exempt")
+	assert.Nil(t, statuses[3])
+	mockRPC.AssertExpectations(t)
+}
+
+func TestBalanceIncludeRent(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.EpochCacheTTL = 0
+		c.AccountCacheTTL = 0
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(1.0, nil)
+	balanceRPC.On("GetBalance", testOtherWallet).Return(0.0, errors.New("rpc unavailable"))
+	rentRPC := &MockRentRPC{}
+	rentRPC.On("GetAccounts", []string{testWallet}, rpc.EncodingBase64, &rpc.DataSlice{}).Return([]*rpc.AccountInfo{
+		{Lamports: 1_000_000_000, Owner: "11111111111111111111111111111111"},
+	}, nil).Once()
+	rentRPC.On("GetAccounts", []string{testWallet}, rpc.EncodingBase64, &rpc.DataSlice{}).Return([]*rpc.AccountInfo(nil), errors.New("accounts unavailable")).Once()
+	rentRPC.On("GetEpoch").Return(uint64(500), nil)
+	rentRPC.On("GetRentExemptMinimum", uint64(0)).Return(uint64(890_880), nil)
+
+	handler := handlers.NewBalanceHandler(data.NewBalanceService(balanceRPC, cache))
+	handler.SetRentService(data.NewRentService(rentRPC, cache))
+	post := func(body string) []models.WalletBalance {
+		rr := httptest.NewRecorder()
+		handler.GetBalanceHandler(rr, httptest.NewRequest("POST", "/api/get-balance", bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var response struct {
+			Data []models.WalletBalance `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	balances := post(`{"wallets": ["` + testWallet + `"]}`)
+	assert.Nil(t, balances[0].Rent)
+
+	balances = post(`{"wallets": ["` + testWallet + `", "` + testOtherWallet + `"], "include_rent": true}`)
+	require.Len(t, balances, 2)
+	require.NotNil(t, balances[0].Rent)
+	assert.True(t, balances[0].Rent.RentExempt)
+	assert.Nil(t, balances[1].Rent)
+	assert.Empty(t, balances[1].RentError)
+
+	// The balance survives a failed account read
+	balances = post(`{"wallets": ["` + testWallet + `"], "include_rent": true}`)
+	assert.Equal(t, 1.0, balances[0].Balance)
+	assert.Nil(t, balances[0].Rent)
+	assert.Equal(t, "accounts unavailable", balances[0].RentError)
+	rentRPC.AssertExpectations(t)
+}
+
+func TestRentExemptionHandler(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.EpochCacheTTL = 0
+		c.AccountCacheTTL = 0
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockRentRPC{}
+	mockRPC.On("GetEpoch").Return(uint64(500), nil)
+	mockRPC.On("GetRentExemptMinimum", uint64(165)).Return(uint64(2_039_280), nil)
+	mockRPC.On("GetRentExemptMinimum", uint64(1000)).Return(uint64(0), errors.New("rpc unavailable"))
+	handler := handlers.NewRentHandler(data.NewRentService(mockRPC, cache))
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.RentExemptionHandler(rr, httptest.NewRequest("GET", "/api/rent-exemption"+query, nil))
+		return rr
+	}
+
+	rr := get("?sizes=165,%20165")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data models.RentExemptions `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, uint64(500), response.Data.Epoch)
+	require.Len(t, response.Data.Minimums, 2)
+	assert.Equal(t, uint64(2_039_280), response.Data.Minimums[1].Lamports)
+
+	assert.Equal(t, http.StatusBadGateway, get("?sizes=1000").Code)
+	for _, query := range []string{"", "?sizes=", "?sizes=-1", "?sizes=abc", "?sizes=10485761"} {
+		assert.Equal(t, http.StatusBadRequest, get(query).Code, query)
+	}
+	mockRPC.AssertNotCalled(t, "GetRentExemptMinimum", uint64(10485761))
+}
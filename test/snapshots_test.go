@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySnapshotStore is an in-memory SnapshotStore
+type memorySnapshotStore struct {
+	mutex     sync.Mutex
+	snapshots []models.BalanceSnapshot
+}
+
+func (s *memorySnapshotStore) SaveSnapshots(snapshots []models.BalanceSnapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.snapshots = append(s.snapshots, snapshots...)
+	return nil
+}
+
+func (s *memorySnapshotStore) BalanceHistory(walletAddress string, from, to time.Time) ([]models.BalanceSnapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshots := []models.BalanceSnapshot{}
+	for _, snapshot := range s.snapshots {
+		if snapshot.Wallet == walletAddress && !snapshot.Time.Before(from) && snapshot.Time.Before(to) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func TestDownsampleLastAndMinMax(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int, balance float64) models.BalanceSnapshot {
+		return models.BalanceSnapshot{Wallet: testWallet, Balance: balance, Time: from.Add(time.Duration(minutes) * time.Minute)}
+	}
+	snapshots := []models.BalanceSnapshot{at(0, 5), at(20, 3), at(50, 4), at(130, 7), at(150, 6)}
+
+	last := data.Downsample(snapshots, from, time.Hour, data.HistoryAggregateLast)
+	require.Len(t, last, 2)
+	assert.Equal(t, from, last[0].Time)
+	assert.Equal(t, 4.0, *last[0].Balance)
+	assert.Nil(t, last[0].Min)
+	// The bucket starting at 01:00 has no snapshots and is omitted
+	assert.Equal(t, from.Add(2*time.Hour), last[1].Time)
+	assert.Equal(t, 6.0, *last[1].Balance)
+
+	minMax := data.Downsample(snapshots, from, time.Hour, data.HistoryAggregateMinMax)
+	require.Len(t, minMax, 2)
+	assert.Equal(t, 3.0, *minMax[0].Min)
+	assert.Equal(t, 5.0, *minMax[0].Max)
+	assert.Nil(t, minMax[0].Balance)
+	assert.Equal(t, 6.0, *minMax[1].Min)
+	assert.Equal(t, 7.0, *minMax[1].Max)
+}
+
+func TestSnapshotterRecordsWatchlistedWallets(t *testing.T) {
+	withWarmerConfig(t, []string{testWallet}, 0, 10)
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", testWallet).Return(2.0, nil)
+	mockRPC.On("GetBalance", testOtherWallet).Return(0.0, errors.New("rpc unavailable"))
+
+	watchlist := &MockWatchlist{}
+	watchlist.On("GetWatchlist").Return([]string{testWallet, testOtherWallet}, nil)
+
+	// A stale cached balance is not what gets recorded
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+	require.NoError(t, cache.SetBalance(testWallet, 1.0))
+	service := data.NewBalanceService(mockRPC, cache)
+
+	store := &memorySnapshotStore{}
+	snapshotter := data.NewSnapshotter(service, store, newMemoryWebhookStore(), watchlist)
+
+	assert.Equal(t, 1, snapshotter.Snapshot())
+	require.Len(t, store.snapshots, 1)
+	assert.Equal(t, testWallet, store.snapshots[0].Wallet)
+	assert.Equal(t, 2.0, store.snapshots[0].Balance)
+	mockRPC.AssertNumberOfCalls(t, "GetBalance", 2)
+	assert.Empty(t, service.TopRequestedWallets(10), "snapshots are not requests for the cache warmer")
+}
+
+func TestSnapshotterOnlyLeaseHolderRecords(t *testing.T) {
+	withWarmerConfig(t, []string{testWallet}, 0, 10)
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", testWallet).Return(2.0, nil)
+	service := data.NewBalanceService(mockRPC, data.NoopBalanceCache{})
+
+	// Two replicas sharing one lease store
+	store := &memorySnapshotStore{}
+	leases := newMemoryWebhookStore()
+	first := data.NewSnapshotter(service, store, leases, nil)
+	second := data.NewSnapshotter(service, store, leases, nil)
+
+	assert.Equal(t, 1, first.Snapshot())
+	assert.Equal(t, 0, second.Snapshot())
+	assert.Equal(t, 1, first.Snapshot())
+	assert.Len(t, store.snapshots, 2)
+
+	// Once the holder's lease runs out the other replica takes over
+	leases.mutex.Lock()
+	lease := leases.leases["snapshots"]
+	lease.expires = time.Now().Add(-time.Second)
+	leases.leases["snapshots"] = lease
+	leases.mutex.Unlock()
+	assert.Equal(t, 1, second.Snapshot())
+	assert.Equal(t, 0, first.Snapshot())
+	assert.Len(t, store.snapshots, 3)
+}
+
+func TestSnapshotterStopsOnClose(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) {
+		c.SnapshotInterval = 1
+		c.WatchlistWallets = []string{testWallet}
+	})
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", testWallet).Return(1.0, nil)
+
+	store := &memorySnapshotStore{}
+	snapshotter := data.NewSnapshotter(data.NewBalanceService(mockRPC, data.NoopBalanceCache{}), store, newMemoryWebhookStore(), nil)
+	snapshotter.Start(context.Background())
+	assert.Eventually(t, func() bool {
+		history, _ := store.BalanceHistory(testWallet, time.Time{}, time.Now().Add(time.Minute))
+		return len(history) == 1
+	}, time.Second, 10*time.Millisecond)
+	snapshotter.Close()
+}
+
+func TestBalanceHistoryHandler(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &memorySnapshotStore{}
+	store.SaveSnapshots([]models.BalanceSnapshot{
+		{Wallet: testWallet, Balance: 1, Time: from.Add(time.Hour)},
+		{Wallet: testWallet, Balance: 2, Time: from.Add(25 * time.Hour)},
+		{Wallet: testOtherWallet, Balance: 9, Time: from.Add(time.Hour)},
+	})
+	historyHandler := handlers.NewHistoryHandler(store)
+
+	get := func(query string) (*httptest.ResponseRecorder, []models.BalanceHistoryPoint) {
+		req := httptest.NewRequest("GET", "/api/balance-history?"+query, nil)
+		rr := httptest.NewRecorder()
+		historyHandler.BalanceHistoryHandler(rr, req)
+
+		var response struct {
+			Data []models.BalanceHistoryPoint `json:"data"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response.Data
+	}
+
+	rr, points := get("wallet=" + testWallet + "&from=2024-01-01T00:00:00Z&to=2024-01-03T00:00:00Z&interval=1d")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, points, 2)
+	assert.Equal(t, from, points[0].Time)
+	assert.Equal(t, 1.0, *points[0].Balance)
+	assert.Equal(t, 2.0, *points[1].Balance)
+
+	rr, points = get("wallet=" + testWallet + "&from=1704067200&to=1704240000&interval=1d&aggregate=minmax")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, points, 2)
+	assert.Equal(t, 1.0, *points[0].Max)
+
+	invalid := []string{
+		"wallet=not-a-wallet",
+		"wallet=" + testWallet + "&from=yesterday",
+		"wallet=" + testWallet + "&from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z",
+		"wallet=" + testWallet + "&interval=10s",
+		"wallet=" + testWallet + "&interval=1m&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z",
+		"wallet=" + testWallet + "&aggregate=avg",
+	}
+	for _, query := range invalid {
+		rr, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
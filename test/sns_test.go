@@ -0,0 +1,89 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockNameRPC struct {
+	mock.Mock
+}
+
+func (m *MockNameRPC) ResolveSOLDomain(domain string) (string, error) {
+	args := m.Called(domain)
+	return args.String(0), args.Error(1)
+}
+
+func TestSOLDomainKey(t *testing.T) {
+	// The name account of bonfida.sol as published by Bonfida
+	key, err := rpc.SOLDomainKey("bonfida.sol")
+	require.NoError(t, err)
+	assert.Equal(t, "Crf8hzfthWGbGbLTVCiqRqV5MVnbpHB1L9KQMd6gsinb", key.String())
+
+	upper, err := rpc.SOLDomainKey("Bonfida.SOL")
+	require.NoError(t, err)
+	assert.Equal(t, key, upper)
+
+	_, err = rpc.SOLDomainKey("dex.bonfida.sol")
+	assert.ErrorIs(t, err, rpc.ErrInvalidAddress)
+	_, err = rpc.SOLDomainKey(".sol")
+	assert.ErrorIs(t, err, rpc.ErrInvalidAddress)
+
+	assert.True(t, rpc.IsSOLDomain("alice.sol"))
+	assert.False(t, rpc.IsSOLDomain(testWallet))
+}
+
+func TestBalanceResolvesSOLDomains(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.SNSCacheTTL = 60 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	nameRPC := &MockNameRPC{}
+	nameRPC.On("ResolveSOLDomain", "alice.sol").Return(testWallet, nil).Once()
+	nameRPC.On("ResolveSOLDomain", "nobody.sol").Return("", rpc.ErrDomainNotFound)
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(1.5, nil)
+	balanceRPC.On("GetBalance", testOtherWallet).Return(2.0, nil)
+
+	balanceHandler := handlers.NewBalanceHandler(data.NewBalanceService(balanceRPC, cache))
+	post := func() []models.WalletBalance {
+		body := bytes.NewBufferString(`{"wallets": ["Alice.sol", "nobody.sol", "` + testOtherWallet + `"]}`)
+		rr := httptest.NewRecorder()
+		balanceHandler.GetBalanceHandler(rr, httptest.NewRequest("POST", "/api/get-balance", body))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data []models.WalletBalance `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.Len(t, response.Data, 3)
+		return response.Data
+	}
+
+	balances := post()
+	assert.Equal(t, ".sol domains are not supported", balances[0].Error)
+
+	balanceHandler.SetNameService(data.NewNameService(nameRPC, cache))
+	for i := 0; i < 2; i++ {
+		balances = post()
+		assert.Equal(t, models.WalletBalance{Wallet: "Alice.sol", ResolvedAddress: testWallet, Balance: 1.5}, balances[0])
+		assert.Equal(t, "nobody.sol", balances[1].Wallet)
+		assert.Empty(t, balances[1].ResolvedAddress)
+		assert.Equal(t, rpc.ErrDomainNotFound.Error(), balances[1].Error)
+		assert.Equal(t, models.WalletBalance{Wallet: testOtherWallet, Balance: 2.0}, balances[2])
+	}
+	nameRPC.AssertExpectations(t)
+}
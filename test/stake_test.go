@@ -0,0 +1,254 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testVoter = "Vote111111111111111111111111111111111111111"
+
+type MockStakeRPC struct {
+	mock.Mock
+}
+
+func (m *MockStakeRPC) GetStakeAccounts(walletAddress string) ([]rpc.StakeAccount, error) {
+	args := m.Called(walletAddress)
+	return args.Get(0).([]rpc.StakeAccount), args.Error(1)
+}
+
+func (m *MockStakeRPC) GetEpoch() (uint64, error) {
+	args := m.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// encodeStakeAccount lays out a delegated StakeStateV2 account
+func encodeStakeAccount(staker, withdrawer, voter string, reserve, stake, activation, deactivation uint64) []byte {
+	buf := make([]byte, rpc.StakeAccountSize)
+	binary.LittleEndian.PutUint32(buf[0:], 2)
+	binary.LittleEndian.PutUint64(buf[4:], reserve)
+	copy(buf[12:], solana.MustPublicKeyFromBase58(staker).Bytes())
+	copy(buf[44:], solana.MustPublicKeyFromBase58(withdrawer).Bytes())
+	copy(buf[124:], solana.MustPublicKeyFromBase58(voter).Bytes())
+	binary.LittleEndian.PutUint64(buf[156:], stake)
+	binary.LittleEndian.PutUint64(buf[164:], activation)
+	binary.LittleEndian.PutUint64(buf[172:], deactivation)
+	return buf
+}
+
+func TestParseStakeAccount(t *testing.T) {
+	raw := encodeStakeAccount(testWallet, testOtherWallet, testVoter, 2_282_880, 5_000_000_000, 500, math.MaxUint64)
+
+	account, err := rpc.ParseStakeAccount("stake-1", 5_002_282_880, raw)
+	require.NoError(t, err)
+	assert.Equal(t, testWallet, account.Staker)
+	assert.Equal(t, testOtherWallet, account.Withdrawer)
+	assert.Equal(t, testVoter, account.Voter)
+	assert.Equal(t, uint64(2_282_880), account.RentExemptReserve)
+	assert.Equal(t, uint64(5_000_000_000), account.Stake)
+	assert.Equal(t, uint64(500), account.ActivationEpoch)
+	assert.False(t, account.Deactivated())
+
+	_, err = rpc.ParseStakeAccount("stake-1", 0, raw[:100])
+	assert.Error(t, err)
+	_, err = rpc.ParseStakeAccount("stake-1", 0, make([]byte, rpc.StakeAccountSize))
+	assert.Error(t, err, "uninitialized accounts are rejected")
+}
+
+func TestClassifyStake(t *testing.T) {
+	delegated := func(activation, deactivation uint64) rpc.StakeAccount {
+		return rpc.StakeAccount{
+			Address:           "stake-1",
+			Lamports:          3_002_000_000,
+			RentExemptReserve: 2_000_000,
+			Voter:             testVoter,
+			Stake:             2_000_000_000,
+			ActivationEpoch:   activation,
+			DeactivationEpoch: deactivation,
+		}
+	}
+
+	tests := []struct {
+		name                                       string
+		account                                    rpc.StakeAccount
+		active, activating, deactivating, inactive float64
+	}{
+		{"active", delegated(90, math.MaxUint64), 2, 0, 0, 1},
+		{"activating", delegated(100, math.MaxUint64), 0, 2, 0, 1},
+		{"deactivating", delegated(90, 100), 0, 0, 2, 1},
+		{"deactivated", delegated(90, 95), 0, 0, 0, 3},
+		{"bootstrap", delegated(math.MaxUint64, math.MaxUint64), 2, 0, 0, 1},
+		{"undelegated", rpc.StakeAccount{Lamports: 1_002_000_000, RentExemptReserve: 2_000_000}, 0, 0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := data.ClassifyStake(tt.account, 100)
+			assert.Equal(t, tt.active, result.Active)
+			assert.Equal(t, tt.activating, result.Activating)
+			assert.Equal(t, tt.deactivating, result.Deactivating)
+			assert.Equal(t, tt.inactive, result.Inactive)
+			assert.Equal(t, 0.002, result.Rent)
+		})
+	}
+}
+
+func TestBalanceIncludeStaked(t *testing.T) {
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(1.5, nil)
+	balanceRPC.On("GetBalance", testOtherWallet).Return(2.0, nil)
+
+	stakeRPC := &MockStakeRPC{}
+	stakeRPC.On("GetStakeAccounts", testWallet).Return([]rpc.StakeAccount{{
+		Address:           "stake-1",
+		Lamports:          10_002_000_000,
+		RentExemptReserve: 2_000_000,
+		Voter:             testVoter,
+		Stake:             10_000_000_000,
+		ActivationEpoch:   10,
+		DeactivationEpoch: math.MaxUint64,
+	}}, nil)
+	stakeRPC.On("GetStakeAccounts", testOtherWallet).Return([]rpc.StakeAccount(nil), errors.New("rpc unavailable"))
+	stakeRPC.On("GetEpoch").Return(uint64(100), nil)
+
+	balanceHandler := handlers.NewBalanceHandler(data.NewBalanceService(balanceRPC, cache))
+	balanceHandler.SetStakeService(data.NewStakeService(stakeRPC, cache))
+
+	post := func(request models.BalanceRequest) []models.WalletBalance {
+		body, _ := json.Marshal(request)
+		rr := httptest.NewRecorder()
+		balanceHandler.GetBalanceHandler(rr, httptest.NewRequest("POST", "/api/get-balance", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data []models.WalletBalance `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	balances := post(models.BalanceRequest{Wallets: []string{testWallet, testOtherWallet}, IncludeStaked: true})
+	require.Len(t, balances, 2)
+
+	staked := balances[0].Staked
+	require.NotNil(t, staked)
+	require.Len(t, staked.Accounts, 1)
+	assert.Equal(t, 10.0, staked.Accounts[0].Active)
+	assert.Equal(t, testVoter, staked.Accounts[0].Voter)
+	assert.Equal(t, 10.002, staked.Staked)
+	assert.InDelta(t, 11.502, staked.Total, 1e-9)
+
+	// The stake lookup failed but the balance is still reported
+	assert.Equal(t, 2.0, balances[1].Balance)
+	require.NotNil(t, balances[1].Staked)
+	assert.Equal(t, "rpc unavailable", balances[1].Staked.Error)
+
+	// Stake accounts are cached and left out unless requested
+	balances = post(models.BalanceRequest{Wallets: []string{testWallet}, IncludeStaked: true})
+	assert.Equal(t, 10.002, balances[0].Staked.Staked)
+	stakeRPC.AssertNumberOfCalls(t, "GetStakeAccounts", 2)
+
+	balances = post(models.BalanceRequest{Wallets: []string{testWallet}})
+	assert.Nil(t, balances[0].Staked)
+}
+
+// slowStakeRPC records how many lookups ran at the same time
+type slowStakeRPC struct {
+	mutex   sync.Mutex
+	running int
+	peak    int
+	calls   int
+}
+
+func (f *slowStakeRPC) GetStakeAccounts(walletAddress string) ([]rpc.StakeAccount, error) {
+	f.mutex.Lock()
+	f.calls++
+	f.running++
+	f.peak = max(f.peak, f.running)
+	f.mutex.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	f.mutex.Lock()
+	f.running--
+	f.mutex.Unlock()
+	return []rpc.StakeAccount{}, nil
+}
+
+func (f *slowStakeRPC) GetEpoch() (uint64, error) {
+	return 100, nil
+}
+
+func TestStakeSummariesAreBudgeted(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.StakeCacheTTL = 60
+		c.StakeLookupConcurrency = 2
+		c.StakeMaxLookupsPerRequest = 3
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	fake := &slowStakeRPC{}
+	service := data.NewStakeService(fake, cache)
+	wallets := []string{"wallet-0", "wallet-1", "wallet-2", "wallet-3", "wallet-4"}
+	liquid := []float64{1, 1, 1, 1, 1}
+
+	summaries, errs := service.Summaries(wallets, liquid)
+	failed := 0
+	for i := range wallets {
+		if errs[i] != nil {
+			assert.ErrorIs(t, errs[i], data.ErrStakeLookupBudget)
+			assert.Nil(t, summaries[i])
+			failed++
+			continue
+		}
+		assert.Equal(t, 1.0, summaries[i].Total)
+	}
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, 3, fake.calls)
+	assert.LessOrEqual(t, fake.peak, 2)
+
+	// Cached wallets do not count against the budget of the next request
+	_, errs = service.Summaries(wallets, liquid)
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 5, fake.calls)
+}
+
+func TestStakeLookupFailuresAreCached(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.StakeErrorCacheTTL = 10 })
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	stakeRPC := &MockStakeRPC{}
+	stakeRPC.On("GetStakeAccounts", testWallet).Return([]rpc.StakeAccount(nil), errors.New("rpc unavailable")).Once()
+	service := data.NewStakeService(stakeRPC, cache)
+
+	for i := 0; i < 2; i++ {
+		_, err := service.Summary(testWallet, 1)
+		assert.EqualError(t, err, "rpc unavailable")
+	}
+	stakeRPC.AssertExpectations(t)
+}
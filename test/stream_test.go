@@ -0,0 +1,429 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/middleware"
+	"nova-api/models"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type streamTestServer struct {
+	server  *httptest.Server
+	feed    *data.BalanceFeed
+	service *data.BalanceService
+}
+
+func newStreamTestServer(t *testing.T) *streamTestServer {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", mock.Anything).Return(1.5, nil).Maybe()
+	return newStreamTestServerWithRPC(t, mockRPC)
+}
+
+func newStreamTestServerWithRPC(t *testing.T, mockRPC *MockBalanceRPC) *streamTestServer {
+	mockAuth := &MockAPIKeyValidator{}
+	mockAuth.On("ValidateAPIKey", "valid-key").Return(&models.APIKey{ID: "valid-key"}, nil).Maybe()
+	mockAuth.On("ValidateAPIKey", mock.Anything).Return(nil, assert.AnError).Maybe()
+
+	service := data.NewBalanceService(mockRPC, data.NoopBalanceCache{})
+	feed := data.NewBalanceFeed(service, nil)
+	streamHandler := handlers.NewStreamHandler(feed, service)
+
+	router := mux.NewRouter()
+	streams := router.PathPrefix("/api").Subrouter()
+	streams.Use(middleware.StreamAPIKeyAuth(mockAuth))
+	streams.HandleFunc("/ws", streamHandler.WebSocketHandler).Methods("GET")
+	streams.HandleFunc("/balances/stream", streamHandler.EventStreamHandler).Methods("GET")
+
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middleware.APIKeyAuth(mockAuth))
+	api.HandleFunc("/get-balance", (&MockBalanceHandler{}).GetBalanceHandler).Methods("POST")
+
+	s := &streamTestServer{server: httptest.NewServer(router), feed: feed, service: service}
+	t.Cleanup(func() {
+		s.server.Close()
+		feed.Close()
+	})
+	return s
+}
+
+func (s *streamTestServer) dial(t *testing.T, token string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(s.server.URL, "http") + "/api/ws?token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readStreamMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&message))
+	return message
+}
+
+func TestWebSocketRequiresAuthentication(t *testing.T) {
+	s := newStreamTestServer(t)
+
+	url := "ws" + strings.TrimPrefix(s.server.URL, "http") + "/api/ws?token=invalid-key"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestQueryTokenOnlyAcceptedOnStreamRoutes(t *testing.T) {
+	s := newStreamTestServer(t)
+
+	resp, err := http.Post(s.server.URL+"/api/get-balance?token=valid-key", "application/json", strings.NewReader(`{"wallets": []}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = http.Get(s.server.URL + "/api/balances/stream?token=invalid-key&wallets=" + testWallet)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	s.dial(t, "valid-key")
+}
+
+// withAllowedOrigins sets CORSAllowedOrigins for the test. Unlike withConfig it
+// restores only that field, since handlers of earlier stream tests can still
+// be reading the rest of the config when the test ends.
+func withAllowedOrigins(t *testing.T, origins ...string) {
+	original := config.AppConfig.CORSAllowedOrigins
+	t.Cleanup(func() { config.AppConfig.CORSAllowedOrigins = original })
+	config.AppConfig.CORSAllowedOrigins = origins
+}
+
+func TestWebSocketChecksAllowedOrigins(t *testing.T) {
+	withAllowedOrigins(t, "https://app.example.com")
+	s := newStreamTestServer(t)
+	url := "ws" + strings.TrimPrefix(s.server.URL, "http") + "/api/ws?token=valid-key"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	assert.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Accepted connections are checked without a server, whose handlers would
+	// still read the config while it is restored
+	assert.True(t, middleware.OriginAllowed("https://APP.example.com"))
+	assert.True(t, middleware.OriginAllowed(""), "clients other than browsers send no origin")
+	assert.False(t, middleware.OriginAllowed("https://app.example.com.evil.com"))
+}
+
+func TestCORSUsesAllowedOrigins(t *testing.T) {
+	withAllowedOrigins(t, "https://app.example.com")
+	handler := middleware.CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for origin, expected := range map[string]string{
+		"https://app.example.com":  "https://app.example.com",
+		"https://evil.example.com": "",
+		"":                         "",
+	} {
+		req := httptest.NewRequest("POST", "/api/get-balance", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, expected, rr.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+
+	withAllowedOrigins(t)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/get-balance", nil))
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestWebSocketStreamsBalanceChanges(t *testing.T) {
+	s := newStreamTestServer(t)
+	conn := s.dial(t, "valid-key")
+
+	require.NoError(t, conn.WriteJSON(models.StreamRequest{Action: "subscribe", Wallets: []string{testWallet}}))
+
+	subscribed := readStreamMessage(t, conn)
+	assert.Equal(t, "subscribed", subscribed["type"])
+	assert.Equal(t, []interface{}{testWallet}, subscribed["data"])
+
+	initial := readStreamMessage(t, conn)
+	assert.Equal(t, "balance", initial["type"])
+	assert.Equal(t, 1.5, initial["data"].(map[string]interface{})["balance"])
+
+	s.feed.Publish(testWallet, 1.5)
+	s.feed.Publish(testWallet, 2.0)
+	changed := readStreamMessage(t, conn)
+	assert.Equal(t, "balance", changed["type"])
+	assert.Equal(t, 2.0, changed["data"].(map[string]interface{})["balance"])
+
+	require.NoError(t, conn.WriteJSON(models.StreamRequest{Action: "unsubscribe", Wallets: []string{testWallet}}))
+	unsubscribed := readStreamMessage(t, conn)
+	assert.Equal(t, "unsubscribed", unsubscribed["type"])
+	assert.Eventually(t, func() bool { return s.feed.WatchedWallets() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketSharesUpstreamWatches(t *testing.T) {
+	s := newStreamTestServer(t)
+	first := s.dial(t, "valid-key")
+	second := s.dial(t, "valid-key")
+
+	for _, conn := range []*websocket.Conn{first, second} {
+		require.NoError(t, conn.WriteJSON(models.StreamRequest{Action: "subscribe", Wallets: []string{testWallet}}))
+		readStreamMessage(t, conn)
+		readStreamMessage(t, conn)
+	}
+	assert.Equal(t, 1, s.feed.WatchedWallets())
+
+	s.feed.Publish(testWallet, 3.0)
+	for _, conn := range []*websocket.Conn{first, second} {
+		message := readStreamMessage(t, conn)
+		assert.Equal(t, 3.0, message["data"].(map[string]interface{})["balance"])
+	}
+
+	// The wallet stays watched until the last client leaves
+	first.Close()
+	second.Close()
+	assert.Eventually(t, func() bool { return s.feed.WatchedWallets() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketEnforcesPerKeyLimit(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.StreamMaxWalletsPerKey = 1 })
+
+	s := newStreamTestServer(t)
+	first := s.dial(t, "valid-key")
+	second := s.dial(t, "valid-key")
+
+	require.NoError(t, first.WriteJSON(models.StreamRequest{Action: "subscribe", Wallets: []string{testWallet}}))
+	assert.Equal(t, "subscribed", readStreamMessage(t, first)["type"])
+	readStreamMessage(t, first)
+
+	require.NoError(t, second.WriteJSON(models.StreamRequest{Action: "subscribe", Wallets: []string{testOtherWallet}}))
+	rejected := readStreamMessage(t, second)
+	assert.Equal(t, "error", rejected["type"])
+	assert.Contains(t, rejected["error"], "Maximum 1 wallets")
+
+	require.NoError(t, second.WriteJSON(models.StreamRequest{Action: "subscribe", Wallets: []string{"not-a-wallet"}}))
+	assert.Equal(t, "error", readStreamMessage(t, second)["type"])
+}
+
+func TestFeedSubscriptionDropsOldestWhenFull(t *testing.T) {
+	service := data.NewBalanceService(&MockBalanceRPC{}, data.NoopBalanceCache{})
+	feed := data.NewBalanceFeed(service, nil)
+	defer feed.Close()
+
+	sub := feed.Subscribe(2)
+	defer sub.Close()
+	require.NoError(t, sub.Add(testWallet))
+
+	for _, balance := range []float64{1, 2, 3, 4} {
+		feed.Publish(testWallet, balance)
+	}
+
+	assert.Equal(t, 3.0, (<-sub.Updates()).Balance)
+	assert.Equal(t, 4.0, (<-sub.Updates()).Balance)
+}
+
+func TestFeedPollIsCappedPerTick(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.StreamPollInterval = 1
+		c.StreamPollMaxWallets = 2
+	})
+
+	var mutex sync.Mutex
+	polled := make(map[string]int)
+	calls := func() (total int, wallets int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, count := range polled {
+			total += count
+		}
+		return total, len(polled)
+	}
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", mock.Anything).Run(func(args mock.Arguments) {
+		mutex.Lock()
+		polled[args.String(0)]++
+		mutex.Unlock()
+	}).Return(1.0, nil)
+
+	feed := data.NewBalanceFeed(data.NewBalanceService(mockRPC, data.NoopBalanceCache{}), nil)
+	defer feed.Close()
+	sub := feed.Subscribe(8)
+	defer sub.Close()
+	require.NoError(t, sub.Add(testWallet, testOtherWallet, testMint))
+
+	require.Eventually(t, func() bool { total, _ := calls(); return total == 2 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	total, _ := calls()
+	assert.Equal(t, 2, total, "only two wallets are read per tick")
+
+	// The wallet left out is read first on the next tick
+	assert.Eventually(t, func() bool { _, wallets := calls(); return wallets == 3 }, 2*time.Second, 10*time.Millisecond)
+}
+
+type sseEvent struct {
+	ID     string
+	Update models.BalanceUpdate
+}
+
+// openEventStream starts a Server-Sent Events request and returns a function reading the next balance event.
+func (s *streamTestServer) openEventStream(t *testing.T, wallets, lastEventID string) (*http.Response, func() sseEvent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.server.URL+"/api/balances/stream?wallets="+wallets, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Token", "valid-key")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Update)
+			case line == "" && event.ID != "":
+				events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+
+	next := func() sseEvent {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "event stream closed")
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return sseEvent{}
+		}
+	}
+	return resp, next
+}
+
+func TestEventStreamSendsInitialBalancesAndChanges(t *testing.T) {
+	s := newStreamTestServer(t)
+
+	resp, next := s.openEventStream(t, testWallet, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	initial := next()
+	assert.Equal(t, testWallet, initial.Update.Wallet)
+	assert.Equal(t, 1.5, initial.Update.Balance)
+
+	s.feed.Publish(testWallet, 1.5)
+	s.feed.Publish(testWallet, 2.0)
+	changed := next()
+	assert.Equal(t, 2.0, changed.Update.Balance)
+	assert.Equal(t, "1", changed.ID)
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	s := newStreamTestServer(t)
+
+	s.feed.Seed(testWallet, 1.0)
+	sub := s.feed.Subscribe(4)
+	require.NoError(t, sub.Add(testWallet, testOtherWallet))
+	s.feed.Publish(testWallet, 2.0)
+	s.feed.Publish(testOtherWallet, 5.0)
+	s.feed.Publish(testWallet, 3.0)
+
+	// The wallet stays watched by another client, so nothing was missed
+	_, next := s.openEventStream(t, testWallet, "1")
+
+	// Only the missed change of the requested wallet is replayed, without a fresh snapshot
+	missed := next()
+	assert.Equal(t, "3", missed.ID)
+	assert.Equal(t, 3.0, missed.Update.Balance)
+
+	s.feed.Publish(testWallet, 4.0)
+	assert.Equal(t, 4.0, next().Update.Balance)
+	sub.Close()
+}
+
+func TestEventStreamSendsSnapshotWhenWalletWasUnwatched(t *testing.T) {
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", testWallet).Return(1.5, nil).Once()
+	mockRPC.On("GetBalance", testWallet).Return(2.5, nil)
+	s := newStreamTestServerWithRPC(t, mockRPC)
+
+	resp, next := s.openEventStream(t, testWallet, "")
+	initial := next()
+	assert.Equal(t, 1.5, initial.Update.Balance)
+
+	// The client goes away, nobody watches the wallet while its balance changes
+	resp.Body.Close()
+	require.Eventually(t, func() bool { return s.feed.WatchedWallets() == 0 }, 2*time.Second, 10*time.Millisecond)
+
+	// Resuming cannot replay the change, it was never published
+	_, next = s.openEventStream(t, testWallet, initial.ID)
+	resumed := next()
+	assert.Equal(t, 2.5, resumed.Update.Balance)
+}
+
+func TestEventStreamFallsBackToSnapshotWhenReplayIsGone(t *testing.T) {
+	withConfig(t, func(c *config.Config) { c.StreamReplayBufferSize = 1 })
+
+	s := newStreamTestServer(t)
+	sub := s.feed.Subscribe(4)
+	require.NoError(t, sub.Add(testWallet))
+	s.feed.Publish(testWallet, 2.0)
+	s.feed.Publish(testWallet, 3.0)
+	s.feed.Publish(testWallet, 4.0)
+	sub.Close()
+
+	_, next := s.openEventStream(t, testWallet, "1")
+	snapshot := next()
+	assert.Equal(t, "3", snapshot.ID)
+	assert.Equal(t, 1.5, snapshot.Update.Balance)
+}
+
+func TestEventStreamRejectsBadRequests(t *testing.T) {
+	s := newStreamTestServer(t)
+
+	resp, err := http.Get(s.server.URL + "/api/balances/stream?wallets=" + testWallet)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	for _, query := range []string{"", "not-a-wallet"} {
+		req, _ := http.NewRequest(http.MethodGet, s.server.URL+"/api/balances/stream?wallets="+query, nil)
+		req.Header.Set("X-Token", "valid-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
@@ -0,0 +1,398 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-api/data"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// fakeSolanaWS is a minimal Solana PubSub server that answers accountSubscribe
+// and accountUnsubscribe and lets tests push accountNotification messages.
+type fakeSolanaWS struct {
+	server *httptest.Server
+
+	mutex         sync.Mutex
+	conns         map[*websocket.Conn]bool
+	subscriptions map[string]fakeSubscription
+	unsubscribed  []uint64
+	nextSubID     uint64
+	commitments   []string
+	// holds delays the answer to accountSubscribe for a wallet until the channel is closed
+	holds map[string]chan struct{}
+}
+
+type fakeSubscription struct {
+	conn  *websocket.Conn
+	subID uint64
+}
+
+type fakeRPCRequest struct {
+	ID     json.Number       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func newFakeSolanaWS() *fakeSolanaWS {
+	fake := &fakeSolanaWS{
+		conns:         make(map[*websocket.Conn]bool),
+		subscriptions: make(map[string]fakeSubscription),
+		holds:         make(map[string]chan struct{}),
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	return fake
+}
+
+func (f *fakeSolanaWS) URL() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http")
+}
+
+func (f *fakeSolanaWS) Close() {
+	f.server.Close()
+}
+
+func (f *fakeSolanaWS) handle(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	f.mutex.Lock()
+	f.conns[conn] = true
+	f.mutex.Unlock()
+
+	defer func() {
+		f.mutex.Lock()
+		delete(f.conns, conn)
+		for wallet, sub := range f.subscriptions {
+			if sub.conn == conn {
+				delete(f.subscriptions, wallet)
+			}
+		}
+		f.mutex.Unlock()
+	}()
+
+	for {
+		var request fakeRPCRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+
+		if request.Method == "accountSubscribe" {
+			var wallet string
+			json.Unmarshal(request.Params[0], &wallet)
+			f.mutex.Lock()
+			hold := f.holds[wallet]
+			f.mutex.Unlock()
+			if hold != nil {
+				<-hold
+			}
+		}
+
+		f.mutex.Lock()
+		var result interface{}
+		switch request.Method {
+		case "accountSubscribe":
+			var wallet string
+			json.Unmarshal(request.Params[0], &wallet)
+			var options struct {
+				Commitment string `json:"commitment"`
+			}
+			if len(request.Params) > 1 {
+				json.Unmarshal(request.Params[1], &options)
+			}
+			f.commitments = append(f.commitments, options.Commitment)
+			f.nextSubID++
+			f.subscriptions[wallet] = fakeSubscription{conn: conn, subID: f.nextSubID}
+			result = f.nextSubID
+		case "accountUnsubscribe":
+			var subID uint64
+			json.Unmarshal(request.Params[0], &subID)
+			f.unsubscribed = append(f.unsubscribed, subID)
+			result = true
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"jsonrpc":"2.0","result":%v,"id":%s}`, result, request.ID)))
+		f.mutex.Unlock()
+	}
+}
+
+// Hold delays accountSubscribe for wallet until the returned function is called.
+func (f *fakeSolanaWS) Hold(wallet string) func() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	hold := make(chan struct{})
+	f.holds[wallet] = hold
+	return func() { close(hold) }
+}
+
+// Commitments returns the commitment of every accountSubscribe received.
+func (f *fakeSolanaWS) Commitments() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.commitments...)
+}
+
+func (f *fakeSolanaWS) IsSubscribed(wallet string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, exists := f.subscriptions[wallet]
+	return exists
+}
+
+// SubscribeCount returns how many accountSubscribe requests were received over all connections.
+func (f *fakeSolanaWS) SubscribeCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return int(f.nextSubID)
+}
+
+func (f *fakeSolanaWS) UnsubscribeCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return len(f.unsubscribed)
+}
+
+func (f *fakeSolanaWS) Notify(wallet string, lamports uint64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	sub := f.subscriptions[wallet]
+	message := fmt.Sprintf(`{"jsonrpc":"2.0","method":"accountNotification","params":{"result":{"context":{"slot":1234},"value":{"lamports":%d,"data":["","base64"],"owner":"11111111111111111111111111111111","executable":false,"rentEpoch":0}},"subscription":%d}}`, lamports, sub.subID)
+	sub.conn.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+// DropConnections closes every open connection as if the node went away.
+func (f *fakeSolanaWS) DropConnections() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+// fakeSubscriptionRPC answers the balance reads that follow every subscribe.
+type fakeSubscriptionRPC struct {
+	mutex    sync.Mutex
+	balances map[string]float64
+	slot     uint64
+	reads    map[string]int
+}
+
+func newFakeSubscriptionRPC() *fakeSubscriptionRPC {
+	return &fakeSubscriptionRPC{balances: make(map[string]float64), reads: make(map[string]int)}
+}
+
+// Set makes reads of wallet return balance at slot.
+func (f *fakeSubscriptionRPC) Set(wallet string, balance float64, slot uint64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.balances[wallet] = balance
+	f.slot = slot
+}
+
+func (f *fakeSubscriptionRPC) Reads(wallet string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.reads[wallet]
+}
+
+func (f *fakeSubscriptionRPC) GetBalanceAndSlot(walletAddress string) (float64, uint64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.reads[walletAddress]++
+	return f.balances[walletAddress], f.slot, nil
+}
+
+const (
+	testWallet      = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	testOtherWallet = "So11111111111111111111111111111111111111112"
+)
+
+func TestSubscriptionManagerCachesPushedBalances(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	manager := data.NewSubscriptionManager(fake.URL(), newFakeSubscriptionRPC(), cache)
+	defer manager.Close()
+
+	assert.NoError(t, manager.Subscribe(testWallet))
+	assert.Eventually(t, func() bool {
+		return fake.IsSubscribed(testWallet) && manager.IsActive(testWallet)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	fake.Notify(testWallet, 2_500_000_000)
+	assert.Eventually(t, func() bool {
+		balance, found, _ := cache.GetBalance(testWallet)
+		return found && balance == 2.5
+	}, 2*time.Second, 10*time.Millisecond)
+
+	manager.Unsubscribe(testWallet)
+	assert.False(t, manager.IsActive(testWallet))
+	assert.Eventually(t, func() bool {
+		return fake.UnsubscribeCount() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscriptionManagerRejectsInvalidWallets(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	manager := data.NewSubscriptionManager(fake.URL(), newFakeSubscriptionRPC(), data.NoopBalanceCache{})
+	defer manager.Close()
+
+	assert.Error(t, manager.Subscribe("not-a-wallet"))
+	assert.Empty(t, manager.Wallets())
+}
+
+func TestSubscriptionManagerResubscribesAfterDisconnect(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	manager := data.NewSubscriptionManager(fake.URL(), newFakeSubscriptionRPC(), cache)
+	defer manager.Close()
+
+	manager.Sync([]string{testWallet, testOtherWallet})
+	assert.Eventually(t, func() bool {
+		return fake.IsSubscribed(testWallet) && fake.IsSubscribed(testOtherWallet)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	fake.DropConnections()
+	assert.Eventually(t, func() bool {
+		return fake.SubscribeCount() == 4 && fake.IsSubscribed(testWallet) && fake.IsSubscribed(testOtherWallet) &&
+			manager.IsActive(testWallet) && manager.IsActive(testOtherWallet)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	fake.Notify(testOtherWallet, 1_000_000_000)
+	assert.Eventually(t, func() bool {
+		balance, found, _ := cache.GetBalance(testOtherWallet)
+		return found && balance == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	manager.Sync([]string{testWallet})
+	assert.Equal(t, []string{testWallet}, manager.Wallets())
+}
+
+func TestSubscriptionManagerHandshakeDoesNotBlockOtherCalls(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	manager := data.NewSubscriptionManager(fake.URL(), newFakeSubscriptionRPC(), data.NoopBalanceCache{})
+	defer manager.Close()
+
+	assert.NoError(t, manager.Subscribe(testOtherWallet))
+	assert.Eventually(t, func() bool { return manager.IsActive(testOtherWallet) }, 2*time.Second, 10*time.Millisecond)
+
+	release := fake.Hold(testWallet)
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		manager.Subscribe(testWallet)
+	}()
+
+	// While the node sits on the handshake the manager keeps answering
+	answered := make(chan struct{})
+	go func() {
+		defer close(answered)
+		manager.IsActive(testOtherWallet)
+		manager.Unsubscribe(testOtherWallet)
+		manager.Wallets()
+	}()
+	select {
+	case <-answered:
+	case <-time.After(time.Second):
+		t.Fatal("manager blocked behind a pending subscription")
+	}
+
+	release()
+	<-subscribed
+	assert.Eventually(t, func() bool { return manager.IsActive(testWallet) }, 2*time.Second, 10*time.Millisecond)
+
+	// Pushed balances are read at the same commitment as polled ones
+	for _, commitment := range fake.Commitments() {
+		assert.Equal(t, "finalized", commitment)
+	}
+}
+
+func TestSubscriptionManagerResyncsBalanceAfterResubscribe(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	balances := newFakeSubscriptionRPC()
+	balances.Set(testWallet, 1.5, 1000)
+
+	manager := data.NewSubscriptionManager(fake.URL(), balances, cache)
+	defer manager.Close()
+
+	var mutex sync.Mutex
+	var published []float64
+	manager.OnUpdate(func(wallet string, balance float64) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		published = append(published, balance)
+	})
+
+	// The balance is read as soon as the subscription is open
+	assert.NoError(t, manager.Subscribe(testWallet))
+	assert.Eventually(t, func() bool {
+		balance, found, _ := cache.GetBalance(testWallet)
+		return found && balance == 1.5
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A change made while disconnected is picked up after resubscribing
+	balances.Set(testWallet, 4, 2000)
+	fake.DropConnections()
+	assert.Eventually(t, func() bool {
+		balance, found, _ := cache.GetBalance(testWallet)
+		return found && balance == 4 && balances.Reads(testWallet) == 2 && fake.IsSubscribed(testWallet)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Pushes older than the balance read are dropped
+	fake.Notify(testWallet, 3_000_000_000)
+	time.Sleep(100 * time.Millisecond)
+	balance, _, _ := cache.GetBalance(testWallet)
+	assert.Equal(t, 4.0, balance)
+
+	mutex.Lock()
+	assert.Equal(t, []float64{1.5, 4}, published)
+	mutex.Unlock()
+}
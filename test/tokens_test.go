@@ -0,0 +1,365 @@
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testBonkMint = "DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263"
+
+type MockTokenRPC struct {
+	mock.Mock
+	batchMutex sync.Mutex
+	batches    [][]string
+}
+
+func (m *MockTokenRPC) GetTokenAccounts(walletAddress string) ([]rpc.TokenHolding, error) {
+	args := m.Called(walletAddress)
+	holdings, _ := args.Get(0).([]rpc.TokenHolding)
+	return holdings, args.Error(1)
+}
+
+// GetTokenMetadatas records the batch and answers each mint from the GetTokenMetadata expectations
+func (m *MockTokenRPC) GetTokenMetadatas(mints []string) ([]*rpc.TokenMetadata, []error) {
+	m.batchMutex.Lock()
+	m.batches = append(m.batches, append([]string{}, mints...))
+	m.batchMutex.Unlock()
+
+	metadatas := make([]*rpc.TokenMetadata, len(mints))
+	errs := make([]error, len(mints))
+	for i, mint := range mints {
+		args := m.MethodCalled("GetTokenMetadata", mint)
+		metadatas[i], _ = args.Get(0).(*rpc.TokenMetadata)
+		errs[i] = args.Error(1)
+	}
+	return metadatas, errs
+}
+
+func (m *MockTokenRPC) metadataBatches() [][]string {
+	m.batchMutex.Lock()
+	defer m.batchMutex.Unlock()
+	return m.batches
+}
+
+// borshStrings encodes values as borsh strings, the first one padded with NUL bytes like Metaplex does
+func borshStrings(values ...string) []byte {
+	var out []byte
+	for i, value := range values {
+		encoded := []byte(value)
+		if i == 0 {
+			encoded = append(encoded, make([]byte, 32-len(value))...)
+		}
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(encoded)))
+		out = append(out, encoded...)
+	}
+	return out
+}
+
+func withTokenConfig(t *testing.T, tokenListPath string) {
+	withConfig(t, func(c *config.Config) {
+		c.TokenListPath = tokenListPath
+		c.TokenMetadataCacheTTL = 60
+		c.TokenMetadataErrorCacheTTL = 60
+		c.BalanceCacheTTL = 30
+	})
+}
+
+func TestDecodeMetaplexMetadata(t *testing.T) {
+	account := append([]byte{4}, make([]byte, 64)...)
+	account = append(account, borshStrings("USD Coin", "USDC", "https://example.com/usdc.json")...)
+	account = append(account, 0xff, 0x01)
+
+	name, symbol, uri, err := rpc.DecodeMetaplexMetadata(account)
+	require.NoError(t, err)
+	assert.Equal(t, "USD Coin", name)
+	assert.Equal(t, "USDC", symbol)
+	assert.Equal(t, "https://example.com/usdc.json", uri)
+
+	_, _, _, err = rpc.DecodeMetaplexMetadata(account[:80])
+	assert.Error(t, err)
+	account[0] = 6
+	_, _, _, err = rpc.DecodeMetaplexMetadata(account)
+	assert.Error(t, err)
+}
+
+func TestDecodeToken2022Metadata(t *testing.T) {
+	value := append(make([]byte, 64), borshStrings("Paypal USD", "PYUSD", "https://example.com/pyusd.json")...)
+
+	mint := make([]byte, 165)
+	mint = append(mint, 1)
+	// A metadata pointer extension comes before the metadata itself
+	mint = binary.LittleEndian.AppendUint16(mint, 18)
+	mint = binary.LittleEndian.AppendUint16(mint, 64)
+	mint = append(mint, make([]byte, 64)...)
+	mint = binary.LittleEndian.AppendUint16(mint, 19)
+	mint = binary.LittleEndian.AppendUint16(mint, uint16(len(value)))
+	mint = append(mint, value...)
+
+	name, symbol, uri, found := rpc.DecodeToken2022Metadata(mint)
+	require.True(t, found)
+	assert.Equal(t, "Paypal USD", name)
+	assert.Equal(t, "PYUSD", symbol)
+	assert.Equal(t, "https://example.com/pyusd.json", uri)
+
+	_, _, _, found = rpc.DecodeToken2022Metadata(mint[:len(mint)-5])
+	assert.False(t, found)
+	_, _, _, found = rpc.DecodeToken2022Metadata(make([]byte, 82))
+	assert.False(t, found)
+}
+
+func TestTokenMetadataWithTokenList(t *testing.T) {
+	list := `{"tokens": [
+		{"address": "` + testMint + `", "name": "USD Coin", "symbol": "USDC", "decimals": 6, "logoURI": "https://example.com/usdc.png"},
+		{"address": "` + testBonkMint + `", "name": "Bonk", "symbol": "Bonk", "decimals": 5}
+	]}`
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(list), 0o600))
+	withTokenConfig(t, path)
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockTokenRPC{}
+	mockRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{
+		Mint: testMint, Decimals: 6, Name: "USDC", Symbol: "USDC", URI: "https://example.com/usdc.json",
+	}, nil).Once()
+	mockRPC.On("GetTokenMetadata", testBonkMint).Return(nil, errors.New("rpc unavailable"))
+	mockRPC.On("GetTokenMetadata", testOtherWallet).Return(nil, errors.New("rpc unavailable"))
+	service := data.NewTokenMetadataService(mockRPC, cache)
+
+	for i := 0; i < 2; i++ {
+		metadata, err := service.Metadata(testMint)
+		require.NoError(t, err)
+		assert.Equal(t, &models.TokenMetadata{
+			Mint:        testMint,
+			Name:        "USD Coin",
+			Symbol:      "USDC",
+			Decimals:    6,
+			LogoURI:     "https://example.com/usdc.png",
+			MetadataURI: "https://example.com/usdc.json",
+		}, metadata)
+	}
+
+	// A listed mint is still described when the chain cannot be read
+	metadata, err := service.Metadata(testBonkMint)
+	require.NoError(t, err)
+	assert.Equal(t, "Bonk", metadata.Symbol)
+	assert.Equal(t, uint8(5), metadata.Decimals)
+
+	_, err = service.Metadata(testOtherWallet)
+	assert.Error(t, err)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestTokenMetadataIgnoresBrokenTokenList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	withTokenConfig(t, path)
+	cache := data.NewMemoryBalanceCache(10)
+	defer cache.Close()
+
+	mockRPC := &MockTokenRPC{}
+	mockRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{Mint: testMint, Decimals: 6, Symbol: "USDC"}, nil)
+	metadata, err := data.NewTokenMetadataService(mockRPC, cache).Metadata(testMint)
+	require.NoError(t, err)
+	assert.Equal(t, "USDC", metadata.Symbol)
+	assert.Empty(t, metadata.LogoURI)
+}
+
+func TestTokenHoldings(t *testing.T) {
+	withTokenConfig(t, "")
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockTokenRPC{}
+	mockRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{
+		{Account: "a1", Mint: testMint, Amount: 1_500_000, Decimals: 6},
+		{Account: "a2", Mint: testBonkMint, Amount: 0, Decimals: 5},
+		{Account: "a3", Mint: testMint, Amount: 500_000, Decimals: 6},
+		{Account: "a4", Mint: testOtherWallet, Amount: 5_000_000_000, Decimals: 9},
+	}, nil).Once()
+	mockRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{Mint: testMint, Decimals: 6, Symbol: "USDC"}, nil)
+	mockRPC.On("GetTokenMetadata", testOtherWallet).Return(nil, errors.New("rpc unavailable"))
+	service := data.NewTokenService(mockRPC, cache, data.NewTokenMetadataService(mockRPC, cache))
+
+	for i := 0; i < 2; i++ {
+		balances, err := service.Holdings(testWallet)
+		require.NoError(t, err)
+		require.Len(t, balances, 2)
+		assert.Equal(t, testOtherWallet, balances[0].Mint)
+		assert.Equal(t, 5.0, balances[0].Amount)
+		assert.Nil(t, balances[0].Token)
+		assert.Equal(t, testMint, balances[1].Mint)
+		assert.Equal(t, 2.0, balances[1].Amount)
+		require.NotNil(t, balances[1].Token)
+		assert.Equal(t, "USDC", balances[1].Token.Symbol)
+	}
+	mockRPC.AssertExpectations(t)
+}
+
+func TestTokenMetadataIsReadInBatches(t *testing.T) {
+	withTokenConfig(t, "")
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockRPC := &MockTokenRPC{}
+	mockRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{
+		{Mint: testMint, Amount: 1_000_000, Decimals: 6},
+		{Mint: testBonkMint, Amount: 100_000, Decimals: 5},
+		{Mint: testOtherWallet, Amount: 1, Decimals: 0},
+	}, nil)
+	mockRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{Mint: testMint, Decimals: 6, Symbol: "USDC"}, nil).Once()
+	mockRPC.On("GetTokenMetadata", testBonkMint).Return(&rpc.TokenMetadata{Mint: testBonkMint, Decimals: 5, Symbol: "Bonk"}, nil).Once()
+	mockRPC.On("GetTokenMetadata", testOtherWallet).Return(nil, errors.New("not a mint")).Once()
+	metadata := data.NewTokenMetadataService(mockRPC, cache)
+	service := data.NewTokenService(mockRPC, cache, metadata)
+
+	balances, err := service.Holdings(testWallet)
+	require.NoError(t, err)
+	require.Len(t, balances, 3)
+	batches := mockRPC.metadataBatches()
+	require.Len(t, batches, 1)
+	sort.Strings(batches[0])
+	assert.Equal(t, []string{testBonkMint, testMint, testOtherWallet}, batches[0])
+
+	// Described mints are cached and the failed one is remembered for a while
+	metadatas, errs := metadata.Describe([]string{testBonkMint, testOtherWallet, testMint, testBonkMint})
+	assert.Equal(t, "Bonk", metadatas[0].Symbol)
+	assert.EqualError(t, errs[1], "not a mint")
+	assert.Equal(t, "USDC", metadatas[2].Symbol)
+	assert.Equal(t, "Bonk", metadatas[3].Symbol)
+	assert.Len(t, mockRPC.metadataBatches(), 1)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestGetTokenMetadatasBatchesAccounts(t *testing.T) {
+	mintData := make([]byte, 82)
+	mintData[44] = 6
+	mintAccount := `{"data":["` + base64.StdEncoding.EncodeToString(mintData) + `","base64"],"executable":false,` +
+		`"lamports":1461600,"owner":"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA","rentEpoch":0,"space":82}`
+
+	var mutex sync.Mutex
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "getMultipleAccounts", request.Method)
+		var keys []string
+		require.NoError(t, json.Unmarshal(request.Params[0], &keys))
+		mutex.Lock()
+		batchSizes = append(batchSizes, len(keys))
+		mutex.Unlock()
+
+		// Mints exist, their Metaplex metadata accounts do not
+		values := make([]string, len(keys))
+		for i := range keys {
+			values[i] = "null"
+			if i%2 == 0 {
+				values[i] = mintAccount
+			}
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"context":{"slot":1},"value":[` + strings.Join(values, ",") + `]}}`))
+	}))
+	defer server.Close()
+
+	mints := []string{"nope"}
+	for i := 1; i <= 60; i++ {
+		key := make([]byte, 32)
+		key[0] = byte(i)
+		mints = append(mints, solana.PublicKeyFromBytes(key).String())
+	}
+
+	metadatas, errs := rpc.NewSolanaRPC(server.URL).GetTokenMetadatas(mints)
+	assert.Equal(t, []int{rpc.MaxAccountsPerCall, 20}, batchSizes)
+	assert.ErrorIs(t, errs[0], rpc.ErrInvalidAddress)
+	assert.Nil(t, metadatas[0])
+	for i := 1; i < len(mints); i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, &rpc.TokenMetadata{Mint: mints[i], Program: "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA", Decimals: 6}, metadatas[i])
+	}
+}
+
+func TestBalanceIncludeTokens(t *testing.T) {
+	withTokenConfig(t, "")
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(1.0, nil)
+	balanceRPC.On("GetBalance", testOtherWallet).Return(2.0, nil)
+	tokenRPC := &MockTokenRPC{}
+	tokenRPC.On("GetTokenAccounts", testWallet).Return([]rpc.TokenHolding{{Mint: testMint, Amount: 3_000_000, Decimals: 6}}, nil)
+	tokenRPC.On("GetTokenAccounts", testOtherWallet).Return(nil, errors.New("rpc unavailable"))
+	tokenRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{Mint: testMint, Decimals: 6, Symbol: "USDC"}, nil)
+
+	balanceHandler := handlers.NewBalanceHandler(data.NewBalanceService(balanceRPC, cache))
+	balanceHandler.SetTokenService(data.NewTokenService(tokenRPC, cache, data.NewTokenMetadataService(tokenRPC, cache)))
+
+	body := bytes.NewBufferString(`{"wallets": ["` + testWallet + `", "` + testOtherWallet + `"], "include_tokens": true}`)
+	rr := httptest.NewRecorder()
+	balanceHandler.GetBalanceHandler(rr, httptest.NewRequest("POST", "/api/get-balance", body))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Data []models.WalletBalance `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	require.Len(t, response.Data[0].Tokens, 1)
+	assert.Equal(t, 3.0, response.Data[0].Tokens[0].Amount)
+	assert.Equal(t, "USDC", response.Data[0].Tokens[0].Token.Symbol)
+	assert.Equal(t, 2.0, response.Data[1].Balance)
+	assert.Empty(t, response.Data[1].Tokens)
+	assert.Equal(t, "rpc unavailable", response.Data[1].TokensError)
+}
+
+func TestTransactionsDescribeTokens(t *testing.T) {
+	withTokenConfig(t, "")
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	tokenRPC := &MockTokenRPC{}
+	tokenRPC.On("GetTokenMetadata", testMint).Return(&rpc.TokenMetadata{Mint: testMint, Decimals: 6, Symbol: "USDC"}, nil)
+
+	transactionRPC := &fakeTransactionRPC{transactions: []*rpc.TransactionDetails{{
+		Signature: testSignature(1),
+		Accounts:  []string{testOtherWallet, testWallet},
+		TokenBalances: []rpc.TokenBalance{
+			{Owner: testWallet, Mint: testMint, Decimals: 6, Pre: 0, Post: 1_000_000},
+			{Owner: testOtherWallet, Mint: testMint, Decimals: 6, Pre: 1_000_000, Post: 0},
+		},
+	}}}
+
+	service := data.NewTransactionService(transactionRPC, cache)
+	service.SetTokenMetadata(data.NewTokenMetadataService(tokenRPC, cache))
+	page, err := service.Transactions(testWallet, "", 20)
+	require.NoError(t, err)
+	require.Len(t, page.Transactions[0].Transfers, 1)
+	transfer := page.Transactions[0].Transfers[0]
+	require.NotNil(t, transfer.Token)
+	assert.Equal(t, "USDC", transfer.Token.Symbol)
+}
@@ -0,0 +1,191 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+
+// fakeTransactionRPC serves a fixed list of transactions, newest first
+type fakeTransactionRPC struct {
+	transactions []*rpc.TransactionDetails
+
+	mutex   sync.Mutex
+	fetches int
+}
+
+func (f *fakeTransactionRPC) GetSignatures(walletAddress, before string, limit int) ([]rpc.SignatureInfo, error) {
+	start := 0
+	for i, tx := range f.transactions {
+		if tx.Signature == before {
+			start = i + 1
+		}
+	}
+
+	signatures := []rpc.SignatureInfo{}
+	for _, tx := range f.transactions[start:] {
+		if len(signatures) == limit {
+			break
+		}
+		signatures = append(signatures, rpc.SignatureInfo{Signature: tx.Signature, Slot: tx.Slot})
+	}
+	return signatures, nil
+}
+
+func (f *fakeTransactionRPC) GetTransactionDetails(signature string) (*rpc.TransactionDetails, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.fetches++
+	for _, tx := range f.transactions {
+		if tx.Signature == signature {
+			return tx, nil
+		}
+	}
+	return nil, rpc.ErrTransactionNotFound
+}
+
+func testSignature(n byte) string {
+	return solana.Signature{n}.String()
+}
+
+// solTransfer sends lamports from the fee payer to the second account
+func solTransfer(n byte, from, to string, lamports uint64) *rpc.TransactionDetails {
+	return &rpc.TransactionDetails{
+		Signature:    testSignature(n),
+		Slot:         uint64(n) * 10,
+		BlockTime:    1_700_000_000 + int64(n),
+		Fee:          5000,
+		Accounts:     []string{from, to},
+		PreBalances:  []uint64{10_000_000_000, 1_000_000_000},
+		PostBalances: []uint64{10_000_000_000 - lamports - 5000, 1_000_000_000 + lamports},
+	}
+}
+
+func TestNormalizeSOLTransfer(t *testing.T) {
+	tx := solTransfer(1, testWallet, testOtherWallet, 1_500_000_000)
+
+	sent := data.NormalizeTransaction(tx, testWallet)
+	assert.Equal(t, "success", sent.Status)
+	assert.Equal(t, 0.000005, sent.Fee)
+	require.Len(t, sent.Transfers, 1)
+	assert.Equal(t, models.Transfer{Type: "sol", Direction: "out", Counterparty: testOtherWallet, Amount: 1.5}, sent.Transfers[0])
+
+	received := data.NormalizeTransaction(tx, testOtherWallet)
+	require.Len(t, received.Transfers, 1)
+	assert.Equal(t, models.Transfer{Type: "sol", Direction: "in", Counterparty: testWallet, Amount: 1.5}, received.Transfers[0])
+}
+
+func TestNormalizeSPLTransferAndFailure(t *testing.T) {
+	tx := &rpc.TransactionDetails{
+		Signature:    testSignature(2),
+		Fee:          5000,
+		Accounts:     []string{testOtherWallet, testWallet, "ata-1", "ata-2"},
+		PreBalances:  []uint64{1_000_000, 1_000_000, 2_039_280, 2_039_280},
+		PostBalances: []uint64{995_000, 1_000_000, 2_039_280, 2_039_280},
+		TokenBalances: []rpc.TokenBalance{
+			{Account: "ata-1", Owner: testOtherWallet, Mint: testMint, Decimals: 6, Pre: 10_000_000, Post: 7_500_000},
+			{Account: "ata-2", Owner: testWallet, Mint: testMint, Decimals: 6, Pre: 0, Post: 2_500_000},
+		},
+	}
+
+	// The fee was paid by the sender, so the recipient only sees the token transfer
+	received := data.NormalizeTransaction(tx, testWallet)
+	require.Len(t, received.Transfers, 1)
+	assert.Equal(t, models.Transfer{Type: "spl", Mint: testMint, Decimals: 6, Direction: "in", Counterparty: testOtherWallet, Amount: 2.5}, received.Transfers[0])
+
+	failed := &rpc.TransactionDetails{
+		Signature:    testSignature(3),
+		Fee:          5000,
+		Failed:       true,
+		Accounts:     []string{testWallet, testOtherWallet},
+		PreBalances:  []uint64{1_000_000, 0},
+		PostBalances: []uint64{995_000, 0},
+	}
+	normalized := data.NormalizeTransaction(failed, testWallet)
+	assert.Equal(t, "failed", normalized.Status)
+	assert.Empty(t, normalized.Transfers)
+}
+
+func TestTransactionsPaginateAndCache(t *testing.T) {
+	fake := &fakeTransactionRPC{}
+	for n := byte(5); n >= 1; n-- {
+		fake.transactions = append(fake.transactions, solTransfer(n, testWallet, testOtherWallet, uint64(n)*1_000_000_000))
+	}
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+	service := data.NewTransactionService(fake, cache)
+
+	first, err := service.Transactions(testWallet, "", 3)
+	require.NoError(t, err)
+	require.Len(t, first.Transactions, 3)
+	assert.Equal(t, testSignature(5), first.Transactions[0].Signature)
+	require.NotEmpty(t, first.NextCursor)
+	assert.NotEqual(t, testSignature(3), first.NextCursor)
+
+	second, err := service.Transactions(testWallet, first.NextCursor, 3)
+	require.NoError(t, err)
+	require.Len(t, second.Transactions, 2)
+	assert.Equal(t, testSignature(2), second.Transactions[0].Signature)
+	assert.Equal(t, 1.0, second.Transactions[1].Transfers[0].Amount)
+	assert.Empty(t, second.NextCursor)
+	assert.Equal(t, 5, fake.fetches)
+
+	// Finalized transactions are served from the cache, also for the other side of the transfer
+	_, err = service.Transactions(testOtherWallet, "", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, fake.fetches)
+
+	_, err = service.Transactions(testWallet, "not-a-cursor", 3)
+	assert.ErrorIs(t, err, data.ErrInvalidCursor)
+}
+
+func TestTransactionsHandler(t *testing.T) {
+	fake := &fakeTransactionRPC{transactions: []*rpc.TransactionDetails{solTransfer(1, testWallet, testOtherWallet, 1_000_000_000)}}
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+	transactionHandler := handlers.NewTransactionHandler(data.NewTransactionService(fake, cache))
+
+	get := func(query string) (*httptest.ResponseRecorder, models.TransactionPage) {
+		req := httptest.NewRequest("GET", "/api/transactions?"+query, nil)
+		rr := httptest.NewRecorder()
+		transactionHandler.TransactionsHandler(rr, req)
+
+		var response struct {
+			Data models.TransactionPage `json:"data"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response.Data
+	}
+
+	rr, page := get("wallet=" + testWallet)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, page.Transactions, 1)
+	assert.Equal(t, "out", page.Transactions[0].Transfers[0].Direction)
+	assert.Empty(t, page.NextCursor)
+
+	invalid := []string{
+		"",
+		"wallet=not-a-wallet",
+		"wallet=" + testWallet + "&limit=0",
+		"wallet=" + testWallet + "&limit=101",
+		"wallet=" + testWallet + "&before=garbage",
+	}
+	for _, query := range invalid {
+		rr, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
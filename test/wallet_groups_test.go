@@ -0,0 +1,236 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/middleware"
+	"nova-api/models"
+	"nova-api/rpc"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryWalletGroupStore is an in-memory WalletGroupStore
+type memoryWalletGroupStore struct {
+	mutex  sync.Mutex
+	groups map[string]models.WalletGroup
+}
+
+func newMemoryWalletGroupStore() *memoryWalletGroupStore {
+	return &memoryWalletGroupStore{groups: make(map[string]models.WalletGroup)}
+}
+
+func (s *memoryWalletGroupStore) CreateWalletGroup(group *models.WalletGroup) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.groups[group.ID] = *group
+	return nil
+}
+
+func (s *memoryWalletGroupStore) ListWalletGroups(apiKey string) ([]models.WalletGroup, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	groups := []models.WalletGroup{}
+	for _, group := range s.groups {
+		if group.APIKey == apiKey {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+func (s *memoryWalletGroupStore) GetWalletGroup(apiKey, id string) (*models.WalletGroup, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	group, exists := s.groups[id]
+	if !exists || group.APIKey != apiKey {
+		return nil, data.ErrWalletGroupNotFound
+	}
+	return &group, nil
+}
+
+func (s *memoryWalletGroupStore) DeleteWalletGroup(apiKey, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if group, exists := s.groups[id]; !exists || group.APIKey != apiKey {
+		return data.ErrWalletGroupNotFound
+	}
+	delete(s.groups, id)
+	return nil
+}
+
+func TestWalletGroups(t *testing.T) {
+	verifyNoLeaks(t)
+	withConfig(t, func(c *config.Config) {
+		c.MaxWalletsPerRequest = 1
+		c.MaxWalletsPerGroup = 3
+		c.StakeCacheTTL = 0
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	mockAuth := &MockAPIKeyValidator{}
+	mockAuth.On("ValidateAPIKey", "valid-key").Return(&models.APIKey{ID: "valid-key"}, nil)
+	mockAuth.On("ValidateAPIKey", "other-key").Return(&models.APIKey{ID: "other-key"}, nil)
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", testWallet).Return(1.5, nil)
+	balanceRPC.On("GetBalance", testOtherWallet).Return(0.0, errors.New("rpc unavailable"))
+	stakeRPC := &MockStakeRPC{}
+	stakeRPC.On("GetStakeAccounts", mock.Anything).Return([]rpc.StakeAccount{}, nil)
+
+	groups := data.NewWalletGroupService(newMemoryWalletGroupStore())
+	balanceHandler := handlers.NewBalanceHandler(data.NewBalanceService(balanceRPC, cache))
+	balanceHandler.SetStakeService(data.NewStakeService(stakeRPC, cache))
+	balanceHandler.SetWalletGroups(groups)
+	groupHandler := handlers.NewWalletGroupHandler(groups)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middleware.APIKeyAuth(mockAuth))
+	api.HandleFunc("/get-balance", balanceHandler.GetBalanceHandler).Methods("POST")
+	api.HandleFunc("/wallet-groups", groupHandler.CreateWalletGroupHandler).Methods("POST")
+	api.HandleFunc("/wallet-groups", groupHandler.ListWalletGroupsHandler).Methods("GET")
+	api.HandleFunc("/wallet-groups/{id}", groupHandler.GetWalletGroupHandler).Methods("GET")
+	api.HandleFunc("/wallet-groups/{id}", groupHandler.DeleteWalletGroupHandler).Methods("DELETE")
+
+	do := func(key, method, path string, body interface{}) (*httptest.ResponseRecorder, models.Response) {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("X-Token", key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var response models.Response
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	invalid := []models.WalletGroupRequest{
+		{Name: " ", Wallets: []string{testWallet}},
+		{Name: "treasury"},
+		{Name: "treasury", Wallets: []string{testWallet, "not-a-wallet"}},
+		{Name: "treasury", Wallets: []string{testWallet, testOtherWallet, testMint, testVoter}},
+	}
+	for _, request := range invalid {
+		rr, _ := do("valid-key", "POST", "/api/wallet-groups", request)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, request)
+	}
+
+	rr, response := do("valid-key", "POST", "/api/wallet-groups", models.WalletGroupRequest{
+		Name:    "treasury",
+		Wallets: []string{testWallet, testOtherWallet, testWallet},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	created := response.Data.(map[string]interface{})
+	id := created["id"].(string)
+	assert.Len(t, created["wallets"], 2)
+
+	_, response = do("valid-key", "GET", "/api/wallet-groups", nil)
+	assert.Len(t, response.Data, 1)
+	rr, _ = do("other-key", "GET", "/api/wallet-groups/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// The group holds more wallets than a request may list
+	rr, _ = do("valid-key", "POST", "/api/get-balance", models.BalanceRequest{Wallets: []string{testWallet, testOtherWallet}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req := httptest.NewRequest("POST", "/api/get-balance", bytes.NewBufferString(`{"group_id": "`+id+`", "include_staked": true}`))
+	req.Header.Set("X-Token", "valid-key")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var balances struct {
+		Data models.GroupBalances `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &balances))
+	assert.Equal(t, models.WalletGroupRef{ID: id, Name: "treasury"}, balances.Data.Group)
+	require.Len(t, balances.Data.Wallets, 2)
+	assert.Equal(t, 1.5, balances.Data.Totals.Balance)
+	require.NotNil(t, balances.Data.Totals.Staked)
+	assert.Equal(t, 0.0, *balances.Data.Totals.Staked)
+	assert.Equal(t, 1.5, balances.Data.Totals.Total)
+	assert.True(t, balances.Data.Totals.Partial)
+
+	rr, _ = do("valid-key", "POST", "/api/get-balance", models.BalanceRequest{GroupID: id, Wallets: []string{testWallet}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr, _ = do("other-key", "POST", "/api/get-balance", models.BalanceRequest{GroupID: id})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr, _ = do("other-key", "DELETE", "/api/wallet-groups/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr, _ = do("valid-key", "DELETE", "/api/wallet-groups/"+id, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr, _ = do("valid-key", "POST", "/api/get-balance", models.BalanceRequest{GroupID: id})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPortfolioByWalletGroup(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.MaxWalletsPerRequest = 1
+		c.NFTCacheTTL = 0
+	})
+	cache := data.NewMemoryBalanceCache(100)
+	defer cache.Close()
+
+	groups := data.NewWalletGroupService(newMemoryWalletGroupStore())
+	group, err := groups.Create("", models.WalletGroupRequest{Name: "team", Wallets: []string{testWallet, testOtherWallet}})
+	require.NoError(t, err)
+
+	balanceRPC := &MockBalanceRPC{}
+	balanceRPC.On("GetBalance", mock.Anything).Return(2.0, nil)
+	tokenRPC := &MockTokenRPC{}
+	tokenRPC.On("GetTokenAccounts", mock.Anything).Return([]rpc.TokenHolding{}, nil)
+	stakeRPC := &MockStakeRPC{}
+	stakeRPC.On("GetStakeAccounts", mock.Anything).Return([]rpc.StakeAccount{}, nil)
+	nftRPC := &MockNFTRPC{}
+	nftRPC.On("GetTokenAccounts", mock.Anything).Return([]rpc.TokenHolding{}, nil)
+	nftRPC.On("GetAssetsByOwner", mock.Anything, 1, rpc.MaxAssetsPerPage).Return(&rpc.AssetPage{}, nil)
+
+	handler := handlers.NewPortfolioHandler(data.NewPortfolioService(
+		data.NewBalanceService(balanceRPC, cache),
+		data.NewTokenService(tokenRPC, cache, data.NewTokenMetadataService(tokenRPC, cache)),
+		data.NewStakeService(stakeRPC, cache),
+		data.NewNFTService(nftRPC, cache),
+	))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.PortfolioHandler(rr, httptest.NewRequest("POST", "/api/portfolio", bytes.NewBufferString(body)))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"group_id": "`+group.ID+`"}`).Code)
+
+	handler.SetWalletGroups(groups)
+	rr := post(`{"group_id": "` + group.ID + `"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data models.Portfolio `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.NotNil(t, response.Data.Group)
+	assert.Equal(t, "team", response.Data.Group.Name)
+	assert.Len(t, response.Data.Wallets, 2)
+	assert.Equal(t, 4.0, response.Data.Totals.Total)
+
+	assert.Equal(t, http.StatusNotFound, post(`{"group_id": "missing"}`).Code)
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"nova-api/config"
+	"nova-api/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/goleak"
+)
+
+type MockWatchlist struct {
+	mock.Mock
+}
+
+func (m *MockWatchlist) GetWatchlist() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func withWarmerConfig(t *testing.T, wallets []string, budget, learnTopN int) {
+	withConfig(t, func(c *config.Config) {
+		c.WatchlistWallets = wallets
+		c.CacheWarmerRPCBudget = budget
+		c.CacheWarmerLearnTopN = learnTopN
+	})
+}
+
+func TestCacheWarmerStaysWithinBudget(t *testing.T) {
+	withWarmerConfig(t, []string{"wallet-a", "wallet-b"}, 2, 0)
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", mock.Anything).Return(1.0, nil)
+
+	watchlist := &MockWatchlist{}
+	watchlist.On("GetWatchlist").Return([]string{"wallet-b", "wallet-c"}, nil)
+
+	service := data.NewBalanceService(mockRPC, data.NewMemoryBalanceCache(100))
+	defer service.Close()
+	warmer := data.NewCacheWarmer(service, watchlist)
+
+	assert.Equal(t, 2, warmer.Refresh())
+	assert.Equal(t, []string{"wallet-a", "wallet-b", "wallet-c"}, warmer.Wallets())
+	mockRPC.AssertNumberOfCalls(t, "GetBalance", 2)
+
+	// The next cycle picks up where the budget cut the previous one off
+	assert.Equal(t, 2, warmer.Refresh())
+	mockRPC.AssertNumberOfCalls(t, "GetBalance", 4)
+	mockRPC.AssertCalled(t, "GetBalance", "wallet-c")
+
+	// Warmed balances are served without another RPC call
+	balance, err := service.GetBalance("wallet-c")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, balance)
+	mockRPC.AssertNumberOfCalls(t, "GetBalance", 4)
+}
+
+func TestCacheWarmerLearnsHotWallets(t *testing.T) {
+	withWarmerConfig(t, nil, 0, 1)
+
+	mockRPC := &MockBalanceRPC{}
+	mockRPC.On("GetBalance", mock.Anything).Return(1.0, nil)
+
+	service := data.NewBalanceService(mockRPC, data.NoopBalanceCache{})
+	for i := 0; i < 3; i++ {
+		service.GetBalance("hot-wallet")
+	}
+	service.GetBalance("cold-wallet")
+
+	warmer := data.NewCacheWarmer(service, nil)
+	assert.Equal(t, 1, warmer.Refresh())
+	assert.Equal(t, []string{"hot-wallet"}, warmer.Wallets())
+}
+
+func TestCacheWarmerStops(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	withWarmerConfig(t, nil, 0, 0)
+
+	service := data.NewBalanceService(&MockBalanceRPC{}, data.NoopBalanceCache{})
+	warmer := data.NewCacheWarmer(service, nil)
+	warmer.Start(context.Background())
+	warmer.Close()
+}
@@ -0,0 +1,695 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nova-api/config"
+	"nova-api/data"
+	"nova-api/handlers"
+	"nova-api/middleware"
+	"nova-api/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// memoryWebhookStore is an in-memory WebhookStore
+type memoryWebhookStore struct {
+	mutex       sync.Mutex
+	webhooks    map[string]models.Webhook
+	deliveries  map[string]models.WebhookDelivery
+	deadLetters map[string]models.WebhookDelivery
+	leases      map[string]memoryLease
+}
+
+type memoryLease struct {
+	holder  string
+	expires time.Time
+}
+
+func newMemoryWebhookStore() *memoryWebhookStore {
+	return &memoryWebhookStore{
+		webhooks:    make(map[string]models.Webhook),
+		deliveries:  make(map[string]models.WebhookDelivery),
+		deadLetters: make(map[string]models.WebhookDelivery),
+		leases:      make(map[string]memoryLease),
+	}
+}
+
+func (s *memoryWebhookStore) CreateWebhook(webhook *models.Webhook) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (s *memoryWebhookStore) ListWebhooks(apiKey string) ([]models.Webhook, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhooks := []models.Webhook{}
+	for _, webhook := range s.webhooks {
+		if webhook.APIKey == apiKey {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (s *memoryWebhookStore) AllWebhooks() ([]models.Webhook, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhooks := []models.Webhook{}
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func (s *memoryWebhookStore) DeleteWebhook(apiKey, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if webhook, exists := s.webhooks[id]; !exists || webhook.APIKey != apiKey {
+		return data.ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	return nil
+}
+
+func (s *memoryWebhookStore) SaveDelivery(delivery *models.WebhookDelivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *memoryWebhookStore) ListDeliveries(apiKey, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.APIKey == apiKey && delivery.WebhookID == webhookID && len(deliveries) < limit {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *memoryWebhookStore) SaveDeadLetter(delivery *models.WebhookDelivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deadLetters[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *memoryWebhookStore) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lease, exists := s.leases[name]
+	if exists && lease.holder != holder && time.Now().Before(lease.expires) {
+		return false, nil
+	}
+	s.leases[name] = memoryLease{holder: holder, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryWebhookStore) Deliveries() []models.WebhookDelivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+func (s *memoryWebhookStore) DeadLetters() []models.WebhookDelivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range s.deadLetters {
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+// webhookReceiver records the requests it gets and answers with the queued status codes, then 200
+type webhookReceiver struct {
+	server *httptest.Server
+
+	mutex    sync.Mutex
+	requests []receivedWebhook
+	statuses []int
+}
+
+type receivedWebhook struct {
+	Signature string
+	Body      []byte
+	Event     models.WebhookEvent
+}
+
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event models.WebhookEvent
+		json.Unmarshal(body, &event)
+
+		receiver.mutex.Lock()
+		receiver.requests = append(receiver.requests, receivedWebhook{Signature: r.Header.Get("X-Nova-Signature"), Body: body, Event: event})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status = receiver.statuses[0]
+			receiver.statuses = receiver.statuses[1:]
+		}
+		receiver.mutex.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	return receiver
+}
+
+func (r *webhookReceiver) Requests() []receivedWebhook {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+// Received reports whether an event with balance was delivered for wallet.
+func (r *webhookReceiver) Received(wallet string, balance float64) bool {
+	for _, request := range r.Requests() {
+		if request.Event.Wallet == wallet && request.Event.Balance == balance {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyNoLeaks checks for leaked goroutines after the test's other cleanups have run
+func verifyNoLeaks(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	t.Cleanup(func() { goleak.VerifyNone(t, ignore) })
+}
+
+func withWebhookConfig(t *testing.T, maxAttempts int) {
+	withConfig(t, func(c *config.Config) {
+		c.WebhookMaxAttempts = maxAttempts
+		c.WebhookRetryBackoffMs = 5
+		c.WebhookTimeout = 2
+		c.WebhookWorkers = 2
+		c.WebhookAllowPrivateURLs = true
+		c.WebhookMaxPerKey = 0
+		c.WebhookMaxWalletsPerKey = 0
+		c.WebhookLeaseTTL = 30
+	})
+}
+
+func newTestDispatcher(t *testing.T, store data.WebhookStore) (*data.WebhookDispatcher, *data.BalanceFeed) {
+	service := data.NewBalanceService(&MockBalanceRPC{}, data.NoopBalanceCache{})
+	feed := data.NewBalanceFeed(service, nil)
+	dispatcher, err := data.NewWebhookDispatcher(store, feed)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		dispatcher.Close()
+		feed.Close()
+	})
+	return dispatcher, feed
+}
+
+func TestWebhookDeliversSignedPayload(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 3)
+
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	store := newMemoryWebhookStore()
+	dispatcher, feed := newTestDispatcher(t, store)
+
+	webhook, err := dispatcher.Register("key-1", models.WebhookRequest{URL: receiver.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, webhook.Secret)
+
+	feed.Publish(testWallet, 2.5)
+	require.Eventually(t, func() bool { return len(receiver.Requests()) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	request := receiver.Requests()[0]
+	assert.Equal(t, data.SignWebhookPayload(webhook.Secret, request.Body), request.Signature)
+	assert.Equal(t, "balance.changed", request.Event.Type)
+	assert.Equal(t, testWallet, request.Event.Wallet)
+	assert.Equal(t, 2.5, request.Event.Balance)
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := dispatcher.Deliveries("key-1", webhook.ID, 10)
+		return len(deliveries) == 1 && deliveries[0].Status == "delivered" && deliveries[0].Attempts == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = dispatcher.Deliveries("key-2", webhook.ID, 10)
+	assert.ErrorIs(t, err, data.ErrWebhookNotFound)
+}
+
+func TestWebhookRetriesThenDeadLetters(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 3)
+
+	retried := newWebhookReceiver(http.StatusInternalServerError, http.StatusBadGateway)
+	defer retried.server.Close()
+	failing := newWebhookReceiver(500, 500, 500)
+	defer failing.server.Close()
+
+	store := newMemoryWebhookStore()
+	dispatcher, feed := newTestDispatcher(t, store)
+
+	succeeds, err := dispatcher.Register("key-1", models.WebhookRequest{URL: retried.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	_, err = dispatcher.Register("key-1", models.WebhookRequest{URL: failing.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+
+	feed.Publish(testWallet, 1)
+
+	require.Eventually(t, func() bool { return len(store.DeadLetters()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	deadLetter := store.DeadLetters()[0]
+	assert.Equal(t, "failed", deadLetter.Status)
+	assert.Equal(t, 3, deadLetter.Attempts)
+	assert.Equal(t, 500, deadLetter.StatusCode)
+	assert.Len(t, failing.Requests(), 3)
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := dispatcher.Deliveries("key-1", succeeds.ID, 10)
+		return len(deliveries) == 1 && deliveries[0].Status == "delivered" && deliveries[0].Attempts == 3
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookThresholdFiresOnCrossing(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	dispatcher, feed := newTestDispatcher(t, newMemoryWebhookStore())
+
+	below := 1.0
+	_, err := dispatcher.Register("key-1", models.WebhookRequest{
+		URL:       receiver.server.URL,
+		Wallets:   []string{testWallet},
+		Threshold: &models.WebhookThreshold{Below: &below},
+	})
+	require.NoError(t, err)
+
+	for _, balance := range []float64{2, 1.5, 0.5, 0.4, 3, 0.9} {
+		feed.Publish(testWallet, balance)
+	}
+
+	require.Eventually(t, func() bool { return len(receiver.Requests()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	requests := receiver.Requests()
+	require.Len(t, requests, 2)
+	for _, request := range requests {
+		assert.Equal(t, "balance.below_threshold", request.Event.Type)
+		assert.Less(t, request.Event.Balance, below)
+		require.NotNil(t, request.Event.PreviousBalance)
+		assert.GreaterOrEqual(t, *request.Event.PreviousBalance, below)
+	}
+}
+
+func TestWebhookDeleteStopsDeliveries(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	dispatcher, feed := newTestDispatcher(t, newMemoryWebhookStore())
+
+	webhook, err := dispatcher.Register("key-1", models.WebhookRequest{URL: receiver.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, feed.WatchedWallets())
+
+	assert.ErrorIs(t, dispatcher.Delete("key-2", webhook.ID), data.ErrWebhookNotFound)
+	require.NoError(t, dispatcher.Delete("key-1", webhook.ID))
+	assert.Equal(t, 0, feed.WatchedWallets())
+
+	feed.Publish(testWallet, 1)
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, receiver.Requests())
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+
+	mockAuth := &MockAPIKeyValidator{}
+	mockAuth.On("ValidateAPIKey", "valid-key").Return(&models.APIKey{ID: "valid-key"}, nil)
+	mockAuth.On("ValidateAPIKey", mock.Anything).Return(nil, assert.AnError)
+
+	dispatcher, _ := newTestDispatcher(t, newMemoryWebhookStore())
+	webhookHandler := handlers.NewWebhookHandler(dispatcher)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middleware.APIKeyAuth(mockAuth))
+	api.HandleFunc("/webhooks", webhookHandler.CreateWebhookHandler).Methods("POST")
+	api.HandleFunc("/webhooks", webhookHandler.ListWebhooksHandler).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", webhookHandler.DeleteWebhookHandler).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.DeliveriesHandler).Methods("GET")
+
+	do := func(method, path string, body interface{}) (*httptest.ResponseRecorder, models.Response) {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("X-Token", "valid-key")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var response models.Response
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	invalid := []models.WebhookRequest{
+		{URL: "ftp://example.com", Wallets: []string{testWallet}},
+		{URL: "https://example.com/hook"},
+		{URL: "https://example.com/hook", Wallets: []string{"not-a-wallet"}},
+	}
+	for _, request := range invalid {
+		rr, _ := do("POST", "/api/webhooks", request)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, request)
+	}
+
+	rr, response := do("POST", "/api/webhooks", models.WebhookRequest{URL: "https://example.com/hook", Wallets: []string{testWallet}})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	created := response.Data.(map[string]interface{})
+	assert.NotEmpty(t, created["secret"])
+	id := created["id"].(string)
+
+	rr, response = do("GET", "/api/webhooks", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	listed := response.Data.([]interface{})
+	require.Len(t, listed, 1)
+	assert.Nil(t, listed[0].(map[string]interface{})["secret"])
+
+	rr, _ = do("GET", "/api/webhooks/"+id+"/deliveries?limit=0", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr, _ = do("GET", "/api/webhooks/"+id+"/deliveries", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr, _ = do("DELETE", "/api/webhooks/"+id, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr, _ = do("DELETE", "/api/webhooks/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestWebhookRejectsNonPublicURLs(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+	withConfig(t, func(c *config.Config) { c.WebhookAllowPrivateURLs = false })
+
+	dispatcher, _ := newTestDispatcher(t, newMemoryWebhookStore())
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.5/hook",
+		"http://172.16.3.4/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"ftp://93.184.215.14/hook",
+	} {
+		_, err := dispatcher.Register("key-1", models.WebhookRequest{URL: url, Wallets: []string{testWallet}})
+		assert.ErrorIs(t, err, data.ErrInvalidWebhookURL, url)
+	}
+
+	_, err := dispatcher.Register("key-1", models.WebhookRequest{URL: "https://93.184.215.14/hook", Wallets: []string{testWallet}})
+	assert.NoError(t, err)
+}
+
+func TestWebhookConnectionsToPrivateAddressesAreRefused(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	store := newMemoryWebhookStore()
+	dispatcher, feed := newTestDispatcher(t, store)
+	_, err := dispatcher.Register("key-1", models.WebhookRequest{URL: receiver.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+
+	// As if the host had been rebound to a loopback address after registration
+	withConfig(t, func(c *config.Config) { c.WebhookAllowPrivateURLs = false })
+	feed.Publish(testWallet, 1)
+
+	require.Eventually(t, func() bool { return len(store.DeadLetters()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Contains(t, store.DeadLetters()[0].Error, "non-public address")
+	assert.Empty(t, receiver.Requests())
+}
+
+func TestWebhookRetriesDoNotHoldUpWorkers(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 2)
+	withConfig(t, func(c *config.Config) {
+		c.WebhookWorkers = 1
+		c.WebhookRetryBackoffMs = 500
+	})
+
+	failing := newWebhookReceiver(http.StatusServiceUnavailable)
+	defer failing.server.Close()
+	healthy := newWebhookReceiver()
+	defer healthy.server.Close()
+
+	store := newMemoryWebhookStore()
+	dispatcher, feed := newTestDispatcher(t, store)
+	retried, err := dispatcher.Register("key-1", models.WebhookRequest{URL: failing.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	_, err = dispatcher.Register("key-1", models.WebhookRequest{URL: healthy.server.URL, Wallets: []string{testOtherWallet}})
+	require.NoError(t, err)
+
+	feed.Publish(testWallet, 1)
+	require.Eventually(t, func() bool { return len(failing.Requests()) == 1 }, time.Second, 5*time.Millisecond)
+	feed.Publish(testOtherWallet, 1)
+
+	// The only worker is free for other deliveries while the failed one waits for its retry
+	require.Eventually(t, func() bool { return len(healthy.Requests()) == 1 }, 300*time.Millisecond, 5*time.Millisecond)
+	assert.Len(t, failing.Requests(), 1)
+
+	deliveries, err := dispatcher.Deliveries("key-1", retried.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "pending", deliveries[0].Status)
+	assert.NotNil(t, deliveries[0].NextAttemptAt)
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := dispatcher.Deliveries("key-1", retried.ID, 10)
+		return len(deliveries) == 1 && deliveries[0].Status == "delivered" && deliveries[0].Attempts == 2
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookLimitsPerKey(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+	withConfig(t, func(c *config.Config) {
+		c.WebhookMaxPerKey = 2
+		c.WebhookMaxWalletsPerKey = 2
+	})
+
+	dispatcher, _ := newTestDispatcher(t, newMemoryWebhookStore())
+	register := func(apiKey string, wallets ...string) error {
+		_, err := dispatcher.Register(apiKey, models.WebhookRequest{URL: "https://93.184.215.14/hook", Wallets: wallets})
+		return err
+	}
+
+	require.NoError(t, register("key-1", testWallet))
+	assert.ErrorIs(t, register("key-1", testOtherWallet, testMint), data.ErrWebhookLimit, "three distinct wallets")
+	require.NoError(t, register("key-1", testWallet, testOtherWallet))
+	assert.ErrorIs(t, register("key-1", testWallet), data.ErrWebhookLimit, "three webhooks")
+	assert.NoError(t, register("key-2", testWallet))
+}
+
+func TestWebhookOnlyLeaseHolderDelivers(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+	withConfig(t, func(c *config.Config) { c.WebhookLeaseTTL = 1 })
+
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	// Two replicas sharing one store, each with its own feed
+	store := newMemoryWebhookStore()
+	first, firstFeed := newTestDispatcher(t, store)
+	second, secondFeed := newTestDispatcher(t, store)
+
+	// Registered on the replica that does not deliver, and picked up by the one that does
+	webhook, err := second.Register("key-1", models.WebhookRequest{URL: receiver.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := first.Deliveries("key-1", webhook.ID, 10)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	firstFeed.Publish(testWallet, 1)
+	secondFeed.Publish(testWallet, 1)
+	require.Eventually(t, func() bool { return len(receiver.Requests()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, receiver.Requests(), 1)
+
+	// Once the leader is gone the other replica takes over
+	first.Close()
+	assert.Eventually(t, func() bool {
+		secondFeed.Publish(testWallet, float64(time.Now().UnixNano()))
+		return len(receiver.Requests()) > 1
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestWebhookDeliversWhileAnotherWebhookSubscribes(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	fake := newFakeSolanaWS()
+	defer fake.Close()
+
+	subscriptions := data.NewSubscriptionManager(fake.URL(), newFakeSubscriptionRPC(), data.NoopBalanceCache{})
+	defer subscriptions.Close()
+
+	service := data.NewBalanceService(&MockBalanceRPC{}, data.NoopBalanceCache{})
+	feed := data.NewBalanceFeed(service, subscriptions)
+	defer feed.Close()
+	dispatcher, err := data.NewWebhookDispatcher(newMemoryWebhookStore(), feed)
+	require.NoError(t, err)
+	defer dispatcher.Close()
+
+	webhook, err := dispatcher.Register("key-1", models.WebhookRequest{URL: receiver.server.URL, Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return subscriptions.IsActive(testWallet) }, 2*time.Second, 10*time.Millisecond)
+
+	// The second webhook's upstream subscription hangs in the handshake
+	release := fake.Hold(testOtherWallet)
+	registered := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Register("key-1", models.WebhookRequest{URL: receiver.server.URL, Wallets: []string{testOtherWallet}})
+		registered <- err
+	}()
+	require.Eventually(t, func() bool { return feed.WatchedWallets() == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	feed.Publish(testWallet, 2)
+	assert.Eventually(t, func() bool { return receiver.Received(testWallet, 2) }, 2*time.Second, 10*time.Millisecond)
+	_, err = dispatcher.Deliveries("key-1", webhook.ID, 10)
+	assert.NoError(t, err)
+
+	// Once subscribed the resynced balance is delivered to the new webhook
+	release()
+	assert.NoError(t, <-registered)
+	assert.Eventually(t, func() bool { return receiver.Received(testOtherWallet, 0) }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookIsRolledBackWhenWatchingFails(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+
+	// A stored webhook the feed refuses to watch is not kept half-registered
+	store := newMemoryWebhookStore()
+	broken := models.Webhook{ID: "broken", APIKey: "key-1", URL: "https://example.com", Wallets: []string{testWallet, "not-a-wallet"}}
+	require.NoError(t, store.CreateWebhook(&broken))
+
+	dispatcher, feed := newTestDispatcher(t, store)
+	_, err := dispatcher.Deliveries("key-1", broken.ID, 10)
+	assert.ErrorIs(t, err, data.ErrWebhookNotFound)
+	assert.Equal(t, 0, feed.WatchedWallets())
+
+	_, err = dispatcher.Register("key-1", models.WebhookRequest{URL: "https://example.com", Wallets: []string{testWallet}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, feed.WatchedWallets())
+}
+
+// pausingWebhookStore holds up the next AllWebhooks once paused, after taking
+// its snapshot, until resumed
+type pausingWebhookStore struct {
+	*memoryWebhookStore
+
+	pause   atomic.Bool
+	reading chan struct{}
+	resume  chan struct{}
+}
+
+func (s *pausingWebhookStore) AllWebhooks() ([]models.Webhook, error) {
+	webhooks, err := s.memoryWebhookStore.AllWebhooks()
+	if s.pause.CompareAndSwap(true, false) {
+		s.reading <- struct{}{}
+		<-s.resume
+	}
+	return webhooks, err
+}
+
+func TestWebhookReloadDoesNotUndoConcurrentChanges(t *testing.T) {
+	verifyNoLeaks(t)
+	withWebhookConfig(t, 1)
+	withConfig(t, func(c *config.Config) { c.WebhookLeaseTTL = 1 })
+
+	store := &pausingWebhookStore{memoryWebhookStore: newMemoryWebhookStore(), reading: make(chan struct{}), resume: make(chan struct{})}
+	dispatcher, _ := newTestDispatcher(t, store)
+
+	deleted, err := dispatcher.Register("key-1", models.WebhookRequest{URL: "https://example.com", Wallets: []string{testWallet}})
+	require.NoError(t, err)
+
+	// A reload reads the store, then a webhook is registered and another deleted before it applies the snapshot
+	store.pause.Store(true)
+	<-store.reading
+	type result struct {
+		webhook *models.Webhook
+		err     error
+	}
+	registered := make(chan result, 1)
+	go func() {
+		webhook, err := dispatcher.Register("key-1", models.WebhookRequest{URL: "https://example.com", Wallets: []string{testOtherWallet}})
+		registered <- result{webhook, err}
+	}()
+	removed := make(chan error, 1)
+	go func() { removed <- dispatcher.Delete("key-1", deleted.ID) }()
+	time.Sleep(50 * time.Millisecond)
+	store.resume <- struct{}{}
+
+	added := <-registered
+	require.NoError(t, added.err)
+	require.NoError(t, <-removed)
+
+	// Reloads run one after another, so once the next one reads the store the first has been applied
+	store.pause.Store(true)
+	<-store.reading
+	_, err = dispatcher.Deliveries("key-1", added.webhook.ID, 10)
+	assert.NoError(t, err)
+	_, err = dispatcher.Deliveries("key-1", deleted.ID, 10)
+	assert.ErrorIs(t, err, data.ErrWebhookNotFound)
+	store.resume <- struct{}{}
+}